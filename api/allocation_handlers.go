@@ -0,0 +1,288 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+// allocationRequest asks how to split stock of an item between schools. The
+// quantity defaults to what is available, and all schools are considered
+// unless school_ids is given.
+type allocationRequest struct {
+	ItemId       string              `json:"item_id"`
+	Quantity     *repository.Decimal `json:"quantity"`
+	Minimum      repository.Decimal  `json:"minimum"`
+	RoundToPacks bool                `json:"round_to_packs"`
+	SchoolIds    []string            `json:"school_ids"`
+	Notes        string              `json:"notes"`
+}
+
+// planAllocation decodes an allocation request and plans it, responding on
+// its own and returning false when it cannot.
+func (s *Server) planAllocation(w http.ResponseWriter, r *http.Request) (internal.AllocationPlan, string, bool) {
+	var req allocationRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err := decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return internal.AllocationPlan{}, "", false
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	v.Required("item_id", req.ItemId)
+	v.NonNegative("minimum", req.Minimum)
+	v.MaxLength("notes", req.Notes, 255)
+
+	if req.Quantity != nil {
+		v.Positive("quantity", *req.Quantity)
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return internal.AllocationPlan{}, "", false
+	}
+
+	wi := repository.WarehouseItem{Id: req.ItemId}
+
+	if err := wi.GetWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return internal.AllocationPlan{}, "", false
+	}
+
+	available := wi.Available
+
+	if req.Quantity != nil {
+		available = *req.Quantity
+	}
+
+	step := repository.NewDecimal(1)
+
+	if req.RoundToPacks && wi.PackUnit != nil {
+		step = wi.PackSize
+	}
+
+	schools, err := repository.GetSchools(s.DB, req.SchoolIds)
+
+	if err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return internal.AllocationPlan{}, "", false
+	}
+
+	return internal.PlanAllocation(wi.Id, available, step, req.Minimum, schools), req.Notes, true
+}
+
+// PlanAllocationHandler proposes how much of an item each school gets, in
+// proportion to enrollment, without reserving anything.
+func (s *Server) PlanAllocationHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	plan, _, ok := s.planAllocation(w, r)
+
+	if !ok {
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, plan)
+}
+
+// CreateAllocationShipmentsHandler plans an allocation and turns it into one
+// draft shipment per school that gets anything, reserving the stock.
+func (s *Server) CreateAllocationShipmentsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	plan, notes, ok := s.planAllocation(w, r)
+
+	if !ok {
+		return
+	}
+
+	shipments := []repository.Shipment{}
+
+	for _, l := range plan.Lines {
+		if l.Quantity.IsPositive() {
+			shipments = append(shipments, repository.Shipment{
+				SchoolId: l.SchoolId,
+				Notes:    notes,
+				Lines:    []repository.ShipmentLine{{ItemId: plan.ItemId, Quantity: l.Quantity}},
+			})
+		}
+	}
+
+	if len(shipments) == 0 {
+		internal.RespondWithError(w, http.StatusUnprocessableEntity, "Nothing to allocate")
+		return
+	}
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	if err = repository.CreateDraftShipments(s.DB, shipments, &userId); err != nil {
+		s.respondWithShipmentError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, shipments)
+}
+
+func (s *Server) GetShipmentsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+
+	shipments, err := repository.GetShipments(s.DB, q.Get("school_id"), q.Get("status"), q.Get("period"))
+
+	if err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, shipments)
+}
+
+func (s *Server) GetShipmentHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	sh := repository.Shipment{Id: mux.Vars(r)["id"]}
+
+	if err = sh.GetShipmentById(s.DB); err != nil {
+		s.respondWithShipmentError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, sh)
+}
+
+// DispatchShipmentHandler issues a draft shipment's stock to its school.
+func (s *Server) DispatchShipmentHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	sh := repository.Shipment{Id: mux.Vars(r)["id"]}
+
+	if err = sh.Dispatch(s.DB); err != nil {
+		s.respondWithShipmentError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, sh)
+}
+
+// CancelShipmentHandler cancels a draft shipment, releasing its stock.
+func (s *Server) CancelShipmentHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	sh := repository.Shipment{Id: mux.Vars(r)["id"]}
+
+	if err = sh.Cancel(s.DB); err != nil {
+		s.respondWithShipmentError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) respondWithShipmentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		internal.RespondWithError(w, http.StatusNotFound, "Shipment not found")
+	case errors.Is(err, repository.ErrShipmentNotDraft), errors.Is(err, repository.ErrInsufficientStock),
+		errors.Is(err, repository.ErrExpiredStock), errors.Is(err, repository.ErrItemArchived),
+		errors.Is(err, repository.ErrItemLocked), errors.Is(err, repository.ErrStockReserved):
+		internal.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		internal.RespondWithStorageError(w, err, "")
+	}
+}
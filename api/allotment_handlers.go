@@ -0,0 +1,274 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type allotmentRunRequest struct {
+	Period string `json:"period"`
+	Notes  string `json:"notes"`
+}
+
+// allotmentRunResult tells which shipments a run created and which schools
+// it skipped because they were already served in the period.
+type allotmentRunResult struct {
+	Period  string                `json:"period"`
+	Created []repository.Shipment `json:"created"`
+	Skipped []string              `json:"skipped"`
+}
+
+func (s *Server) CreateAllotmentHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	var a repository.Allotment
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&a); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if errs := internal.ValidateAllotment(a); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	if err = a.CreateAllotment(s.DB); err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, a)
+}
+
+func (s *Server) GetAllotmentsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	allotments, err := repository.GetAllotments(s.DB)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, allotments)
+}
+
+func (s *Server) DeleteAllotmentHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	a := repository.Allotment{Id: mux.Vars(r)["id"]}
+
+	if err = a.DeleteAllotment(s.DB); err != nil {
+		if errors.Is(err, repository.ErrAllotmentNotFound) {
+			internal.RespondWithError(w, http.StatusNotFound, "Allotment not found")
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// planAllotments decodes a run request and plans the period's allotments,
+// responding on its own and returning false when it cannot.
+func (s *Server) planAllotments(w http.ResponseWriter, r *http.Request) (internal.AllotmentPlan, string, bool) {
+	var req allotmentRunRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err := decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return internal.AllotmentPlan{}, "", false
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	_, semester, err := internal.ParseSemester(req.Period)
+
+	if err != nil {
+		v.Add("period", "invalid", err.Error())
+	}
+
+	v.MaxLength("notes", req.Notes, 255)
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return internal.AllotmentPlan{}, "", false
+	}
+
+	allotments, err := repository.GetAllotments(s.DB)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return internal.AllotmentPlan{}, "", false
+	}
+
+	schools, err := repository.GetSchools(s.DB, nil)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return internal.AllotmentPlan{}, "", false
+	}
+
+	shipments, err := repository.GetPeriodShipments(s.DB, req.Period)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return internal.AllotmentPlan{}, "", false
+	}
+
+	return internal.PlanAllotments(req.Period, semester, allotments, schools, shipments), req.Notes, true
+}
+
+// PreviewAllotmentsHandler shows what generating a semester would send each
+// school and which items would fall short, without creating anything.
+func (s *Server) PreviewAllotmentsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	plan, _, ok := s.planAllotments(w, r)
+
+	if !ok {
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, plan)
+}
+
+// GenerateAllotmentsHandler creates a draft shipment for every school not yet
+// served in the semester, so running it again for the same period only
+// serves schools it missed. Either every shipment is created or none is.
+func (s *Server) GenerateAllotmentsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	plan, notes, ok := s.planAllotments(w, r)
+
+	if !ok {
+		return
+	}
+
+	result := allotmentRunResult{Period: plan.Period, Created: []repository.Shipment{}, Skipped: []string{}}
+
+	for _, sa := range plan.Schools {
+		if sa.ShipmentId != nil {
+			result.Skipped = append(result.Skipped, sa.SchoolId)
+			continue
+		}
+
+		sh := repository.Shipment{SchoolId: sa.SchoolId, Period: &plan.Period, Notes: notes}
+
+		for _, l := range sa.Lines {
+			sh.Lines = append(sh.Lines, repository.ShipmentLine{ItemId: l.ItemId, Quantity: l.Quantity})
+		}
+
+		result.Created = append(result.Created, sh)
+	}
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	if err = repository.CreateDraftShipments(s.DB, result.Created, &userId); err != nil {
+		if repository.IsUniqueViolation(err) {
+			internal.RespondWithError(w, http.StatusConflict, "Period is already being generated")
+			return
+		}
+
+		s.respondWithShipmentError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, result)
+}
@@ -0,0 +1,326 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type assetCheckOutRequest struct {
+	SchoolId string           `json:"school_id"`
+	DueAt    *repository.Date `json:"due_at"`
+	Notes    string           `json:"notes"`
+}
+
+type assetCheckInRequest struct {
+	Condition string `json:"condition"`
+	Notes     string `json:"notes"`
+}
+
+func (s *Server) CreateAssetHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var a repository.Asset
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&a); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if errs := internal.ValidateAsset(a); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	if err = a.CreateAsset(s.DB, &userId); err != nil {
+		internal.RespondWithStorageError(w, err, "Serial number already registered")
+		return
+	}
+
+	if err = a.GetAssetById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, a)
+}
+
+func (s *Server) GetAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+
+	f := repository.AssetFilter{Kind: q.Get("kind"), SchoolId: q.Get("school_id"), Status: q.Get("status")}
+
+	switch f.Status {
+	case "", repository.AssetInWarehouse, repository.AssetCheckedOut, repository.AssetOverdue, repository.AssetRetired:
+	default:
+		internal.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("invalid status %q", f.Status))
+		return
+	}
+
+	assets, err := repository.GetAssets(s.DB, f)
+
+	if err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, assets)
+}
+
+func (s *Server) GetAssetHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	a := repository.Asset{Id: mux.Vars(r)["id"]}
+
+	if err = a.GetAssetById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Asset not found")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, a)
+}
+
+func (s *Server) GetAssetHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	a := repository.Asset{Id: mux.Vars(r)["id"]}
+
+	if err = a.GetAssetById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Asset not found")
+		return
+	}
+
+	events, err := a.GetHistory(s.DB)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, events)
+}
+
+// CheckOutAssetHandler loans an asset to a school, optionally until a due
+// date after which it is reported as overdue.
+func (s *Server) CheckOutAssetHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req assetCheckOutRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	v.Required("school_id", req.SchoolId)
+	v.MaxLength("notes", req.Notes, 255)
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	a := repository.Asset{Id: mux.Vars(r)["id"]}
+
+	if err = a.CheckOut(s.DB, req.SchoolId, req.DueAt, req.Notes, &userId); err != nil {
+		s.respondWithAssetError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, a)
+}
+
+// CheckInAssetHandler returns an asset to the warehouse, recording the
+// condition it came back in.
+func (s *Server) CheckInAssetHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req assetCheckInRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	if req.Condition != "" {
+		v.OneOf("condition", req.Condition, repository.AssetConditions...)
+	}
+
+	v.MaxLength("notes", req.Notes, 255)
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	a := repository.Asset{Id: mux.Vars(r)["id"]}
+
+	if err = a.CheckIn(s.DB, req.Condition, req.Notes, &userId); err != nil {
+		s.respondWithAssetError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, a)
+}
+
+// RetireAssetHandler takes an asset out of use. Its history is kept.
+func (s *Server) RetireAssetHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	a := repository.Asset{Id: mux.Vars(r)["id"]}
+
+	if err = a.Retire(s.DB, r.URL.Query().Get("reason"), &userId); err != nil {
+		s.respondWithAssetError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) respondWithAssetError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		internal.RespondWithError(w, http.StatusNotFound, "Asset not found")
+	case errors.Is(err, repository.ErrAssetRetired), errors.Is(err, repository.ErrAssetCheckedOut),
+		errors.Is(err, repository.ErrAssetNotCheckedOut), errors.Is(err, repository.ErrAssetBroken),
+		errors.Is(err, repository.ErrAssetUnderRepair):
+		internal.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		internal.RespondWithStorageError(w, err, "")
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+func (s *Server) GetWarehouseItemByBarcodeHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	code, err := internal.NormalizeGTIN(mux.Vars(r)["code"])
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var wi repository.WarehouseItem
+
+	if err = wi.GetWarehouseItemByBarcode(s.DB, code); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	if err = wi.GetBarcodes(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, wi)
+}
+
+func (s *Server) CreateBarcodeHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var b repository.Barcode
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&b); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if b.Code, err = internal.NormalizeGTIN(b.Code); err != nil {
+		internal.RespondWithValidationErrors(w, internal.ValidationErrors{{Field: "code", Code: "invalid", Message: err.Error()}})
+		return
+	}
+
+	var wi repository.WarehouseItem
+
+	wi.Id = mux.Vars(r)["id"]
+
+	if err = wi.GetWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	b.ItemId = wi.Id
+
+	if err = b.CreateBarcode(s.DB); err != nil {
+		internal.RespondWithStorageError(w, err, "Barcode already registered")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, b)
+}
+
+func (s *Server) DeleteBarcodeHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	code, err := internal.NormalizeGTIN(vars["code"])
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	b := repository.Barcode{Code: code, ItemId: vars["id"]}
+
+	deleted, err := b.DeleteBarcode(s.DB)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	if !deleted {
+		internal.RespondWithError(w, http.StatusNotFound, "Barcode not found")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,262 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+const (
+	maxBatchOperations = 500
+
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+)
+
+type batchOperation struct {
+	Op      string          `json:"op"`
+	Id      string          `json:"id"`
+	Version *int32          `json:"version"`
+	Item    json.RawMessage `json:"item"`
+}
+
+type batchRequest struct {
+	ContinueOnError bool             `json:"continue_on_error"`
+	Operations      []batchOperation `json:"operations"`
+}
+
+type batchResult struct {
+	Index   int                       `json:"index"`
+	Op      string                    `json:"op"`
+	Status  int                       `json:"status"`
+	Id      string                    `json:"id,omitempty"`
+	Version int32                     `json:"version,omitempty"`
+	Item    *repository.WarehouseItem `json:"item,omitempty"`
+	Error   string                    `json:"error,omitempty"`
+	Errors  internal.ValidationErrors `json:"errors,omitempty"`
+}
+
+// BatchWarehouseItemsHandler runs a list of item creates, updates and deletes
+// in one transaction. Unless continue_on_error is set the first failure rolls
+// back every operation and the response carries that operation's status.
+func (s *Server) BatchWarehouseItemsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req batchRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	if len(req.Operations) == 0 {
+		v.Add("operations", "required", "operations must have at least one operation")
+	} else if len(req.Operations) > maxBatchOperations {
+		v.Add("operations", "too_many", fmt.Sprintf("operations must have at most %d operations", maxBatchOperations))
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	batch, err := repository.BeginBatch(s.DB, req.ContinueOnError)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	results := make([]batchResult, len(req.Operations))
+	failed := 0
+
+	for i, op := range req.Operations {
+		results[i] = runBatchOperation(batch, i, op)
+
+		if failed == 0 && results[i].Status >= http.StatusBadRequest {
+			failed = results[i].Status
+		}
+	}
+
+	if !req.ContinueOnError && failed != 0 {
+		batch.Rollback()
+
+		for i := range results {
+			if results[i].Status < http.StatusBadRequest {
+				results[i] = batchResult{
+					Index: i, Op: results[i].Op, Status: http.StatusFailedDependency,
+					Error: "rolled back because another operation failed",
+				}
+			}
+		}
+
+		internal.RespondWithJSON(w, failed, map[string]any{"error": "Batch rolled back", "results": results})
+		return
+	}
+
+	if err = batch.Commit(); err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, map[string]any{"results": results})
+}
+
+func runBatchOperation(batch *repository.Batch, i int, op batchOperation) batchResult {
+	res := batchResult{Index: i, Op: op.Op, Id: op.Id}
+
+	if batch.Aborted() {
+		res.Status = http.StatusFailedDependency
+		res.Error = "not run because an earlier operation failed"
+		return res
+	}
+
+	var v internal.Validator
+
+	v.OneOf("op", op.Op, batchCreate, batchUpdate, batchDelete)
+
+	if op.Op == batchUpdate || op.Op == batchDelete {
+		v.Required("id", op.Id)
+
+		if op.Version == nil {
+			v.Add("version", "required", "version is required")
+		}
+	}
+
+	if (op.Op == batchCreate || op.Op == batchUpdate) && len(op.Item) == 0 {
+		v.Add("item", "required", "item is required")
+	}
+
+	var wi repository.WarehouseItem
+
+	err := batch.Do(func(tx *sql.Tx) error {
+		if len(v.Errors) > 0 {
+			return v.Errors
+		}
+
+		switch op.Op {
+		case batchCreate:
+			if err := json.Unmarshal(op.Item, &wi); err != nil {
+				return internal.ValidationErrors{{Field: "item", Code: "invalid", Message: "item is not a valid item"}}
+			}
+
+			if errs := internal.ValidateWarehouseItem(wi); len(errs) > 0 {
+				return errs.PrefixFields("item")
+			}
+
+			return wi.CreateWarehouseItemTx(tx)
+		case batchUpdate:
+			current := repository.WarehouseItem{Id: op.Id}
+
+			if err := current.GetWarehouseItemByIdTx(tx); err != nil {
+				return err
+			}
+
+			current.Version = *op.Version
+
+			updated, err := internal.PatchWarehouseItem(current, "application/merge-patch+json", op.Item)
+
+			var errs internal.ValidationErrors
+
+			if errors.As(err, &errs) {
+				return errs.PrefixFields("item")
+			}
+
+			if err != nil {
+				return internal.ValidationErrors{{Field: "item", Code: "invalid", Message: err.Error()}}
+			}
+
+			if errs := internal.ValidateWarehouseItem(updated); len(errs) > 0 {
+				return errs.PrefixFields("item")
+			}
+
+			if err = updated.UpdateWarehouseItemTx(tx); err != nil {
+				return err
+			}
+
+			wi = updated
+
+			return wi.GetWarehouseItemByIdTx(tx)
+		default:
+			wi.Id = op.Id
+
+			if err := wi.GetWarehouseItemByIdTx(tx); err != nil {
+				return err
+			}
+
+			wi.Version = *op.Version
+
+			return wi.DeleteWarehouseItemTx(tx)
+		}
+	})
+
+	if err != nil {
+		res.Status, res.Error, res.Errors = batchErrorResponse(err)
+		return res
+	}
+
+	switch op.Op {
+	case batchCreate:
+		res.Status = http.StatusCreated
+		res.Id, res.Version, res.Item = wi.Id, wi.Version, &wi
+	case batchUpdate:
+		res.Status = http.StatusOK
+		res.Version, res.Item = wi.Version, &wi
+	default:
+		res.Status = http.StatusNoContent
+	}
+
+	return res
+}
+
+// batchErrorResponse maps an operation's error to the status, message and
+// field errors the single item endpoints would respond with.
+func batchErrorResponse(err error) (int, string, internal.ValidationErrors) {
+	var errs internal.ValidationErrors
+
+	switch {
+	case errors.As(err, &errs):
+		return http.StatusUnprocessableEntity, "Validation failed", errs
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound, "Item not found", nil
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, err.Error(), nil
+	case errors.Is(err, repository.ErrNameTaken), repository.IsUniqueViolation(err):
+		return http.StatusConflict, "Item already registered", nil
+	case errors.Is(err, repository.ErrInsufficientStock), errors.Is(err, repository.ErrStockReserved),
+		errors.Is(err, repository.ErrItemLocked), errors.Is(err, repository.ErrItemArchived):
+		return http.StatusConflict, err.Error(), nil
+	case repository.IsInvalidData(err):
+		field := repository.ConstraintField(err)
+		return http.StatusUnprocessableEntity, "Validation failed",
+			internal.ValidationErrors{{Field: field, Code: "invalid", Message: field + " is invalid"}}
+	default:
+		return http.StatusInternalServerError, internalServerError, nil
+	}
+}
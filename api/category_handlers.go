@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+func (s *Server) CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var c repository.Category
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err := decoder.Decode(&c); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if errs := internal.ValidateCategory(c); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	if err = c.CreateCategory(s.DB); err != nil {
+		internal.RespondWithStorageError(w, err, "Category already registered")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, c)
+}
+
+func (s *Server) GetCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	categories, err := repository.GetCategories(s.DB)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, categories)
+}
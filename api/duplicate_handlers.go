@@ -0,0 +1,136 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type mergeRequest struct {
+	DuplicateIds []string `json:"duplicate_ids"`
+}
+
+// GetDuplicateItemsHandler reports pairs of items whose names are alike enough
+// to probably be the same item. threshold, between 0 and 1, sets how alike.
+func (s *Server) GetDuplicateItemsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	threshold := internal.DefaultDuplicateThreshold
+
+	if t := r.URL.Query().Get("threshold"); t != "" {
+		if threshold, err = strconv.ParseFloat(t, 64); err != nil || threshold <= 0 || threshold > 1 {
+			internal.RespondWithError(w, http.StatusBadRequest, "threshold must be greater than 0 and at most 1")
+			return
+		}
+	}
+
+	names, err := repository.GetItemNames(s.DB)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, internal.FindDuplicates(names, threshold))
+}
+
+// MergeWarehouseItemHandler folds duplicate items into the item in the URL,
+// which keeps its name and gains their stock and history.
+func (s *Server) MergeWarehouseItemHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	var req mergeRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	wi := repository.WarehouseItem{Id: mux.Vars(r)["id"]}
+
+	var v internal.Validator
+
+	if len(req.DuplicateIds) == 0 {
+		v.Add("duplicate_ids", "required", "duplicate_ids must have at least one item")
+	}
+
+	listed := map[string]bool{wi.Id: true}
+
+	for i, id := range req.DuplicateIds {
+		field := fmt.Sprintf("duplicate_ids[%d]", i)
+
+		if v.Required(field, id) && listed[id] {
+			v.Add(field, "duplicate", "item is listed twice or is the item being kept")
+		}
+
+		listed[id] = true
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	merges, err := wi.Merge(s.DB, req.DuplicateIds, fmt.Sprintf("%v", claims["user_id"]))
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		case errors.Is(err, repository.ErrItemLocked), errors.Is(err, repository.ErrUnitMismatch),
+			errors.Is(err, repository.ErrPackSizeMismatch), errors.Is(err, repository.ErrNestedKit):
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			internal.RespondWithStorageError(w, err, "")
+		}
+
+		return
+	}
+
+	w.Header().Set("ETag", internal.ETag(wi.Version, wi.Reserved))
+	internal.RespondWithJSON(w, http.StatusOK, map[string]any{"item": wi, "merges": merges})
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type forecastRequest struct {
+	internal.ForecastParams
+	VacationMonths []int `json:"vacation_months"`
+}
+
+type recommendationsRequest struct {
+	Ids []string `json:"ids"`
+}
+
+func (s *Server) RunForecastHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	req := forecastRequest{ForecastParams: internal.DefaultForecastParams()}
+
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+
+		if err = decoder.Decode(&req); err != nil {
+			internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+			return
+		}
+
+		defer r.Body.Close()
+	}
+
+	errs := req.Validate()
+
+	if req.VacationMonths != nil {
+		req.Calendar = internal.SchoolCalendar{}
+
+		for _, m := range req.VacationMonths {
+			if m < 1 || m > 12 {
+				errs = append(errs, internal.FieldError{Field: "vacation_months", Code: "out_of_range", Message: "vacation months must be between 1 and 12"})
+				break
+			}
+
+			req.Calendar[m-1] = true
+		}
+	}
+
+	if len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	if _, err = internal.RunForecast(s.DB, req.ForecastParams, time.Now()); err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	recs, err := repository.GetRecommendations(s.DB, repository.RecommendationPending)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, recs)
+}
+
+func (s *Server) GetRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	recs, err := repository.GetRecommendations(s.DB, r.URL.Query().Get("status"))
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, recs)
+}
+
+func (s *Server) AcceptRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	s.decideRecommendations(w, r, repository.AcceptRecommendations)
+}
+
+func (s *Server) DismissRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	s.decideRecommendations(w, r, repository.DismissRecommendations)
+}
+
+// decideRecommendations accepts or dismisses the pending recommendations
+// listed in the body, or all of them when no ids are given.
+func (s *Server) decideRecommendations(w http.ResponseWriter, r *http.Request, decide func(*sql.DB, []string) ([]repository.Recommendation, error)) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	var req recommendationsRequest
+
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+
+		if err = decoder.Decode(&req); err != nil {
+			internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+			return
+		}
+
+		defer r.Body.Close()
+	}
+
+	recs, err := decide(s.DB, req.Ids)
+
+	if err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, recs)
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type kitComponentRequest struct {
+	ItemId   string             `json:"item_id"`
+	Quantity repository.Decimal `json:"quantity"`
+	Unit     string             `json:"unit"`
+}
+
+type kitRequest struct {
+	Components []kitComponentRequest `json:"components"`
+}
+
+type kitMovementRequest struct {
+	Quantity repository.Decimal `json:"quantity"`
+	Reason   string             `json:"reason"`
+}
+
+func (s *Server) GetKitsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	kits, err := repository.GetKits(s.DB)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, kits)
+}
+
+func (s *Server) GetKitHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	k := repository.Kit{ItemId: mux.Vars(r)["id"]}
+
+	if err = k.GetKit(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Kit not found")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, k)
+}
+
+// SetKitComponentsHandler defines what goes into one kit. Component quantities
+// may be given in any of the component's units.
+func (s *Server) SetKitComponentsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req kitRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	k := repository.Kit{ItemId: mux.Vars(r)["id"], Components: []repository.KitComponent{}}
+
+	for _, c := range req.Components {
+		k.Components = append(k.Components, repository.KitComponent{ItemId: c.ItemId, Quantity: c.Quantity})
+	}
+
+	if errs := internal.ValidateKit(k); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	var v internal.Validator
+
+	for i, c := range req.Components {
+		field := fmt.Sprintf("components[%d]", i)
+
+		wi := repository.WarehouseItem{Id: c.ItemId}
+
+		if err = wi.GetWarehouseItemById(s.DB); err != nil {
+			v.Add(field+".item_id", "not_found", "item not found")
+			continue
+		}
+
+		if k.Components[i].Quantity, err = wi.ToBaseUnits(c.Quantity, c.Unit); err != nil {
+			v.Add(field+".unit", "invalid", err.Error())
+		}
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	if err = k.SetComponents(s.DB); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		case errors.Is(err, repository.ErrNestedKit):
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			internal.RespondWithStorageError(w, err, "")
+		}
+
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, k)
+}
+
+func (s *Server) AssembleKitHandler(w http.ResponseWriter, r *http.Request) {
+	s.moveKit(w, r, repository.MovementAssembly)
+}
+
+func (s *Server) DisassembleKitHandler(w http.ResponseWriter, r *http.Request) {
+	s.moveKit(w, r, repository.MovementDisassembly)
+}
+
+func (s *Server) moveKit(w http.ResponseWriter, r *http.Request, kind string) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req kitMovementRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	v.Positive("quantity", req.Quantity)
+	v.MaxLength("reason", req.Reason, 255)
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	k := repository.Kit{ItemId: mux.Vars(r)["id"]}
+
+	if req.Reason == "" {
+		req.Reason = "kit " + kind
+	}
+
+	var movements []repository.StockMovement
+
+	if kind == repository.MovementAssembly {
+		movements, err = k.Assemble(s.DB, req.Quantity, req.Reason)
+	} else {
+		movements, err = k.Disassemble(s.DB, req.Quantity, req.Reason)
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows), errors.Is(err, repository.ErrNotAKit):
+			internal.RespondWithError(w, http.StatusNotFound, "Kit not found")
+		case errors.Is(err, repository.ErrInsufficientStock), errors.Is(err, repository.ErrExpiredStock),
+			errors.Is(err, repository.ErrItemArchived), errors.Is(err, repository.ErrItemLocked),
+			errors.Is(err, repository.ErrStockReserved):
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		}
+
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, map[string]any{"kit": k, "movements": movements})
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type ticketStatusRequest struct {
+	Status    string `json:"status"`
+	Condition string `json:"condition"`
+	Note      string `json:"note"`
+}
+
+type ticketTechnicianRequest struct {
+	TechnicianId *string `json:"technician_id"`
+}
+
+type ticketNoteRequest struct {
+	Body string `json:"body"`
+}
+
+// CreateTicketHandler opens a maintenance ticket for an asset, which stays
+// unavailable until the ticket is resolved or cancelled.
+func (s *Server) CreateTicketHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var t repository.MaintenanceTicket
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&t); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if errs := internal.ValidateMaintenanceTicket(t); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	openedBy := fmt.Sprintf("%v", claims["user_id"])
+
+	t.AssetId = mux.Vars(r)["id"]
+	t.OpenedBy = &openedBy
+
+	if err = t.CreateTicket(s.DB); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			internal.RespondWithError(w, http.StatusNotFound, "Asset not found")
+			return
+		}
+
+		s.respondWithTicketError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, t)
+}
+
+func (s *Server) GetTicketsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+
+	f := repository.TicketFilter{
+		AssetId:      q.Get("asset_id"),
+		SchoolId:     q.Get("school_id"),
+		TechnicianId: q.Get("technician_id"),
+		Status:       q.Get("status"),
+	}
+
+	tickets, err := repository.GetTickets(s.DB, f)
+
+	if err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, tickets)
+}
+
+func (s *Server) GetTicketHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	t := repository.MaintenanceTicket{Id: mux.Vars(r)["id"]}
+
+	if err = t.GetTicketById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Ticket not found")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, t)
+}
+
+// UpdateTicketStatusHandler moves a ticket along, e.g. from open to
+// in_progress. Resolving a ticket may record the condition the asset was
+// repaired to.
+func (s *Server) UpdateTicketStatusHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req ticketStatusRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	if v.Required("status", req.Status) {
+		v.OneOf("status", req.Status, repository.TicketStatuses...)
+	}
+
+	if req.Condition != "" {
+		if req.Status != repository.TicketResolved {
+			v.Add("condition", "invalid", "condition can only be set when resolving a ticket")
+		} else {
+			v.OneOf("condition", req.Condition, repository.AssetConditions...)
+		}
+	}
+
+	v.MaxLength("note", req.Note, 255)
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	t := repository.MaintenanceTicket{Id: mux.Vars(r)["id"]}
+
+	if err = t.Transition(s.DB, req.Status, req.Condition, req.Note, &userId); err != nil {
+		s.respondWithTicketError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, t)
+}
+
+// AssignTicketHandler hands a ticket to a technician. A null technician_id
+// unassigns it.
+func (s *Server) AssignTicketHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	var req ticketTechnicianRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	t := repository.MaintenanceTicket{Id: mux.Vars(r)["id"]}
+
+	if err = t.Assign(s.DB, req.TechnicianId, &userId); err != nil {
+		s.respondWithTicketError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, t)
+}
+
+func (s *Server) AddTicketNoteHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req ticketNoteRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	if v.Required("body", req.Body) {
+		v.MaxLength("body", req.Body, 255)
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	t := repository.MaintenanceTicket{Id: mux.Vars(r)["id"]}
+
+	if err = t.AddNote(s.DB, req.Body, &userId); err != nil {
+		s.respondWithTicketError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, t)
+}
+
+func (s *Server) respondWithTicketError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		internal.RespondWithError(w, http.StatusNotFound, "Ticket not found")
+	case errors.Is(err, repository.ErrTicketClosed), errors.Is(err, repository.ErrTicketTransition),
+		errors.Is(err, repository.ErrAssetHasOpenTicket), errors.Is(err, repository.ErrAssetRetired):
+		internal.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		internal.RespondWithStorageError(w, err, "")
+	}
+}
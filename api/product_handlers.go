@@ -0,0 +1,241 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+// variantRequest either links an existing item as a variant, when ItemId is
+// set, or creates a new item from the embedded fields.
+type variantRequest struct {
+	ItemId  *string            `json:"item_id"`
+	Variant repository.Variant `json:"variant"`
+	repository.WarehouseItem
+}
+
+func (s *Server) CreateProductHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var p repository.Product
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&p); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if errs := internal.ValidateProduct(p); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	if err = p.CreateProduct(s.DB); err != nil {
+		internal.RespondWithStorageError(w, err, "Product already exists")
+		return
+	}
+
+	if err = p.GetProductById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, p)
+}
+
+// GetProductsHandler lists products with the stock of their variants rolled
+// up.
+func (s *Server) GetProductsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	products, err := repository.GetProducts(s.DB, repository.NormalizeName(r.URL.Query().Get("name")))
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, products)
+}
+
+func (s *Server) GetProductHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	p := repository.Product{Id: mux.Vars(r)["id"]}
+
+	if err = p.GetProductById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, p)
+}
+
+// AddVariantHandler adds a variant to a product, either an existing item or a
+// new one named after the product and its attribute values.
+func (s *Server) AddVariantHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req variantRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	p := repository.Product{Id: mux.Vars(r)["id"]}
+
+	if err = p.GetProductById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Product not found")
+		return
+	}
+
+	if errs := internal.ValidateVariant(p, req.Variant); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	if req.ItemId != nil {
+		if err = p.AddVariant(s.DB, *req.ItemId, req.Variant); err != nil {
+			s.respondWithVariantError(w, err)
+			return
+		}
+
+		internal.RespondWithJSON(w, http.StatusOK, p)
+		return
+	}
+
+	wi := req.WarehouseItem
+
+	if wi.Name == "" {
+		wi.Name = p.VariantName(req.Variant)
+	}
+
+	if wi.CategoryId == nil {
+		wi.CategoryId = p.CategoryId
+	}
+
+	if errs := internal.ValidateWarehouseItem(wi); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	if err = p.CreateVariant(s.DB, &wi, req.Variant); err != nil {
+		s.respondWithVariantError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, p)
+}
+
+// RemoveVariantHandler detaches an item from its product. The item and its
+// stock are kept.
+func (s *Server) RemoveVariantHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	vars := mux.Vars(r)
+
+	p := repository.Product{Id: vars["id"]}
+
+	if err = p.RemoveVariant(s.DB, vars["item_id"]); err != nil {
+		s.respondWithVariantError(w, err)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) respondWithVariantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+	case errors.Is(err, repository.ErrNotAVariant):
+		internal.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrItemHasProduct), errors.Is(err, repository.ErrVariantTaken):
+		internal.RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		internal.RespondWithStorageError(w, err, "Item already exists")
+	}
+}
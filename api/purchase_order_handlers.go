@@ -0,0 +1,319 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type purchaseOrderLineRequest struct {
+	ItemId     string             `json:"item_id"`
+	Quantity   repository.Decimal `json:"quantity"`
+	Unit       string             `json:"unit"`
+	ExpectedAt *repository.Date   `json:"expected_at"`
+}
+
+type purchaseOrderRequest struct {
+	SupplierId string                     `json:"supplier_id"`
+	ExpectedAt *repository.Date           `json:"expected_at"`
+	Notes      string                     `json:"notes"`
+	Lines      []purchaseOrderLineRequest `json:"lines"`
+}
+
+type goodsReceiptLineRequest struct {
+	LineId    string              `json:"line_id"`
+	Quantity  repository.Decimal  `json:"quantity"`
+	Unit      string              `json:"unit"`
+	LotCode   string              `json:"lot_code"`
+	ExpiresAt *repository.Date    `json:"expires_at"`
+	UnitCost  *repository.Decimal `json:"unit_cost"`
+}
+
+type goodsReceiptRequest struct {
+	Lines []goodsReceiptLineRequest `json:"lines"`
+}
+
+func (s *Server) CreatePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req purchaseOrderRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	po := repository.PurchaseOrder{SupplierId: req.SupplierId, ExpectedAt: req.ExpectedAt, Notes: req.Notes}
+
+	for _, l := range req.Lines {
+		po.Lines = append(po.Lines, repository.PurchaseOrderLine{ItemId: l.ItemId, Quantity: l.Quantity, ExpectedAt: l.ExpectedAt})
+	}
+
+	if errs := internal.ValidatePurchaseOrder(po); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	var v internal.Validator
+
+	for i, l := range req.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+
+		wi := repository.WarehouseItem{Id: l.ItemId}
+
+		if err = wi.GetWarehouseItemById(s.DB); err != nil {
+			v.Add(field+".item_id", "not_found", "item not found")
+			continue
+		}
+
+		if po.Lines[i].Quantity, err = wi.ToBaseUnits(l.Quantity, l.Unit); err != nil {
+			v.Add(field+".unit", "invalid", err.Error())
+		}
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	if err = po.CreatePurchaseOrder(s.DB); err != nil {
+		internal.RespondWithStorageError(w, err, "Item already ordered")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, po)
+}
+
+func (s *Server) GetPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+
+	orders, err := repository.GetPurchaseOrders(s.DB, q.Get("supplier_id"), q.Get("status"))
+
+	if err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, orders)
+}
+
+func (s *Server) GetPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	po := repository.PurchaseOrder{Id: mux.Vars(r)["id"]}
+
+	if err = po.GetPurchaseOrderById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Purchase order not found")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, po)
+}
+
+func (s *Server) ReceivePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req goodsReceiptRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	po := repository.PurchaseOrder{Id: mux.Vars(r)["id"]}
+
+	if err = po.GetPurchaseOrderById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Purchase order not found")
+		return
+	}
+
+	var v internal.Validator
+
+	if len(req.Lines) == 0 {
+		v.Add("lines", "required", "lines must have at least one line")
+	}
+
+	lines := []repository.GoodsReceiptLine{}
+
+	for i, l := range req.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+
+		if !v.Required(field+".line_id", l.LineId) {
+			continue
+		}
+
+		v.Positive(field+".quantity", l.Quantity)
+
+		if l.UnitCost != nil {
+			v.NonNegative(field+".unit_cost", *l.UnitCost)
+		}
+
+		var ordered *repository.PurchaseOrderLine
+
+		for j := range po.Lines {
+			if po.Lines[j].Id == l.LineId {
+				ordered = &po.Lines[j]
+			}
+		}
+
+		if ordered == nil {
+			v.Add(field+".line_id", "not_found", "line is not part of the purchase order")
+			continue
+		}
+
+		wi := repository.WarehouseItem{Id: ordered.ItemId}
+
+		if err = wi.GetAnyWarehouseItemById(s.DB); err != nil {
+			internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+			return
+		}
+
+		quantity, err := wi.ToBaseUnits(l.Quantity, l.Unit)
+
+		if err != nil {
+			v.Add(field+".unit", "invalid", err.Error())
+			continue
+		}
+
+		gl := repository.GoodsReceiptLine{LineId: l.LineId, Quantity: quantity}
+
+		if l.LotCode != "" || l.ExpiresAt != nil {
+			gl.Lot = &repository.Lot{Code: l.LotCode, ExpiresAt: l.ExpiresAt}
+		}
+
+		if l.UnitCost != nil {
+			cost, _ := wi.ToBaseUnitCost(*l.UnitCost, l.Unit)
+			gl.UnitCost = &cost
+		}
+
+		lines = append(lines, gl)
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	movements, err := po.Receive(s.DB, lines)
+
+	if err != nil {
+		if errors.Is(err, repository.ErrPurchaseOrderClosed) || errors.Is(err, repository.ErrOverReceipt) ||
+			errors.Is(err, repository.ErrItemArchived) || errors.Is(err, repository.ErrItemLocked) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, map[string]any{"purchase_order": po, "movements": movements})
+}
+
+func (s *Server) CancelPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	po := repository.PurchaseOrder{Id: mux.Vars(r)["id"]}
+
+	if err = po.GetPurchaseOrderById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Purchase order not found")
+		return
+	}
+
+	if err = po.Cancel(s.DB); err != nil {
+		if errors.Is(err, repository.ErrPurchaseOrderClosed) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+func (s *Server) GetValuationReportHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+
+	date := repository.Date{Time: time.Now().UTC().Truncate(24 * time.Hour)}
+
+	if q.Get("date") != "" {
+		if date, err = repository.ParseDate(q.Get("date")); err != nil {
+			internal.RespondWithError(w, http.StatusBadRequest, "Invalid date parameter")
+			return
+		}
+	}
+
+	format := q.Get("format")
+
+	if format != "" && format != "json" && format != "csv" {
+		internal.RespondWithError(w, http.StatusBadRequest, "Invalid format parameter")
+		return
+	}
+
+	method, err := internal.CostingMethod()
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	histories, err := repository.GetItemHistories(s.DB, date.AddDate(0, 0, 1))
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	report := internal.NewValuationReport(method, date, histories)
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="valuation-`+date.String()+`.csv"`)
+		w.WriteHeader(http.StatusOK)
+		internal.WriteValuationCSV(w, report)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, report)
+}
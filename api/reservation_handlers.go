@@ -0,0 +1,278 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type reservationRequest struct {
+	ItemId       string             `json:"item_id"`
+	DocumentType string             `json:"document_type"`
+	DocumentId   string             `json:"document_id"`
+	Quantity     repository.Decimal `json:"quantity"`
+	Unit         string             `json:"unit"`
+	ExpiresAt    *time.Time         `json:"expires_at"`
+}
+
+type fulfillRequest struct {
+	Quantity repository.Decimal `json:"quantity"`
+	Unit     string             `json:"unit"`
+}
+
+func (s *Server) CreateReservationHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req reservationRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	v.Required("item_id", req.ItemId)
+	v.Required("document_type", req.DocumentType)
+	v.MaxLength("document_type", req.DocumentType, 30)
+	v.Required("document_id", req.DocumentId)
+	v.MaxLength("document_id", req.DocumentId, 100)
+	v.Positive("quantity", req.Quantity)
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		v.Add("expires_at", "invalid", "expires_at must be in the future")
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	wi := repository.WarehouseItem{Id: req.ItemId}
+
+	if err = wi.GetWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	quantity, err := wi.ToBaseUnits(req.Quantity, req.Unit)
+
+	if err != nil {
+		internal.RespondWithValidationErrors(w, internal.ValidationErrors{{Field: "unit", Code: "invalid", Message: err.Error()}})
+		return
+	}
+
+	res := repository.Reservation{
+		ItemId:       wi.Id,
+		DocumentType: req.DocumentType,
+		DocumentId:   req.DocumentId,
+		Quantity:     quantity,
+		ExpiresAt:    req.ExpiresAt,
+	}
+
+	if err = res.CreateReservation(s.DB); err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithStorageError(w, err, "Reservation already exists")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, res)
+}
+
+func (s *Server) GetReservationsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	q := r.URL.Query()
+
+	reservations, err := repository.GetReservations(s.DB, q.Get("item_id"), q.Get("document_type"), q.Get("document_id"))
+
+	if err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, reservations)
+}
+
+func (s *Server) GetReservationHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	res := repository.Reservation{Id: mux.Vars(r)["id"]}
+
+	if err = res.GetReservationById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Reservation not found")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) FulfillReservationHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req fulfillRequest
+
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+
+		if err = decoder.Decode(&req); err != nil {
+			internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+			return
+		}
+
+		defer r.Body.Close()
+	}
+
+	var v internal.Validator
+
+	v.NonNegative("quantity", req.Quantity)
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	res := repository.Reservation{Id: mux.Vars(r)["id"]}
+
+	if err = res.GetReservationById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Reservation not found")
+		return
+	}
+
+	wi := repository.WarehouseItem{Id: res.ItemId}
+
+	if err = wi.GetAnyWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	quantity, err := wi.ToBaseUnits(req.Quantity, req.Unit)
+
+	if err != nil {
+		internal.RespondWithValidationErrors(w, internal.ValidationErrors{{Field: "unit", Code: "invalid", Message: err.Error()}})
+		return
+	}
+
+	m, err := res.Fulfill(s.DB, quantity)
+
+	if err != nil {
+		if errors.Is(err, repository.ErrReservationClosed) || errors.Is(err, repository.ErrReservationExceeded) ||
+			errors.Is(err, repository.ErrInsufficientStock) || errors.Is(err, repository.ErrExpiredStock) ||
+			errors.Is(err, repository.ErrItemArchived) || errors.Is(err, repository.ErrItemLocked) ||
+			errors.Is(err, repository.ErrStockReserved) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, m)
+}
+
+func (s *Server) ReleaseReservationHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	res := repository.Reservation{Id: mux.Vars(r)["id"]}
+
+	if err = res.GetReservationById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Reservation not found")
+		return
+	}
+
+	if err = res.Release(s.DB); err != nil {
+		if errors.Is(err, repository.ErrReservationClosed) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
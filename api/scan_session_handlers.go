@@ -0,0 +1,247 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type scanRequest struct {
+	Code     string             `json:"code"`
+	Quantity repository.Decimal `json:"quantity"`
+	Unit     string             `json:"unit"`
+}
+
+func (s *Server) CreateScanSessionHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var ss repository.ScanSession
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&ss); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	v.OneOf("kind", ss.Kind, repository.ScanSessionReceipt, repository.ScanSessionCount)
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	if err = ss.CreateScanSession(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, ss)
+}
+
+func (s *Server) GetScanSessionHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	ss := repository.ScanSession{Id: mux.Vars(r)["id"]}
+
+	if err = ss.GetScanSessionById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Scan session not found")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, ss)
+}
+
+func (s *Server) ScanHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req scanRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if req.Quantity.IsZero() {
+		req.Quantity = repository.NewDecimal(1)
+	}
+
+	var v internal.Validator
+
+	v.Positive("quantity", req.Quantity)
+
+	code, err := internal.NormalizeGTIN(req.Code)
+
+	if err != nil {
+		v.Add("code", "invalid", err.Error())
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	ss := repository.ScanSession{Id: mux.Vars(r)["id"]}
+
+	if err = ss.GetScanSessionById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Scan session not found")
+		return
+	}
+
+	var wi repository.WarehouseItem
+
+	if err = wi.GetWarehouseItemByBarcode(s.DB, code); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	quantity, err := wi.ToBaseUnits(req.Quantity, req.Unit)
+
+	if err != nil {
+		internal.RespondWithValidationErrors(w, internal.ValidationErrors{{Field: "unit", Code: "invalid", Message: err.Error()}})
+		return
+	}
+
+	total, err := ss.AddScan(s.DB, wi.Id, quantity)
+
+	if err != nil {
+		if errors.Is(err, repository.ErrScanSessionClosed) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, repository.ScanSessionLine{ItemId: wi.Id, ItemName: wi.Name, Quantity: total})
+}
+
+func (s *Server) CommitScanSessionHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	ss := repository.ScanSession{Id: mux.Vars(r)["id"]}
+
+	if err = ss.GetScanSessionById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Scan session not found")
+		return
+	}
+
+	if err = ss.Commit(s.DB); err != nil {
+		if errors.Is(err, repository.ErrScanSessionClosed) || errors.Is(err, repository.ErrInsufficientStock) ||
+			errors.Is(err, repository.ErrItemArchived) || errors.Is(err, repository.ErrItemLocked) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, ss)
+}
+
+func (s *Server) CancelScanSessionHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	ss := repository.ScanSession{Id: mux.Vars(r)["id"]}
+
+	if err = ss.GetScanSessionById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Scan session not found")
+		return
+	}
+
+	if err = ss.Cancel(s.DB); err != nil {
+		if errors.Is(err, repository.ErrScanSessionClosed) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"github.com/gorilla/mux"
 	"github.com/xsadia/secred/internal"
 	"github.com/xsadia/secred/repository"
 )
@@ -36,10 +39,124 @@ func (s *Server) CreateSchoolHandler(w http.ResponseWriter, r *http.Request) {
 
 	defer r.Body.Close()
 
+	if errs := internal.ValidateSchool(sc); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
 	if err = sc.CreateSchool(s.DB); err != nil {
-		internal.RespondWithError(w, http.StatusConflict, "School already registered")
+		internal.RespondWithStorageError(w, err, "School already registered")
 		return
 	}
 
 	internal.RespondWithJSON(w, http.StatusCreated, sc)
 }
+
+func (s *Server) GetSchoolsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	schools, err := repository.GetSchools(s.DB, nil)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, schools)
+}
+
+func (s *Server) GetSchoolHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	sc := repository.School{Id: mux.Vars(r)["id"]}
+
+	if err = sc.GetSchoolById(s.DB); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			internal.RespondWithError(w, http.StatusNotFound, "School not found")
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, sc)
+}
+
+// UpdateSchoolHandler renames a school or sets its enrollment by hand, for
+// schools whose rosters are not imported.
+func (s *Server) UpdateSchoolHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var sc repository.School
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err := decoder.Decode(&sc); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if errs := internal.ValidateSchool(sc); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	sc.Id = mux.Vars(r)["id"]
+
+	if err = sc.UpdateSchool(s.DB); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			internal.RespondWithError(w, http.StatusNotFound, "School not found")
+			return
+		}
+
+		internal.RespondWithStorageError(w, err, "School already registered")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, sc)
+}
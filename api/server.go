@@ -6,9 +6,11 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
+	"github.com/xsadia/secred/repository"
 	"github.com/xsadia/secred/storage"
 )
 
@@ -24,9 +26,22 @@ const (
 	invalidRequestPayloadError         = "Invalid request payload"
 	invalidClaimError                  = "Invalid token claim"
 	malformedJWTError                  = "Malformed JWT"
-	uuidRegexp                         = "[a-fA-F0-9]{8}-[a-fA-F0-9]{4}-4[a-fA-F0-9]{3}-[8|9|aA|bB][a-fA-F0-9]{3}-[a-fA-F0-9]{12}$"
+	adminOnlyError                     = "Only administrators can do this"
+	uuidRegexp                         = "[a-fA-F0-9]{8}-[a-fA-F0-9]{4}-4[a-fA-F0-9]{3}-[8|9|aA|bB][a-fA-F0-9]{3}-[a-fA-F0-9]{12}"
 )
 
+// isAdmin reports whether the user the token was issued to is an
+// administrator.
+func (s *Server) isAdmin(claims jwt.MapClaims) bool {
+	u := repository.User{Id: fmt.Sprintf("%v", claims["user_id"])}
+
+	if err := u.GetUserById(s.DB); err != nil {
+		return false
+	}
+
+	return u.IsAdmin()
+}
+
 func (s *Server) InitializeDB(host, user, password, dbname string) {
 	connectionString :=
 		fmt.Sprintf("host=%s user=%s password=%s dbname=%s sslmode=disable", host, user, password, dbname)
@@ -42,8 +57,9 @@ func (s *Server) InitializeDB(host, user, password, dbname string) {
 func (s *Server) Run(address string) {
 	ao := handlers.AllowedOrigins([]string{"*"})
 	am := handlers.AllowedMethods([]string{"POST", "GET", "OPTIONS", "PUT", "DELETE", "PATCH"})
-	ah := handlers.AllowedHeaders([]string{"Accept", "Content-Type", "Content-Length", "Authorization"})
-	log.Fatal(http.ListenAndServe(address, handlers.CORS(ao, am, ah)(s.Router)))
+	ah := handlers.AllowedHeaders([]string{"Accept", "Content-Type", "Content-Length", "Authorization", "If-Match", "If-None-Match"})
+	eh := handlers.ExposedHeaders([]string{"ETag", "Link", "X-Total-Count"})
+	log.Fatal(http.ListenAndServe(address, handlers.CORS(ao, am, ah, eh)(s.Router)))
 }
 
 func (s *Server) InitializeRoutes() {
@@ -56,10 +72,103 @@ func (s *Server) InitializeRoutes() {
 
 	s.Router.HandleFunc("/warehouse", s.GetWareHouseItemsHandler).Methods("GET")
 	s.Router.HandleFunc("/warehouse", s.CreateWarehouseItemHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/batch", s.BatchWarehouseItemsHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/duplicates", s.GetDuplicateItemsHandler).Methods("GET")
 	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}", s.GetWareHouseItemHandler).Methods("GET")
 	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}", s.UpdateWarehouseItemHandler).Methods("PATCH")
 	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}", s.DeleteWarehouseItemHandler).Methods("DELETE")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/restore", s.RestoreWarehouseItemHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/purge", s.PurgeWarehouseItemHandler).Methods("DELETE")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/merge", s.MergeWarehouseItemHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/receipts", s.ReceiveWarehouseItemHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/issues", s.IssueWarehouseItemHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/adjust", s.AdjustWarehouseItemHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/movements", s.GetWarehouseItemMovementsHandler).Methods("GET")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/lots", s.GetWarehouseItemLotsHandler).Methods("GET")
+	s.Router.HandleFunc("/warehouse/lots/expiring", s.GetExpiringLotsHandler).Methods("GET")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/components", s.GetKitHandler).Methods("GET")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/components", s.SetKitComponentsHandler).Methods("PUT")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/assemble", s.AssembleKitHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/disassemble", s.DisassembleKitHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/barcodes", s.CreateBarcodeHandler).Methods("POST")
+	s.Router.HandleFunc("/warehouse/{id:"+uuidRegexp+"}/barcodes/{code}", s.DeleteBarcodeHandler).Methods("DELETE")
+	s.Router.HandleFunc("/warehouse/barcode/{code}", s.GetWarehouseItemByBarcodeHandler).Methods("GET")
 	s.Router.HandleFunc("/warehouse/upload", s.UploadCSVWarehouse).Methods("POST")
 
+	s.Router.HandleFunc("/scan-sessions", s.CreateScanSessionHandler).Methods("POST")
+	s.Router.HandleFunc("/scan-sessions/{id:"+uuidRegexp+"}", s.GetScanSessionHandler).Methods("GET")
+	s.Router.HandleFunc("/scan-sessions/{id:"+uuidRegexp+"}", s.CancelScanSessionHandler).Methods("DELETE")
+	s.Router.HandleFunc("/scan-sessions/{id:"+uuidRegexp+"}/scans", s.ScanHandler).Methods("POST")
+	s.Router.HandleFunc("/scan-sessions/{id:"+uuidRegexp+"}/commit", s.CommitScanSessionHandler).Methods("POST")
+
+	s.Router.HandleFunc("/kits", s.GetKitsHandler).Methods("GET")
+
+	s.Router.HandleFunc("/products", s.CreateProductHandler).Methods("POST")
+	s.Router.HandleFunc("/products", s.GetProductsHandler).Methods("GET")
+	s.Router.HandleFunc("/products/{id:"+uuidRegexp+"}", s.GetProductHandler).Methods("GET")
+	s.Router.HandleFunc("/products/{id:"+uuidRegexp+"}/variants", s.AddVariantHandler).Methods("POST")
+	s.Router.HandleFunc("/products/{id:"+uuidRegexp+"}/variants/{item_id:"+uuidRegexp+"}", s.RemoveVariantHandler).Methods("DELETE")
+
+	s.Router.HandleFunc("/categories", s.GetCategoriesHandler).Methods("GET")
+	s.Router.HandleFunc("/categories", s.CreateCategoryHandler).Methods("POST")
+
+	s.Router.HandleFunc("/suppliers", s.CreateSupplierHandler).Methods("POST")
+	s.Router.HandleFunc("/suppliers", s.GetSuppliersHandler).Methods("GET")
+	s.Router.HandleFunc("/purchase-orders", s.CreatePurchaseOrderHandler).Methods("POST")
+	s.Router.HandleFunc("/purchase-orders", s.GetPurchaseOrdersHandler).Methods("GET")
+	s.Router.HandleFunc("/purchase-orders/{id:"+uuidRegexp+"}", s.GetPurchaseOrderHandler).Methods("GET")
+	s.Router.HandleFunc("/purchase-orders/{id:"+uuidRegexp+"}", s.CancelPurchaseOrderHandler).Methods("DELETE")
+	s.Router.HandleFunc("/purchase-orders/{id:"+uuidRegexp+"}/receipts", s.ReceivePurchaseOrderHandler).Methods("POST")
+	s.Router.HandleFunc("/reservations", s.CreateReservationHandler).Methods("POST")
+	s.Router.HandleFunc("/reservations", s.GetReservationsHandler).Methods("GET")
+	s.Router.HandleFunc("/reservations/{id:"+uuidRegexp+"}", s.GetReservationHandler).Methods("GET")
+	s.Router.HandleFunc("/reservations/{id:"+uuidRegexp+"}", s.ReleaseReservationHandler).Methods("DELETE")
+	s.Router.HandleFunc("/reservations/{id:"+uuidRegexp+"}/fulfill", s.FulfillReservationHandler).Methods("POST")
+	s.Router.HandleFunc("/stocktakes", s.CreateStocktakeHandler).Methods("POST")
+	s.Router.HandleFunc("/stocktakes/{id:"+uuidRegexp+"}", s.GetStocktakeHandler).Methods("GET")
+	s.Router.HandleFunc("/stocktakes/{id:"+uuidRegexp+"}", s.CancelStocktakeHandler).Methods("DELETE")
+	s.Router.HandleFunc("/stocktakes/{id:"+uuidRegexp+"}/counts", s.CountStocktakeItemHandler).Methods("POST")
+	s.Router.HandleFunc("/stocktakes/{id:"+uuidRegexp+"}/approve", s.ApproveStocktakeHandler).Methods("POST")
+	s.Router.HandleFunc("/forecasts", s.RunForecastHandler).Methods("POST")
+	s.Router.HandleFunc("/recommendations", s.GetRecommendationsHandler).Methods("GET")
+	s.Router.HandleFunc("/recommendations/accept", s.AcceptRecommendationsHandler).Methods("POST")
+	s.Router.HandleFunc("/recommendations/dismiss", s.DismissRecommendationsHandler).Methods("POST")
+	s.Router.HandleFunc("/reports/valuation", s.GetValuationReportHandler).Methods("GET")
 	s.Router.HandleFunc("/school", s.CreateSchoolHandler).Methods("POST")
+	s.Router.HandleFunc("/schools", s.GetSchoolsHandler).Methods("GET")
+	s.Router.HandleFunc("/schools/{id:"+uuidRegexp+"}", s.GetSchoolHandler).Methods("GET")
+	s.Router.HandleFunc("/schools/{id:"+uuidRegexp+"}", s.UpdateSchoolHandler).Methods("PUT")
+	s.Router.HandleFunc("/schools/{id:"+uuidRegexp+"}/students", s.GetStudentsHandler).Methods("GET")
+	s.Router.HandleFunc("/schools/{id:"+uuidRegexp+"}/students/upload", s.ImportRosterHandler).Methods("POST")
+	s.Router.HandleFunc("/schools/{id:"+uuidRegexp+"}/entitlements/report", s.GetEntitlementReportHandler).Methods("GET")
+	s.Router.HandleFunc("/students/{id:"+uuidRegexp+"}/handouts", s.CreateHandoutHandler).Methods("POST")
+	s.Router.HandleFunc("/students/{id:"+uuidRegexp+"}/handouts", s.GetHandoutsHandler).Methods("GET")
+	s.Router.HandleFunc("/entitlements", s.CreateEntitlementHandler).Methods("POST")
+	s.Router.HandleFunc("/entitlements", s.GetEntitlementsHandler).Methods("GET")
+	s.Router.HandleFunc("/entitlements/{id:"+uuidRegexp+"}", s.DeleteEntitlementHandler).Methods("DELETE")
+	s.Router.HandleFunc("/allotments", s.CreateAllotmentHandler).Methods("POST")
+	s.Router.HandleFunc("/allotments", s.GetAllotmentsHandler).Methods("GET")
+	s.Router.HandleFunc("/allotments/{id:"+uuidRegexp+"}", s.DeleteAllotmentHandler).Methods("DELETE")
+	s.Router.HandleFunc("/allotments/preview", s.PreviewAllotmentsHandler).Methods("POST")
+	s.Router.HandleFunc("/allotments/generate", s.GenerateAllotmentsHandler).Methods("POST")
+	s.Router.HandleFunc("/allocations/plan", s.PlanAllocationHandler).Methods("POST")
+	s.Router.HandleFunc("/allocations/shipments", s.CreateAllocationShipmentsHandler).Methods("POST")
+	s.Router.HandleFunc("/shipments", s.GetShipmentsHandler).Methods("GET")
+	s.Router.HandleFunc("/shipments/{id:"+uuidRegexp+"}", s.GetShipmentHandler).Methods("GET")
+	s.Router.HandleFunc("/shipments/{id:"+uuidRegexp+"}", s.CancelShipmentHandler).Methods("DELETE")
+	s.Router.HandleFunc("/shipments/{id:"+uuidRegexp+"}/dispatch", s.DispatchShipmentHandler).Methods("POST")
+
+	s.Router.HandleFunc("/assets", s.CreateAssetHandler).Methods("POST")
+	s.Router.HandleFunc("/assets", s.GetAssetsHandler).Methods("GET")
+	s.Router.HandleFunc("/assets/{id:"+uuidRegexp+"}", s.GetAssetHandler).Methods("GET")
+	s.Router.HandleFunc("/assets/{id:"+uuidRegexp+"}", s.RetireAssetHandler).Methods("DELETE")
+	s.Router.HandleFunc("/assets/{id:"+uuidRegexp+"}/history", s.GetAssetHistoryHandler).Methods("GET")
+	s.Router.HandleFunc("/assets/{id:"+uuidRegexp+"}/checkout", s.CheckOutAssetHandler).Methods("POST")
+	s.Router.HandleFunc("/assets/{id:"+uuidRegexp+"}/checkin", s.CheckInAssetHandler).Methods("POST")
+	s.Router.HandleFunc("/assets/{id:"+uuidRegexp+"}/tickets", s.CreateTicketHandler).Methods("POST")
+	s.Router.HandleFunc("/tickets", s.GetTicketsHandler).Methods("GET")
+	s.Router.HandleFunc("/tickets/{id:"+uuidRegexp+"}", s.GetTicketHandler).Methods("GET")
+	s.Router.HandleFunc("/tickets/{id:"+uuidRegexp+"}/status", s.UpdateTicketStatusHandler).Methods("POST")
+	s.Router.HandleFunc("/tickets/{id:"+uuidRegexp+"}/technician", s.AssignTicketHandler).Methods("PUT")
+	s.Router.HandleFunc("/tickets/{id:"+uuidRegexp+"}/notes", s.AddTicketNoteHandler).Methods("POST")
 }
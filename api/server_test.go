@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/textproto"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/xsadia/secred/internal"
 	"github.com/xsadia/secred/repository"
 )
 
@@ -341,8 +346,8 @@ func TestWarehouseItems(t *testing.T) {
 			t.Errorf("Expected error to be nil got %q", rs.Error)
 		}
 
-		if rs.Name != "testItem" {
-			t.Errorf("Expected name to be testItem, got %q", rs.Name)
+		if rs.Name != "testitem" {
+			t.Errorf("Expected name to be testitem, got %q", rs.Name)
 		}
 
 		if rs.Quantity != 2 {
@@ -367,6 +372,28 @@ func TestWarehouseItems(t *testing.T) {
 		checkResponseCode(t, http.StatusConflict, response.Code)
 	})
 
+	t.Run("Should return validation errors instead of a conflict for invalid items", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/warehouse", bytes.NewBuffer([]byte(`{
+			"name": "an item name that is way too long to fit in fifty characters",
+			"min": 5,
+			"max": 1
+		}`)))
+		r.Header.Set("Authorization", tokenString)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+
+		var m struct {
+			Errors []map[string]string `json:"errors"`
+		}
+		json.Unmarshal(response.Body.Bytes(), &m)
+
+		if len(m.Errors) != 2 || m.Errors[0]["field"] != "name" || m.Errors[1]["field"] != "min" {
+			t.Errorf("Expected errors on name and min, got %v", m.Errors)
+		}
+	})
+
 	t.Run("Should return items if user is authorized", func(t *testing.T) {
 		r, _ := http.NewRequest("GET", "/warehouse", nil)
 		r.Header.Set("Authorization", tokenString)
@@ -386,8 +413,8 @@ func TestWarehouseItems(t *testing.T) {
 			t.Errorf("Expected error to be nil got %q", items[0].Error)
 		}
 
-		if items[0].Name != "testItem" {
-			t.Errorf("Expected name to be testItem, got %q", items[0].Name)
+		if items[0].Name != "testitem" {
+			t.Errorf("Expected name to be testitem, got %q", items[0].Name)
 		}
 
 		if items[0].Quantity != 2 {
@@ -418,8 +445,8 @@ func TestWarehouseItems(t *testing.T) {
 			t.Errorf("Expected error to be nil got %q", item.Error)
 		}
 
-		if item.Name != "testItem" {
-			t.Errorf("Expected name to be testItem, got %q", item.Name)
+		if item.Name != "testitem" {
+			t.Errorf("Expected name to be testitem, got %q", item.Name)
 		}
 
 		if item.Quantity != 2 {
@@ -444,6 +471,7 @@ func TestWarehouseItems(t *testing.T) {
 
 		r, _ := http.NewRequest("PATCH", "/warehouse/"+rs.Id, bytes.NewBuffer(updateStr))
 		r.Header.Set("Authorization", tokenString)
+		r.Header.Set("If-Match", getETag(rs.Id, tokenString))
 
 		response := executeRequest(r)
 
@@ -463,23 +491,66 @@ func TestWarehouseItems(t *testing.T) {
 			t.Errorf("Expected error to be nil got %q", item.Error)
 		}
 
-		if item.Name != "testItem" {
-			t.Errorf("Expected name to be testItem, got %q", item.Name)
+		if item.Name != "testitem" {
+			t.Errorf("Expected name to be testitem, got %q", item.Name)
 		}
 
-		if item.Quantity != 2 {
+		if item.Quantity != 10 {
 			t.Errorf("Expected quantity to be 10, got %d", item.Quantity)
 		}
 
-		if item.Min != 1 {
-			t.Errorf("Expected min to be 2, got %d", item.Min)
+		if item.Min != 3 {
+			t.Errorf("Expected min to be 3, got %d", item.Min)
 		}
 
-		if item.Max != 3 {
-			t.Errorf("Expected min to be 15, got %d", item.Max)
+		if item.Max != 15 {
+			t.Errorf("Expected max to be 15, got %d", item.Max)
+		}
+	})
+
+	t.Run("Should only update fields present in the body", func(t *testing.T) {
+		r, _ := http.NewRequest("PATCH", "/warehouse/"+rs.Id, bytes.NewBuffer([]byte(`{"name": "renamedItem"}`)))
+		r.Header.Set("Authorization", tokenString)
+		r.Header.Set("Content-Type", "application/merge-patch+json")
+		r.Header.Set("If-Match", getETag(rs.Id, tokenString))
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(r).Code)
+
+		r, _ = http.NewRequest("GET", "/warehouse/"+rs.Id, nil)
+		r.Header.Set("Authorization", tokenString)
+
+		var item ItemResponse
+		json.Unmarshal(executeRequest(r).Body.Bytes(), &item)
+
+		if item.Name != "renameditem" || item.Min != 3 || item.Max != 15 {
+			t.Errorf("Expected only name to change, got %+v", item)
 		}
 	})
 
+	t.Run("Should accept JSON Patch documents", func(t *testing.T) {
+		r, _ := http.NewRequest("PATCH", "/warehouse/"+rs.Id, bytes.NewBuffer([]byte(`[
+			{"op": "test", "path": "/name", "value": "renameditem"},
+			{"op": "replace", "path": "/name", "value": "testItem"}
+		]`)))
+		r.Header.Set("Authorization", tokenString)
+		r.Header.Set("Content-Type", "application/json-patch+json")
+		r.Header.Set("If-Match", getETag(rs.Id, tokenString))
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(r).Code)
+	})
+
+	t.Run("Should not rename an item to a name already in use", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/warehouse", bytes.NewBuffer([]byte(`{"name": "otherItem"}`)))
+		r.Header.Set("Authorization", tokenString)
+		executeRequest(r)
+
+		r, _ = http.NewRequest("PATCH", "/warehouse/"+rs.Id, bytes.NewBuffer([]byte(`{"name": "otherItem"}`)))
+		r.Header.Set("Authorization", tokenString)
+		r.Header.Set("If-Match", getETag(rs.Id, tokenString))
+
+		checkResponseCode(t, http.StatusConflict, executeRequest(r).Code)
+	})
+
 	t.Run("Should throw error when updating a item that doesn't exist", func(t *testing.T) {
 		updateStr := []byte(`{
 			"quantity": 10,
@@ -495,9 +566,41 @@ func TestWarehouseItems(t *testing.T) {
 		checkResponseCode(t, http.StatusNotFound, response.Code)
 	})
 
+	t.Run("Should require If-Match to update an item", func(t *testing.T) {
+		r, _ := http.NewRequest("PATCH", "/warehouse/"+rs.Id, bytes.NewBuffer([]byte(`{"min": 1}`)))
+		r.Header.Set("Authorization", tokenString)
+
+		checkResponseCode(t, http.StatusPreconditionRequired, executeRequest(r).Code)
+	})
+
+	t.Run("Should not update an item that changed since it was read", func(t *testing.T) {
+		etag := getETag(rs.Id, tokenString)
+
+		r, _ := http.NewRequest("PATCH", "/warehouse/"+rs.Id, bytes.NewBuffer([]byte(`{"min": 1}`)))
+		r.Header.Set("Authorization", tokenString)
+		r.Header.Set("If-Match", etag)
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(r).Code)
+
+		r, _ = http.NewRequest("PATCH", "/warehouse/"+rs.Id, bytes.NewBuffer([]byte(`{"min": 2}`)))
+		r.Header.Set("Authorization", tokenString)
+		r.Header.Set("If-Match", etag)
+
+		checkResponseCode(t, http.StatusPreconditionFailed, executeRequest(r).Code)
+	})
+
+	t.Run("Should return 304 if the item did not change", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/warehouse/"+rs.Id, nil)
+		r.Header.Set("Authorization", tokenString)
+		r.Header.Set("If-None-Match", getETag(rs.Id, tokenString))
+
+		checkResponseCode(t, http.StatusNotModified, executeRequest(r).Code)
+	})
+
 	t.Run("Should delete item if item exists", func(t *testing.T) {
 		r, _ := http.NewRequest("DELETE", "/warehouse/"+rs.Id, nil)
 		r.Header.Set("Authorization", tokenString)
+		r.Header.Set("If-Match", getETag(rs.Id, tokenString))
 
 		response := executeRequest(r)
 
@@ -558,51 +661,2076 @@ func TestSchool(t *testing.T) {
 	})
 }
 
-func createAndAuthUser() string {
+func TestWarehouseItemMovements(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
 
-	r, _ := http.NewRequest("POST", "/user", bytes.NewBuffer(userCreationStr))
-	r.Header.Set("Content-Type", "application/json")
+	itemCreationString := []byte(`{
+		"name": "milk",
+		"quantity": 1.5,
+		"min": 12,
+		"max": 48,
+		"unit": "l",
+		"pack_unit": "box",
+		"pack_size": 12
+	}`)
 
-	executeRequest(r)
+	r, _ := http.NewRequest("POST", "/warehouse", bytes.NewBuffer(itemCreationString))
+	r.Header.Set("Authorization", token)
 
-	u := repository.User{Email: "testuser@example.com"}
-	u.GetUserByEmail(s.DB)
+	response := executeRequest(r)
 
-	r, _ = http.NewRequest("GET", "/user/confirm/"+u.Id, nil)
+	checkResponseCode(t, http.StatusCreated, response.Code)
 
-	executeRequest(r)
+	var item repository.WarehouseItem
+	json.Unmarshal(response.Body.Bytes(), &item)
 
-	r, _ = http.NewRequest("POST", "/auth", bytes.NewBuffer(userAuthStr))
+	t.Run("Should convert packs to base units on receipt", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/warehouse/"+item.Id+"/receipts", bytes.NewBuffer([]byte(`{
+			"quantity": 2,
+			"unit": "box"
+		}`)))
+		r.Header.Set("Authorization", token)
 
-	authResponse := executeRequest(r)
+		response := executeRequest(r)
 
-	var am map[string]interface{}
+		checkResponseCode(t, http.StatusCreated, response.Code)
 
-	json.Unmarshal(authResponse.Body.Bytes(), &am)
+		var m repository.StockMovement
+		json.Unmarshal(response.Body.Bytes(), &m)
 
-	tokenString := fmt.Sprintf("bearer %v", am["token"])
+		if m.Quantity != repository.NewDecimal(24) {
+			t.Errorf("Expected movement quantity to be 24, got %s", m.Quantity)
+		}
+	})
 
-	return tokenString
+	t.Run("Should not issue more than what is in stock", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/warehouse/"+item.Id+"/issues", bytes.NewBuffer([]byte(`{
+			"quantity": 30
+		}`)))
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusConflict, response.Code)
+	})
+
+	t.Run("Should issue fractional quantities", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/warehouse/"+item.Id+"/issues", bytes.NewBuffer([]byte(`{
+			"quantity": 0.75
+		}`)))
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		r, _ = http.NewRequest("GET", "/warehouse/"+item.Id, nil)
+		r.Header.Set("Authorization", token)
+
+		response = executeRequest(r)
+
+		var got repository.WarehouseItem
+		json.Unmarshal(response.Body.Bytes(), &got)
+
+		if got.Quantity.String() != "24.75" {
+			t.Errorf("Expected quantity to be 24.75, got %s", got.Quantity)
+		}
+	})
+
+	t.Run("Should keep units on a CSV upload without unit columns", func(t *testing.T) {
+		f, _ := os.CreateTemp("", "items-*.csv")
+		defer os.Remove(f.Name())
+
+		f.WriteString("name,quantity,min,max\nmilk,1,6,24\n")
+		f.Close()
+
+		wil, err := internal.ParseCSV(f.Name())
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		if err = wil[0].UpSertWarehouseItem(s.DB); err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		r, _ := http.NewRequest("GET", "/warehouse/"+item.Id, nil)
+		r.Header.Set("Authorization", token)
+
+		var got repository.WarehouseItem
+		json.Unmarshal(executeRequest(r).Body.Bytes(), &got)
+
+		if got.Unit != "l" || got.PackUnit == nil || *got.PackUnit != "box" || got.PackSize != repository.NewDecimal(12) {
+			t.Errorf("Expected l in boxes of 12, got %s in %v of %s", got.Unit, got.PackUnit, got.PackSize)
+		}
+
+		if got.Min != repository.NewDecimal(6) || got.Quantity.String() != "25.75" {
+			t.Errorf("Expected min 6 and quantity 25.75, got %s and %s", got.Min, got.Quantity)
+		}
+	})
 }
 
-func executeRequest(r *http.Request) *httptest.ResponseRecorder {
-	rr := httptest.NewRecorder()
-	s.Router.ServeHTTP(rr, r)
+func TestAdjustWarehouseItem(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
 
-	return rr
+	r, _ := http.NewRequest("POST", "/warehouse", bytes.NewBuffer([]byte(`{"name": "caderno", "quantity": 50}`)))
+	r.Header.Set("Authorization", token)
+
+	var item repository.WarehouseItem
+	json.Unmarshal(executeRequest(r).Body.Bytes(), &item)
+
+	adjust := func(body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest("POST", "/warehouse/"+item.Id+"/adjust", bytes.NewBuffer([]byte(body)))
+		r.Header.Set("Authorization", token)
+
+		return executeRequest(r)
+	}
+
+	t.Run("Should apply a signed delta and return the new quantity", func(t *testing.T) {
+		response := adjust(`{"delta": -30, "reason": "sent to school"}`)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var m repository.StockMovement
+		json.Unmarshal(response.Body.Bytes(), &m)
+
+		if m.Balance != repository.NewDecimal(20) {
+			t.Errorf("Expected new quantity to be 20, got %s", m.Balance)
+		}
+	})
+
+	t.Run("Should refuse to drive quantity below zero", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, adjust(`{"delta": -21, "reason": "sent to school"}`).Code)
+	})
+
+	t.Run("Should require a reason", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnprocessableEntity, adjust(`{"delta": 5}`).Code)
+	})
 }
 
-func checkResponseCode(t *testing.T, expected, actual int) {
-	t.Helper()
-	if expected != actual {
-		t.Errorf("Expected response code %d. Got %d\n", expected, actual)
+func TestArchiveWarehouseItem(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	create := func(body string) repository.WarehouseItem {
+		r, _ := http.NewRequest("POST", "/warehouse", bytes.NewBuffer([]byte(body)))
+		r.Header.Set("Authorization", token)
+
+		var item repository.WarehouseItem
+		json.Unmarshal(executeRequest(r).Body.Bytes(), &item)
+
+		return item
+	}
+
+	request := func(method, url string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(method, url, nil)
+		r.Header.Set("Authorization", token)
+
+		if method == "DELETE" {
+			r.Header.Set("If-Match", getETag(strings.Split(url, "/")[2], token))
+		}
+
+		return executeRequest(r)
+	}
+
+	used := create(`{"name": "caderno", "quantity": 50}`)
+	unused := create(`{"name": "borracha"}`)
+
+	t.Run("Should hide archived items from the list", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request("DELETE", "/warehouse/"+used.Id).Code)
+
+		var items []repository.WarehouseItem
+		json.Unmarshal(request("GET", "/warehouse").Body.Bytes(), &items)
+
+		if len(items) != 1 || items[0].Id != unused.Id {
+			t.Errorf("Expected only the active item to be listed, got %v", items)
+		}
+
+		json.Unmarshal(request("GET", "/warehouse?include_archived=true").Body.Bytes(), &items)
+
+		if len(items) != 2 {
+			t.Errorf("Expected 2 items including archived ones, got %d", len(items))
+		}
+
+		checkResponseCode(t, http.StatusNotFound, request("GET", "/warehouse/"+used.Id).Code)
+		checkResponseCode(t, http.StatusOK, request("GET", "/warehouse/"+used.Id+"?include_archived=true").Code)
+	})
+
+	t.Run("Should refuse stock movements on archived items", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/warehouse/"+used.Id+"/adjust", bytes.NewBuffer([]byte(`{"delta": -5, "reason": "lost"}`)))
+		r.Header.Set("Authorization", token)
+
+		checkResponseCode(t, http.StatusNotFound, executeRequest(r).Code)
+	})
+
+	t.Run("Should skip archived items when uploading a CSV", func(t *testing.T) {
+		var body bytes.Buffer
+
+		mw := multipart.NewWriter(&body)
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="csvFile"; filename="items.csv"`)
+		header.Set("Content-Type", "text/csv")
+
+		part, _ := mw.CreatePart(header)
+		part.Write([]byte("name,quantity,min,max\ncaderno,10,0,100\nlápis,20,0,100\n"))
+		mw.Close()
+
+		os.MkdirAll("temp", 0755)
+
+		r, _ := http.NewRequest("POST", "/warehouse/upload", &body)
+		r.Header.Set("Authorization", token)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var result struct {
+			Imported int `json:"imported"`
+			Skipped  []struct {
+				Row  int    `json:"row"`
+				Name string `json:"name"`
+			} `json:"skipped"`
+		}
+
+		json.Unmarshal(response.Body.Bytes(), &result)
+
+		if result.Imported != 1 || len(result.Skipped) != 1 || result.Skipped[0].Row != 0 || result.Skipped[0].Name != "caderno" {
+			t.Errorf("Expected lápis to be imported and caderno skipped, got %+v", result)
+		}
+
+		var item repository.WarehouseItem
+		json.Unmarshal(request("GET", "/warehouse/"+used.Id+"?include_archived=true").Body.Bytes(), &item)
+
+		if item.Quantity != repository.NewDecimal(50) {
+			t.Errorf("Expected the archived item to keep 50, got %s", item.Quantity)
+		}
+	})
+
+	t.Run("Should restore an archived item", func(t *testing.T) {
+		response := request("POST", "/warehouse/"+used.Id+"/restore")
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var item repository.WarehouseItem
+		json.Unmarshal(response.Body.Bytes(), &item)
+
+		if item.ArchivedAt != nil || item.Quantity != repository.NewDecimal(50) {
+			t.Errorf("Expected item to be active with its stock, got archived_at=%v quantity=%s", item.ArchivedAt, item.Quantity)
+		}
+
+		checkResponseCode(t, http.StatusConflict, request("POST", "/warehouse/"+used.Id+"/restore").Code)
+	})
+
+	t.Run("Should only let administrators purge items", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request("DELETE", "/warehouse/"+unused.Id+"/purge").Code)
+
+		s.DB.Exec("UPDATE users SET role = 'admin'")
+
+		checkResponseCode(t, http.StatusConflict, request("DELETE", "/warehouse/"+used.Id+"/purge").Code)
+		checkResponseCode(t, http.StatusNoContent, request("DELETE", "/warehouse/"+unused.Id+"/purge").Code)
+		checkResponseCode(t, http.StatusNotFound, request("GET", "/warehouse/"+unused.Id+"?include_archived=true").Code)
+	})
+}
+
+func TestKits(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	create := func(body string) repository.WarehouseItem {
+		r, _ := http.NewRequest("POST", "/warehouse", bytes.NewBuffer([]byte(body)))
+		r.Header.Set("Authorization", token)
+
+		var item repository.WarehouseItem
+		json.Unmarshal(executeRequest(r).Body.Bytes(), &item)
+
+		return item
 	}
+
+	notebook := create(`{"name": "caderno", "quantity": 10}`)
+	pencil := create(`{"name": "lapis", "quantity": 25}`)
+	kit := create(`{"name": "kit escolar"}`)
+
+	t.Run("Should define a kit and compute its availability", func(t *testing.T) {
+		response := authedRequest(token, "PUT", "/warehouse/"+kit.Id+"/components", fmt.Sprintf(
+			`{"components": [{"item_id": %q, "quantity": 1}, {"item_id": %q, "quantity": 3}]}`, notebook.Id, pencil.Id,
+		))
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var k repository.Kit
+		json.Unmarshal(response.Body.Bytes(), &k)
+
+		if len(k.Components) != 2 || k.Buildable != repository.NewDecimal(8) {
+			t.Errorf("Expected 2 components and 8 buildable kits, got %+v", k)
+		}
+	})
+
+	t.Run("Should refuse a kit as a component", func(t *testing.T) {
+		other := create(`{"name": "kit professor"}`)
+
+		response := authedRequest(token, "PUT", "/warehouse/"+other.Id+"/components", fmt.Sprintf(
+			`{"components": [{"item_id": %q, "quantity": 1}]}`, kit.Id,
+		))
+
+		checkResponseCode(t, http.StatusConflict, response.Code)
+	})
+
+	t.Run("Should assemble kits from components", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/warehouse/"+kit.Id+"/assemble", `{"quantity": 5}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		var res struct {
+			Kit repository.Kit `json:"kit"`
+		}
+		json.Unmarshal(response.Body.Bytes(), &res)
+
+		if res.Kit.Quantity != repository.NewDecimal(5) || res.Kit.Buildable != repository.NewDecimal(3) {
+			t.Errorf("Expected 5 kits and 3 more buildable, got %+v", res.Kit)
+		}
+	})
+
+	t.Run("Should assemble nothing when a component is short", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/warehouse/"+kit.Id+"/assemble", `{"quantity": 4}`).Code)
+
+		var item repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+notebook.Id, "").Body.Bytes(), &item)
+
+		if item.Quantity != repository.NewDecimal(5) {
+			t.Errorf("Expected 5 notebooks left, got %s", item.Quantity)
+		}
+	})
+
+	t.Run("Should return components when disassembling", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/warehouse/"+kit.Id+"/disassemble", `{"quantity": 2}`).Code)
+
+		var item repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+pencil.Id, "").Body.Bytes(), &item)
+
+		if item.Quantity != repository.NewDecimal(16) {
+			t.Errorf("Expected 16 pencils, got %s", item.Quantity)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/warehouse/"+kit.Id+"/disassemble", `{"quantity": 4}`).Code)
+	})
+
+	t.Run("Should only treat items with components as kits", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, authedRequest(token, "GET", "/warehouse/"+pencil.Id+"/components", "").Code)
+		checkResponseCode(t, http.StatusNotFound, authedRequest(token, "POST", "/warehouse/"+pencil.Id+"/assemble", `{"quantity": 1}`).Code)
+
+		var kits []repository.Kit
+		json.Unmarshal(authedRequest(token, "GET", "/kits", "").Body.Bytes(), &kits)
+
+		if len(kits) != 1 || kits[0].ItemId != kit.Id {
+			t.Errorf("Expected only the student kit, got %v", kits)
+		}
+	})
 }
 
-func clearTables() {
-	s.DB.Exec("DELETE FROM users")
+func TestDuplicateItems(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
 
-	s.DB.Exec("DELETE FROM warehouse_items")
+	create := func(body string) repository.WarehouseItem {
+		var item repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "POST", "/warehouse", body).Body.Bytes(), &item)
+
+		return item
+	}
+
+	pencil := create(`{"name": "  Lápis   preto ", "quantity": 10}`)
+	notebook := create(`{"name": "caderno", "quantity": 4}`)
+	notebooks := create(`{"name": "cadernos", "quantity": 6}`)
+
+	t.Run("Should normalize names", func(t *testing.T) {
+		if pencil.Name != "lápis preto" {
+			t.Errorf("Expected name to be lápis preto, got %q", pencil.Name)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/warehouse", `{"name": "LAPIS PRETO"}`).Code)
+	})
+
+	t.Run("Should report likely duplicates", func(t *testing.T) {
+		response := authedRequest(token, "GET", "/warehouse/duplicates", "")
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var candidates []internal.DuplicateCandidate
+		json.Unmarshal(response.Body.Bytes(), &candidates)
+
+		if len(candidates) != 1 || candidates[0].Item.Id != notebook.Id || candidates[0].Duplicate.Id != notebooks.Id {
+			t.Errorf("Expected caderno and cadernos, got %v", candidates)
+		}
+
+		checkResponseCode(t, http.StatusBadRequest, authedRequest(token, "GET", "/warehouse/duplicates?threshold=2", "").Code)
+	})
+
+	merge := fmt.Sprintf(`{"duplicate_ids": [%q]}`, notebooks.Id)
+
+	t.Run("Should only let administrators merge items", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, authedRequest(token, "POST", "/warehouse/"+notebook.Id+"/merge", merge).Code)
+	})
+
+	s.DB.Exec("UPDATE users SET role = 'admin'")
+
+	t.Run("Should merge stock and history into the surviving item", func(t *testing.T) {
+		authedRequest(token, "POST", "/warehouse/"+notebooks.Id+"/barcodes", `{"code": "7891234567895"}`)
+
+		response := authedRequest(token, "POST", "/warehouse/"+notebook.Id+"/merge", merge)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var item repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+notebook.Id, "").Body.Bytes(), &item)
+
+		if item.Quantity != repository.NewDecimal(10) {
+			t.Errorf("Expected 10 notebooks, got %s", item.Quantity)
+		}
+
+		var movements []repository.StockMovement
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+notebook.Id+"/movements", "").Body.Bytes(), &movements)
+
+		if len(movements) != 2 {
+			t.Errorf("Expected both initial receipts, got %v", movements)
+		}
+
+		checkResponseCode(t, http.StatusNotFound, authedRequest(token, "GET", "/warehouse/"+notebooks.Id+"?include_archived=true", "").Code)
+		checkResponseCode(t, http.StatusNotFound, authedRequest(token, "POST", "/warehouse/"+notebook.Id+"/merge", merge).Code)
+	})
+
+	t.Run("Should refuse to merge items kept in different units", func(t *testing.T) {
+		rice := create(`{"name": "arroz", "unit": "kg"}`)
+
+		response := authedRequest(token, "POST", "/warehouse/"+notebook.Id+"/merge", fmt.Sprintf(`{"duplicate_ids": [%q]}`, rice.Id))
+
+		checkResponseCode(t, http.StatusConflict, response.Code)
+	})
+
+	t.Run("Should refuse to merge items with different pack sizes", func(t *testing.T) {
+		boxed := create(`{"name": "caderno brochura", "pack_unit": "cx", "pack_size": 10}`)
+
+		response := authedRequest(token, "POST", "/warehouse/"+notebook.Id+"/merge", fmt.Sprintf(`{"duplicate_ids": [%q]}`, boxed.Id))
+
+		checkResponseCode(t, http.StatusConflict, response.Code)
+	})
+
+	t.Run("Should report items whose keys clashed before they had one", func(t *testing.T) {
+		eraser := create(`{"name": "borracha"}`)
+		clash := create(`{"name": "borracha velha"}`)
+
+		s.DB.Exec("UPDATE warehouse_items SET name = 'Borracha', name_key = 'borracha:' || id WHERE id = $1", clash.Id)
+
+		var candidates []internal.DuplicateCandidate
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/duplicates", "").Body.Bytes(), &candidates)
+
+		found := false
+
+		for _, c := range candidates {
+			if c.Item.Id == clash.Id && c.Duplicate.Id == eraser.Id || c.Item.Id == eraser.Id && c.Duplicate.Id == clash.Id {
+				found = true
+			}
+		}
+
+		if !found {
+			t.Errorf("Expected borracha and Borracha to be reported, got %v", candidates)
+		}
+	})
+}
+
+func TestPointInTimeQueries(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var item repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "caderno", "quantity": 10}`).Body.Bytes(), &item)
+
+	authedRequest(token, "POST", "/warehouse/"+item.Id+"/issues", `{"quantity": 4}`)
+
+	s.DB.Exec("UPDATE warehouse_items SET created_at = '2022-03-01T10:00:00Z'")
+	s.DB.Exec("UPDATE stock_movements SET created_at = '2022-03-01T10:00:00Z' WHERE kind = 'receipt'")
+	s.DB.Exec("UPDATE stock_movements SET created_at = '2022-03-05T10:00:00Z' WHERE kind = 'issue'")
+
+	quantityAsOf := func(t *testing.T, asOf string) repository.Decimal {
+		response := authedRequest(token, "GET", "/warehouse/"+item.Id+"?as_of="+asOf, "")
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var wi repository.WarehouseItem
+		json.Unmarshal(response.Body.Bytes(), &wi)
+
+		return wi.Quantity
+	}
+
+	t.Run("Should reconstruct past quantities", func(t *testing.T) {
+		if q := quantityAsOf(t, "2022-03-01"); q != repository.NewDecimal(10) {
+			t.Errorf("Expected 10 on 1 March, got %s", q)
+		}
+
+		if q := quantityAsOf(t, "2022-03-05T09:00:00Z"); q != repository.NewDecimal(10) {
+			t.Errorf("Expected 10 before the issue, got %s", q)
+		}
+
+		if q := quantityAsOf(t, "2022-03-05"); q != repository.NewDecimal(6) {
+			t.Errorf("Expected 6 after the issue, got %s", q)
+		}
+
+		checkResponseCode(t, http.StatusNotFound, authedRequest(token, "GET", "/warehouse/"+item.Id+"?as_of=2022-02-28", "").Code)
+		checkResponseCode(t, http.StatusBadRequest, authedRequest(token, "GET", "/warehouse/"+item.Id+"?as_of=someday", "").Code)
+	})
+
+	t.Run("Should give the same answer from snapshots", func(t *testing.T) {
+		for _, day := range []int{3, 4, 6} {
+			if _, err := repository.TakeStockSnapshots(s.DB, time.Date(2022, time.March, day, 0, 0, 0, 0, time.UTC)); err != nil {
+				t.Fatalf("Expected no error, got %q", err.Error())
+			}
+		}
+
+		if q := quantityAsOf(t, "2022-03-02"); q != repository.NewDecimal(10) {
+			t.Errorf("Expected 10 on 2 March, got %s", q)
+		}
+
+		if q := quantityAsOf(t, "2022-03-05"); q != repository.NewDecimal(6) {
+			t.Errorf("Expected 6 on 5 March, got %s", q)
+		}
+	})
+
+	t.Run("Should list and filter past quantities", func(t *testing.T) {
+		response := authedRequest(token, "GET", "/warehouse?as_of=2022-03-02&min_quantity=8", "")
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var items []repository.WarehouseItem
+		json.Unmarshal(response.Body.Bytes(), &items)
+
+		if len(items) != 1 || items[0].Quantity != repository.NewDecimal(10) {
+			t.Errorf("Expected caderno with 10, got %v", items)
+		}
+	})
+
+	t.Run("Should backfill creation dates from history, not from the migration", func(t *testing.T) {
+		migration, err := os.ReadFile("../migrations/000018_create_stock_snapshots_table.up.sql")
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		backfill := ""
+
+		for _, stmt := range strings.Split(string(migration), ";") {
+			if strings.Contains(stmt, "UPDATE warehouse_items SET created_at") {
+				backfill = stmt
+			}
+		}
+
+		var eraser repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "borracha"}`).Body.Bytes(), &eraser)
+
+		if _, err = s.DB.Exec(backfill); err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		checkResponseCode(t, http.StatusOK, authedRequest(token, "GET", "/warehouse/"+eraser.Id+"?as_of=2022-01-01", "").Code)
+
+		if q := quantityAsOf(t, "2022-03-01"); q != repository.NewDecimal(10) {
+			t.Errorf("Expected 10 on 1 March, got %s", q)
+		}
+
+		checkResponseCode(t, http.StatusNotFound, authedRequest(token, "GET", "/warehouse/"+item.Id+"?as_of=2022-02-28", "").Code)
+	})
+}
+
+func TestAssets(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var school repository.School
+	json.Unmarshal(authedRequest(token, "POST", "/school", `{"name": "Escola Municipal"}`).Body.Bytes(), &school)
+
+	var laptop repository.Asset
+
+	t.Run("Should register assets by serial number", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/assets", `{"serial_number": "SN-001", "kind": "laptop", "model": "X1"}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &laptop)
+
+		if laptop.Condition != repository.AssetGood || laptop.SchoolId != nil {
+			t.Errorf("Expected a good asset in the warehouse, got %v", laptop)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/assets", `{"serial_number": "SN-001", "kind": "laptop"}`).Code)
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/assets", `{"kind": "laptop"}`).Code)
+	})
+
+	t.Run("Should check assets out to schools", func(t *testing.T) {
+		body := fmt.Sprintf(`{"school_id": %q, "due_at": "2000-01-01"}`, school.Id)
+		response := authedRequest(token, "POST", "/assets/"+laptop.Id+"/checkout", body)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var a repository.Asset
+		json.Unmarshal(response.Body.Bytes(), &a)
+
+		if a.SchoolId == nil || *a.SchoolId != school.Id || !a.Overdue {
+			t.Errorf("Expected asset to be overdue at the school, got %v", a)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/assets/"+laptop.Id+"/checkout", body).Code)
+
+		var overdue []repository.Asset
+		json.Unmarshal(authedRequest(token, "GET", "/assets?status=overdue", "").Body.Bytes(), &overdue)
+
+		if len(overdue) != 1 || overdue[0].Id != laptop.Id {
+			t.Errorf("Expected the laptop to be overdue, got %v", overdue)
+		}
+	})
+
+	t.Run("Should check assets back in with their condition", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/assets/"+laptop.Id+"/checkin", `{"condition": "broken"}`)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var a repository.Asset
+		json.Unmarshal(response.Body.Bytes(), &a)
+
+		if a.SchoolId != nil || a.Condition != repository.AssetBroken {
+			t.Errorf("Expected a broken asset in the warehouse, got %v", a)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/assets/"+laptop.Id+"/checkin", `{}`).Code)
+
+		body := fmt.Sprintf(`{"school_id": %q}`, school.Id)
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/assets/"+laptop.Id+"/checkout", body).Code)
+	})
+
+	t.Run("Should keep the asset's history", func(t *testing.T) {
+		var events []repository.AssetEvent
+		json.Unmarshal(authedRequest(token, "GET", "/assets/"+laptop.Id+"/history", "").Body.Bytes(), &events)
+
+		kinds := []string{}
+
+		for _, e := range events {
+			kinds = append(kinds, e.Kind)
+		}
+
+		if fmt.Sprint(kinds) != "[registered checked_out checked_in]" {
+			t.Errorf("Expected registered, checked_out and checked_in events, got %v", kinds)
+		}
+
+		if events[2].SchoolId == nil || *events[2].SchoolId != school.Id {
+			t.Errorf("Expected check in to name the school, got %v", events[2])
+		}
+	})
+
+	t.Run("Should only let administrators retire assets", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, authedRequest(token, "DELETE", "/assets/"+laptop.Id, "").Code)
+
+		s.DB.Exec("UPDATE users SET role = 'admin'")
+
+		checkResponseCode(t, http.StatusNoContent, authedRequest(token, "DELETE", "/assets/"+laptop.Id, "").Code)
+
+		var assets []repository.Asset
+		json.Unmarshal(authedRequest(token, "GET", "/assets", "").Body.Bytes(), &assets)
+
+		if len(assets) != 0 {
+			t.Errorf("Expected retired assets to be hidden, got %v", assets)
+		}
+	})
+}
+
+func TestMaintenanceTickets(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var school repository.School
+	json.Unmarshal(authedRequest(token, "POST", "/school", `{"name": "Escola Estadual"}`).Body.Bytes(), &school)
+
+	var projector repository.Asset
+	json.Unmarshal(authedRequest(token, "POST", "/assets", `{"serial_number": "PJ-01", "kind": "projector"}`).Body.Bytes(), &projector)
+
+	authedRequest(token, "POST", "/assets/"+projector.Id+"/checkout", fmt.Sprintf(`{"school_id": %q}`, school.Id))
+
+	var ticket repository.MaintenanceTicket
+
+	t.Run("Should open tickets under the school holding the asset", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/assets/"+projector.Id+"/tickets", `{"description": "Lamp is out"}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &ticket)
+
+		if ticket.Status != repository.TicketOpen || ticket.SchoolId == nil || *ticket.SchoolId != school.Id {
+			t.Errorf("Expected an open ticket for the school, got %v", ticket)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/assets/"+projector.Id+"/tickets", `{"description": "Again"}`).Code)
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/assets/"+projector.Id+"/tickets", `{}`).Code)
+	})
+
+	t.Run("Should mark the asset unavailable while under repair", func(t *testing.T) {
+		authedRequest(token, "POST", "/assets/"+projector.Id+"/checkin", `{}`)
+
+		var a repository.Asset
+		json.Unmarshal(authedRequest(token, "GET", "/assets/"+projector.Id, "").Body.Bytes(), &a)
+
+		if !a.UnderRepair {
+			t.Errorf("Expected asset to be under repair, got %v", a)
+		}
+
+		body := fmt.Sprintf(`{"school_id": %q}`, school.Id)
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/assets/"+projector.Id+"/checkout", body).Code)
+	})
+
+	t.Run("Should only let administrators assign technicians", func(t *testing.T) {
+		var userId string
+		s.DB.QueryRow("SELECT id FROM users LIMIT 1").Scan(&userId)
+
+		body := fmt.Sprintf(`{"technician_id": %q}`, userId)
+
+		checkResponseCode(t, http.StatusForbidden, authedRequest(token, "PUT", "/tickets/"+ticket.Id+"/technician", body).Code)
+
+		s.DB.Exec("UPDATE users SET role = 'admin'")
+
+		response := authedRequest(token, "PUT", "/tickets/"+ticket.Id+"/technician", body)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var tk repository.MaintenanceTicket
+		json.Unmarshal(response.Body.Bytes(), &tk)
+
+		if tk.TechnicianId == nil || *tk.TechnicianId != userId {
+			t.Errorf("Expected technician to be assigned, got %v", tk)
+		}
+	})
+
+	t.Run("Should move tickets through their statuses", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, authedRequest(token, "POST", "/tickets/"+ticket.Id+"/status", `{"status": "in_progress"}`).Code)
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/tickets/"+ticket.Id+"/notes", `{"body": "Lamp ordered"}`).Code)
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/tickets/"+ticket.Id+"/status", `{"status": "fixed"}`).Code)
+
+		response := authedRequest(token, "POST", "/tickets/"+ticket.Id+"/status", `{"status": "resolved", "condition": "good", "note": "Lamp replaced"}`)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var tk repository.MaintenanceTicket
+		json.Unmarshal(response.Body.Bytes(), &tk)
+
+		if tk.ClosedAt == nil || len(tk.Notes) != 3 {
+			t.Errorf("Expected a closed ticket with 3 notes, got %v", tk)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/tickets/"+ticket.Id+"/status", `{"status": "open"}`).Code)
+	})
+
+	t.Run("Should make the asset available again once resolved", func(t *testing.T) {
+		var a repository.Asset
+		json.Unmarshal(authedRequest(token, "GET", "/assets/"+projector.Id, "").Body.Bytes(), &a)
+
+		if a.UnderRepair || a.Condition != repository.AssetGood {
+			t.Errorf("Expected a good asset out of repair, got %v", a)
+		}
+
+		var events []repository.AssetEvent
+		json.Unmarshal(authedRequest(token, "GET", "/assets/"+projector.Id+"/history", "").Body.Bytes(), &events)
+
+		if last := events[len(events)-1]; last.Kind != repository.AssetRepairClosed {
+			t.Errorf("Expected repair to be closed in the history, got %v", last)
+		}
+	})
+}
+
+func TestProductVariants(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var legacy repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "camiseta p", "quantity": 4, "min": 5}`).Body.Bytes(), &legacy)
+
+	var shirt repository.Product
+
+	t.Run("Should create products with variant attributes", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/products", `{"name": "Camiseta", "attributes": ["Size", "color"]}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &shirt)
+
+		if shirt.Name != "camiseta" || fmt.Sprint(shirt.Attributes) != "[size color]" {
+			t.Errorf("Expected camiseta with size and color, got %v", shirt)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/products", `{"name": "camiseta", "attributes": ["size"]}`).Code)
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/products", `{"name": "bermuda"}`).Code)
+	})
+
+	variants := "/products/" + shirt.Id + "/variants"
+
+	t.Run("Should create new variants named after the product", func(t *testing.T) {
+		response := authedRequest(token, "POST", variants, `{"variant": {"size": "M", "color": "Azul"}, "quantity": 10}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		var p repository.Product
+		json.Unmarshal(response.Body.Bytes(), &p)
+
+		if len(p.Variants) != 1 || p.Variants[0].Name != "camiseta m azul" || p.Variants[0].Variant["size"] != "m" {
+			t.Errorf("Expected variant camiseta m azul, got %v", p.Variants)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", variants, `{"variant": {"size": "m", "color": "azul"}, "name": "outra"}`).Code)
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", variants, `{"variant": {"size": "g"}}`).Code)
+	})
+
+	t.Run("Should link existing items as variants", func(t *testing.T) {
+		body := fmt.Sprintf(`{"item_id": %q, "variant": {"size": "p", "color": "azul"}}`, legacy.Id)
+		response := authedRequest(token, "POST", variants, body)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var items []repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse?product_id="+shirt.Id, "").Body.Bytes(), &items)
+
+		if len(items) != 2 {
+			t.Errorf("Expected 2 variants, got %v", items)
+		}
+	})
+
+	t.Run("Should not change product or variant through a patch", func(t *testing.T) {
+		for _, patch := range []string{`{"product_id": null}`, `{"variant": {"size": "g", "color": "azul"}}`} {
+			r, _ := http.NewRequest("PATCH", "/warehouse/"+legacy.Id, bytes.NewBuffer([]byte(patch)))
+			r.Header.Set("Authorization", token)
+			r.Header.Set("If-Match", getETag(legacy.Id, token))
+
+			checkResponseCode(t, http.StatusUnprocessableEntity, executeRequest(r).Code)
+		}
+	})
+
+	t.Run("Should roll stock up to the product", func(t *testing.T) {
+		var products []repository.Product
+		json.Unmarshal(authedRequest(token, "GET", "/products", "").Body.Bytes(), &products)
+
+		if len(products) != 1 {
+			t.Fatalf("Expected 1 product, got %v", products)
+		}
+
+		p := products[0]
+
+		if p.VariantCount != 2 || p.Quantity != repository.NewDecimal(14) || p.BelowMin != 1 {
+			t.Errorf("Expected 2 variants with 14 in stock and 1 below min, got %v", p)
+		}
+	})
+
+	t.Run("Should detach variants", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, authedRequest(token, "DELETE", variants+"/"+legacy.Id, "").Code)
+		checkResponseCode(t, http.StatusNotFound, authedRequest(token, "DELETE", variants+"/"+legacy.Id, "").Code)
+
+		var item repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+legacy.Id, "").Body.Bytes(), &item)
+
+		if item.ProductId != nil || item.Quantity != repository.NewDecimal(4) {
+			t.Errorf("Expected a standalone item with its stock, got %v", item)
+		}
+	})
+}
+
+func TestStudentHandouts(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	upload := func(url, roster string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+
+		mw := multipart.NewWriter(&body)
+
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="csvFile"; filename="roster.csv"`)
+		header.Set("Content-Type", "text/csv")
+
+		part, _ := mw.CreatePart(header)
+		part.Write([]byte(roster))
+		mw.Close()
+
+		r, _ := http.NewRequest("POST", url, &body)
+		r.Header.Set("Authorization", token)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+
+		return executeRequest(r)
+	}
+
+	os.MkdirAll("temp", 0755)
+
+	var school repository.School
+	json.Unmarshal(authedRequest(token, "POST", "/school", `{"name": "Escola Rural"}`).Body.Bytes(), &school)
+
+	var kit repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "kit escolar", "quantity": 10}`).Body.Bytes(), &kit)
+
+	roster := "/schools/" + school.Id + "/students"
+
+	t.Run("Should import the school's roster", func(t *testing.T) {
+		response := upload(roster+"/upload", "enrollment_id,name,grade\n1,Ana Souza,1º ano\n2,Bia Lima,1º ano\n3,Caio Reis,2º ano\n")
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var result repository.RosterImport
+		json.Unmarshal(response.Body.Bytes(), &result)
+
+		if result.Created != 3 {
+			t.Errorf("Expected 3 students to be created, got %v", result)
+		}
+
+		response = upload(roster+"/upload?replace=true", "enrollment_id,name,grade\n1,Ana Souza,1º ano\n2,Bia Lima,1º ano\n")
+
+		json.Unmarshal(response.Body.Bytes(), &result)
+
+		if result.Updated != 2 || result.Deactivated != 1 {
+			t.Errorf("Expected 2 updated and 1 deactivated student, got %v", result)
+		}
+
+		checkResponseCode(t, http.StatusUnprocessableEntity, upload(roster+"/upload", "enrollment_id,name\n4,Davi\n4,Duda\n").Code)
+	})
+
+	var students []repository.Student
+	json.Unmarshal(authedRequest(token, "GET", roster, "").Body.Bytes(), &students)
+
+	if len(students) != 2 {
+		t.Fatalf("Expected 2 enrolled students, got %v", students)
+	}
+
+	t.Run("Should only let administrators create entitlements", func(t *testing.T) {
+		body := fmt.Sprintf(`{"name": "kit anual", "item_id": %q, "quantity": 1, "period": "year"}`, kit.Id)
+
+		checkResponseCode(t, http.StatusForbidden, authedRequest(token, "POST", "/entitlements", body).Code)
+
+		s.DB.Exec("UPDATE users SET role = 'admin'")
+
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/entitlements", body).Code)
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/entitlements", `{"name": "nada", "quantity": 1, "period": "year"}`).Code)
+	})
+
+	t.Run("Should record handouts", func(t *testing.T) {
+		body := fmt.Sprintf(`{"item_id": %q, "quantity": 1}`, kit.Id)
+
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/students/"+students[0].Id+"/handouts", body).Code)
+
+		var handouts []repository.Handout
+		json.Unmarshal(authedRequest(token, "GET", "/students/"+students[0].Id+"/handouts", "").Body.Bytes(), &handouts)
+
+		if len(handouts) != 1 || handouts[0].ItemName != "kit escolar" {
+			t.Errorf("Expected 1 kit handed out, got %v", handouts)
+		}
+
+		var caio string
+		s.DB.QueryRow("SELECT id FROM students WHERE NOT active").Scan(&caio)
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/students/"+caio+"/handouts", body).Code)
+	})
+
+	t.Run("Should report who is still missing items", func(t *testing.T) {
+		response := authedRequest(token, "GET", "/schools/"+school.Id+"/entitlements/report", "")
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var report internal.EntitlementReport
+		json.Unmarshal(response.Body.Bytes(), &report)
+
+		if len(report.Entitlements) != 1 {
+			t.Fatalf("Expected 1 entitlement, got %v", report.Entitlements)
+		}
+
+		e := report.Entitlements[0]
+
+		if e.Students != 2 || e.Fulfilled != 1 || len(e.Missing) != 1 || e.Missing[0].StudentId != students[1].Id {
+			t.Errorf("Expected %s to be missing the kit, got %v", students[1].Name, e)
+		}
+
+		var last internal.EntitlementReport
+		json.Unmarshal(authedRequest(token, "GET", "/schools/"+school.Id+"/entitlements/report?year=2000", "").Body.Bytes(), &last)
+
+		if len(last.Entitlements) != 1 || last.Entitlements[0].Fulfilled != 0 {
+			t.Errorf("Expected nobody to have received a kit in 2000, got %v", last.Entitlements)
+		}
+	})
+}
+
+func TestAllocationPlanner(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var big, small repository.School
+	json.Unmarshal(authedRequest(token, "POST", "/school", `{"name": "Escola Grande", "enrollment": 300}`).Body.Bytes(), &big)
+	json.Unmarshal(authedRequest(token, "POST", "/school", `{"name": "Escola Pequena", "enrollment": 100}`).Body.Bytes(), &small)
+
+	var item repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "caderno", "quantity": 125, "pack_unit": "cx", "pack_size": 10}`).Body.Bytes(), &item)
+
+	t.Run("Should update a school's enrollment", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "PUT", "/schools/"+small.Id, `{"name": "Escola Pequena", "enrollment": -1}`).Code)
+		checkResponseCode(t, http.StatusOK, authedRequest(token, "PUT", "/schools/"+small.Id, `{"name": "Escola Pequena", "enrollment": 100}`).Code)
+	})
+
+	t.Run("Should plan in proportion to enrollment and round to packs", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/allocations/plan", fmt.Sprintf(`{"item_id": %q, "round_to_packs": true}`, item.Id))
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var plan internal.AllocationPlan
+		json.Unmarshal(response.Body.Bytes(), &plan)
+
+		if len(plan.Lines) != 2 || plan.Lines[0].SchoolId != big.Id || plan.Lines[0].Quantity != repository.NewDecimal(90) ||
+			plan.Lines[1].Quantity != repository.NewDecimal(30) || plan.Remaining != repository.NewDecimal(5) {
+			t.Errorf("Expected 90 and 30 with 5 remaining, got %+v", plan)
+		}
+	})
+
+	var shipments []repository.Shipment
+
+	t.Run("Should turn the plan into draft shipments", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/allocations/shipments", fmt.Sprintf(`{"item_id": %q, "quantity": 40, "minimum": 15}`, item.Id))
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &shipments)
+
+		if len(shipments) != 2 || shipments[0].Status != repository.ShipmentDraft || len(shipments[0].Lines) != 1 {
+			t.Fatalf("Expected 2 draft shipments, got %v", shipments)
+		}
+
+		var wi repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+item.Id, "").Body.Bytes(), &wi)
+
+		if wi.Available != repository.NewDecimal(85) {
+			t.Errorf("Expected drafts to reserve 40, got %s available", wi.Available)
+		}
+	})
+
+	if len(shipments) != 2 {
+		t.FailNow()
+	}
+
+	t.Run("Should dispatch a draft", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/shipments/"+shipments[0].Id+"/dispatch", "")
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/shipments/"+shipments[0].Id+"/dispatch", "").Code)
+
+		var wi repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+item.Id, "").Body.Bytes(), &wi)
+
+		if wi.Quantity != repository.NewDecimal(125).Sub(shipments[0].Lines[0].Quantity) {
+			t.Errorf("Expected the shipment to be issued, got %s in stock", wi.Quantity)
+		}
+	})
+
+	t.Run("Should cancel a draft", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, authedRequest(token, "DELETE", "/shipments/"+shipments[1].Id, "").Code)
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "DELETE", "/shipments/"+shipments[1].Id, "").Code)
+
+		var list []repository.Shipment
+		json.Unmarshal(authedRequest(token, "GET", "/shipments?status=cancelled", "").Body.Bytes(), &list)
+
+		if len(list) != 1 || list[0].Id != shipments[1].Id {
+			t.Errorf("Expected the cancelled shipment to be listed, got %v", list)
+		}
+	})
+}
+
+func TestSemesterAllotments(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var first, second repository.School
+	json.Unmarshal(authedRequest(token, "POST", "/school", `{"name": "Escola A", "enrollment": 20}`).Body.Bytes(), &first)
+	json.Unmarshal(authedRequest(token, "POST", "/school", `{"name": "Escola B", "enrollment": 10}`).Body.Bytes(), &second)
+
+	var item repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "caderno", "quantity": 100}`).Body.Bytes(), &item)
+
+	t.Run("Should only let administrators create allotments", func(t *testing.T) {
+		body := fmt.Sprintf(`{"name": "cadernos", "item_id": %q, "quantity": 2, "period": "semester"}`, item.Id)
+
+		checkResponseCode(t, http.StatusForbidden, authedRequest(token, "POST", "/allotments", body).Code)
+
+		s.DB.Exec("UPDATE users SET role = 'admin'")
+
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/allotments", body).Code)
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/allotments", `{"name": "nada", "quantity": 1, "period": "month"}`).Code)
+	})
+
+	t.Run("Should not send archived items", func(t *testing.T) {
+		var eraser repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "borracha", "quantity": 100}`).Body.Bytes(), &eraser)
+
+		body := fmt.Sprintf(`{"name": "borrachas", "item_id": %q, "quantity": 1, "period": "semester"}`, eraser.Id)
+
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/allotments", body).Code)
+
+		r, _ := http.NewRequest("DELETE", "/warehouse/"+eraser.Id, nil)
+		r.Header.Set("Authorization", token)
+		r.Header.Set("If-Match", getETag(eraser.Id, token))
+
+		checkResponseCode(t, http.StatusNoContent, executeRequest(r).Code)
+
+		var allotments []repository.Allotment
+		json.Unmarshal(authedRequest(token, "GET", "/allotments", "").Body.Bytes(), &allotments)
+
+		if len(allotments) != 2 || !allotments[0].Archived {
+			t.Errorf("Expected the eraser allotment to be kept as archived, got %v", allotments)
+		}
+	})
+
+	t.Run("Should preview the semester", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/allotments/preview", `{"period": "2026"}`).Code)
+
+		response := authedRequest(token, "POST", "/allotments/preview", `{"period": "2026-S1"}`)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var plan internal.AllotmentPlan
+		json.Unmarshal(response.Body.Bytes(), &plan)
+
+		if len(plan.Schools) != 2 || len(plan.Items) != 1 || plan.Items[0].Required != repository.NewDecimal(60) {
+			t.Errorf("Expected 60 notebooks for 2 schools, got %+v", plan)
+		}
+	})
+
+	t.Run("Should generate draft shipments once per period", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/allotments/generate", `{"period": "2026-S1"}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		var result allotmentRunResult
+		json.Unmarshal(response.Body.Bytes(), &result)
+
+		if len(result.Created) != 2 || len(result.Skipped) != 0 {
+			t.Fatalf("Expected 2 shipments to be created, got %v", result)
+		}
+
+		checkResponseCode(t, http.StatusNoContent, authedRequest(token, "DELETE", "/shipments/"+result.Created[0].Id, "").Code)
+
+		response = authedRequest(token, "POST", "/allotments/generate", `{"period": "2026-S1"}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &result)
+
+		if len(result.Created) != 1 || len(result.Skipped) != 1 {
+			t.Errorf("Expected the cancelled school to be served again, got %v", result)
+		}
+
+		var list []repository.Shipment
+		json.Unmarshal(authedRequest(token, "GET", "/shipments?period=2026-S1&status=draft", "").Body.Bytes(), &list)
+
+		if len(list) != 2 {
+			t.Errorf("Expected 2 draft shipments for the period, got %v", list)
+		}
+	})
+}
+
+func TestWarehouseBatch(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	type batchResponse struct {
+		Results []struct {
+			Op      string `json:"op"`
+			Status  int    `json:"status"`
+			Id      string `json:"id"`
+			Version int32  `json:"version"`
+		} `json:"results"`
+	}
+
+	batch := func(body string) (*httptest.ResponseRecorder, batchResponse) {
+		r, _ := http.NewRequest("POST", "/warehouse/batch", bytes.NewBuffer([]byte(body)))
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		var res batchResponse
+		json.Unmarshal(response.Body.Bytes(), &res)
+
+		return response, res
+	}
+
+	countItems := func() int {
+		r, _ := http.NewRequest("GET", "/warehouse", nil)
+		r.Header.Set("Authorization", token)
+
+		var items []repository.WarehouseItem
+		json.Unmarshal(executeRequest(r).Body.Bytes(), &items)
+
+		return len(items)
+	}
+
+	var created batchResponse
+
+	t.Run("Should run every operation in one transaction", func(t *testing.T) {
+		var response *httptest.ResponseRecorder
+
+		response, created = batch(`{"operations": [
+			{"op": "create", "item": {"name": "caderno", "quantity": 10}},
+			{"op": "create", "item": {"name": "lapis"}}
+		]}`)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		if len(created.Results) != 2 || created.Results[0].Status != http.StatusCreated || created.Results[1].Id == "" {
+			t.Fatalf("Expected two created items, got %+v", created.Results)
+		}
+	})
+
+	t.Run("Should roll back everything when an operation fails", func(t *testing.T) {
+		response, res := batch(fmt.Sprintf(`{"operations": [
+			{"op": "update", "id": %q, "version": %d, "item": {"name": "caderno brochura"}},
+			{"op": "create", "item": {"name": "lapis"}},
+			{"op": "delete", "id": %q, "version": %d}
+		]}`, created.Results[0].Id, created.Results[0].Version, created.Results[1].Id, created.Results[1].Version))
+
+		checkResponseCode(t, http.StatusConflict, response.Code)
+
+		statuses := []int{res.Results[0].Status, res.Results[1].Status, res.Results[2].Status}
+
+		if statuses[0] != http.StatusFailedDependency || statuses[1] != http.StatusConflict || statuses[2] != http.StatusFailedDependency {
+			t.Errorf("Expected statuses 424, 409 and 424, got %v", statuses)
+		}
+
+		if countItems() != 2 {
+			t.Errorf("Expected the delete to be rolled back")
+		}
+	})
+
+	t.Run("Should keep the successful operations when continuing on error", func(t *testing.T) {
+		response, res := batch(fmt.Sprintf(`{"continue_on_error": true, "operations": [
+			{"op": "create", "item": {"name": "lapis"}},
+			{"op": "update", "id": %q, "version": 99, "item": {"min": 5}},
+			{"op": "delete", "id": %q, "version": %d},
+			{"op": "create", "item": {"name": ""}}
+		]}`, created.Results[0].Id, created.Results[1].Id, created.Results[1].Version))
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		statuses := []int{res.Results[0].Status, res.Results[1].Status, res.Results[2].Status, res.Results[3].Status}
+
+		if statuses[0] != http.StatusConflict || statuses[1] != http.StatusPreconditionFailed ||
+			statuses[2] != http.StatusNoContent || statuses[3] != http.StatusUnprocessableEntity {
+			t.Errorf("Expected statuses 409, 412, 204 and 422, got %v", statuses)
+		}
+
+		if countItems() != 1 {
+			t.Errorf("Expected the delete to be kept")
+		}
+	})
+
+	t.Run("Should refuse an empty batch", func(t *testing.T) {
+		response, _ := batch(`{"operations": []}`)
+
+		checkResponseCode(t, http.StatusUnprocessableEntity, response.Code)
+	})
+}
+
+func TestStocktakes(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var counted, uncounted repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "caderno", "quantity": 10}`).Body.Bytes(), &counted)
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "lápis", "quantity": 5}`).Body.Bytes(), &uncounted)
+
+	var st repository.Stocktake
+
+	t.Run("Should snapshot items and hide expected quantities on blind counts", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/stocktakes", `{"blind": true}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &st)
+
+		if len(st.Lines) != 2 {
+			t.Fatalf("Expected 2 lines, got %d", len(st.Lines))
+		}
+
+		if st.Lines[0].Expected != nil {
+			t.Errorf("Expected blind stocktake to hide expected quantities, got %s", st.Lines[0].Expected)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/stocktakes", `{}`).Code)
+	})
+
+	t.Run("Should lock counted items against stock changes", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/warehouse/"+counted.Id+"/receipts", `{"quantity": 1}`).Code)
+	})
+
+	t.Run("Should record counts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, authedRequest(token, "POST", "/stocktakes/"+st.Id+"/counts", `{"item_id": "`+counted.Id+`", "quantity": 9}`).Code)
+		checkResponseCode(t, http.StatusNoContent, authedRequest(token, "POST", "/stocktakes/"+st.Id+"/counts", `{"item_id": "`+counted.Id+`", "quantity": 8}`).Code)
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/stocktakes/"+st.Id+"/counts", `{"item_id": "`+counted.Id+`", "quantity": -1}`).Code)
+	})
+
+	t.Run("Should only let administrators approve", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, authedRequest(token, "POST", "/stocktakes/"+st.Id+"/approve", "").Code)
+	})
+
+	s.DB.Exec("UPDATE users SET role = 'admin'")
+
+	t.Run("Should show variances", func(t *testing.T) {
+		json.Unmarshal(authedRequest(token, "GET", "/stocktakes/"+st.Id, "").Body.Bytes(), &st)
+
+		l := st.Lines[0]
+
+		if l.ItemId != counted.Id || l.Variance == nil || *l.Variance != repository.NewDecimal(-2) {
+			t.Errorf("Expected a variance of -2 on %s, got %v", counted.Id, l.Variance)
+		}
+
+		if len(l.Counts) != 1 {
+			t.Errorf("Expected recounting to replace the previous count, got %d counts", len(l.Counts))
+		}
+	})
+
+	t.Run("Should refuse approval while counts are disputed", func(t *testing.T) {
+		var other string
+		s.DB.QueryRow("INSERT INTO users (email, username, password) VALUES ('other@example.com', 'other', 'x') RETURNING id").Scan(&other)
+		s.DB.Exec("INSERT INTO stocktake_counts (stocktake_id, item_id, counted_by, quantity) VALUES ($1, $2, $3, 7)", st.Id, counted.Id, other)
+
+		response := authedRequest(token, "POST", "/stocktakes/"+st.Id+"/approve", "")
+
+		checkResponseCode(t, http.StatusConflict, response.Code)
+
+		var body struct {
+			ItemIds []string `json:"item_ids"`
+		}
+
+		json.Unmarshal(response.Body.Bytes(), &body)
+
+		if len(body.ItemIds) != 1 || body.ItemIds[0] != counted.Id {
+			t.Errorf("Expected %s to be disputed, got %v", counted.Id, body.ItemIds)
+		}
+
+		s.DB.Exec("UPDATE stocktake_counts SET quantity = 8 WHERE counted_by = $1", other)
+	})
+
+	t.Run("Should post adjustments on approval", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, authedRequest(token, "POST", "/stocktakes/"+st.Id+"/approve", "").Code)
+
+		var item repository.WarehouseItem
+
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+counted.Id, "").Body.Bytes(), &item)
+
+		if item.Quantity != repository.NewDecimal(8) {
+			t.Errorf("Expected counted item quantity to be 8, got %s", item.Quantity)
+		}
+
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+uncounted.Id, "").Body.Bytes(), &item)
+
+		if item.Quantity != repository.NewDecimal(5) {
+			t.Errorf("Expected uncounted item quantity to stay 5, got %s", item.Quantity)
+		}
+
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/warehouse/"+counted.Id+"/receipts", `{"quantity": 1}`).Code)
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/stocktakes/"+st.Id+"/counts", `{"item_id": "`+counted.Id+`", "quantity": 1}`).Code)
+	})
+}
+
+func TestReservations(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var item repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "caderno", "quantity": 10}`).Body.Bytes(), &item)
+
+	reserve := func(quantity string) *httptest.ResponseRecorder {
+		return authedRequest(token, "POST", "/reservations",
+			`{"item_id": "`+item.Id+`", "document_type": "requisition", "document_id": "42", "quantity": `+quantity+`}`)
+	}
+
+	var res repository.Reservation
+
+	t.Run("Should reserve available stock", func(t *testing.T) {
+		response := reserve("6")
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &res)
+
+		checkResponseCode(t, http.StatusConflict, reserve("5").Code)
+
+		var wi repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+item.Id, "").Body.Bytes(), &wi)
+
+		if wi.OnHand != repository.NewDecimal(10) || wi.Reserved != repository.NewDecimal(6) || wi.Available != repository.NewDecimal(4) {
+			t.Errorf("Expected on_hand=10 reserved=6 available=4, got %s %s %s", wi.OnHand, wi.Reserved, wi.Available)
+		}
+	})
+
+	t.Run("Should not let issues consume reserved stock", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/warehouse/"+item.Id+"/issues", `{"quantity": 5}`).Code)
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/warehouse/"+item.Id+"/issues", `{"quantity": 4}`).Code)
+	})
+
+	t.Run("Should convert a reservation into an issue", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/reservations/"+res.Id+"/fulfill", `{"quantity": 7}`).Code)
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/reservations/"+res.Id+"/fulfill", `{"quantity": 5}`).Code)
+
+		var wi repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+item.Id, "").Body.Bytes(), &wi)
+
+		if wi.OnHand != repository.NewDecimal(1) || wi.Available != repository.NewDecimal(1) {
+			t.Errorf("Expected the remainder to be released, got on_hand=%s available=%s", wi.OnHand, wi.Available)
+		}
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "DELETE", "/reservations/"+res.Id, "").Code)
+	})
+
+	t.Run("Should stop counting expired reservations", func(t *testing.T) {
+		reserve("1")
+		s.DB.Exec("UPDATE reservations SET expires_at = NOW() - INTERVAL '1 minute' WHERE status = 'active'")
+
+		var reservations []repository.Reservation
+		json.Unmarshal(authedRequest(token, "GET", "/reservations?document_id=42", "").Body.Bytes(), &reservations)
+
+		if len(reservations) != 2 || reservations[1].Status != repository.ReservationExpired {
+			t.Errorf("Expected the second reservation to be expired, got %v", reservations)
+		}
+
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/warehouse/"+item.Id+"/issues", `{"quantity": 1}`).Code)
+	})
+
+	t.Run("Should change the item's ETag when its reservations change", func(t *testing.T) {
+		notModified := func(etag string) bool {
+			r, _ := http.NewRequest("GET", "/warehouse/"+item.Id, nil)
+			r.Header.Set("Authorization", token)
+			r.Header.Set("If-None-Match", etag)
+
+			return executeRequest(r).Code == http.StatusNotModified
+		}
+
+		authedRequest(token, "POST", "/warehouse/"+item.Id+"/receipts", `{"quantity": 2}`)
+
+		etag := getETag(item.Id, token)
+		reserve("1")
+
+		if notModified(etag) {
+			t.Error("Expected a new reservation to change the ETag")
+		}
+
+		etag = getETag(item.Id, token)
+		s.DB.Exec("UPDATE reservations SET expires_at = NOW() - INTERVAL '1 minute' WHERE status = 'active'")
+
+		if notModified(etag) {
+			t.Error("Expected an expired reservation to change the ETag")
+		}
+	})
+}
+
+func TestPurchaseOrders(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var item repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "caderno", "pack_unit": "cx", "pack_size": 10}`).Body.Bytes(), &item)
+
+	var supplier repository.Supplier
+
+	t.Run("Should create a supplier", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/suppliers", `{"name": "Papelaria Central", "email": "vendas@example.com"}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &supplier)
+
+		checkResponseCode(t, http.StatusConflict, authedRequest(token, "POST", "/suppliers", `{"name": "Papelaria Central"}`).Code)
+	})
+
+	var po repository.PurchaseOrder
+
+	t.Run("Should create a purchase order in base units", func(t *testing.T) {
+		response := authedRequest(token, "POST", "/purchase-orders",
+			`{"supplier_id": "`+supplier.Id+`", "expected_at": "2030-02-01",
+			  "lines": [{"item_id": "`+item.Id+`", "quantity": 3, "unit": "cx"}]}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &po)
+
+		if len(po.Lines) != 1 || po.Lines[0].Outstanding != repository.NewDecimal(30) {
+			t.Fatalf("Expected 30 outstanding, got %v", po.Lines)
+		}
+
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/purchase-orders", `{"supplier_id": "`+supplier.Id+`"}`).Code)
+	})
+
+	receive := func(quantity string) *httptest.ResponseRecorder {
+		return authedRequest(token, "POST", "/purchase-orders/"+po.Id+"/receipts", `{"lines": [{"line_id": "`+po.Lines[0].Id+`", "quantity": `+quantity+`}]}`)
+	}
+
+	t.Run("Should receive goods partially", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, receive("12").Code)
+
+		json.Unmarshal(authedRequest(token, "GET", "/purchase-orders/"+po.Id, "").Body.Bytes(), &po)
+
+		if po.Status != repository.PurchaseOrderPartiallyReceived || po.Lines[0].Outstanding != repository.NewDecimal(18) {
+			t.Errorf("Expected partially received with 18 outstanding, got %s with %s", po.Status, po.Lines[0].Outstanding)
+		}
+
+		checkResponseCode(t, http.StatusConflict, receive("19").Code)
+	})
+
+	t.Run("Should close the order once everything is received", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, receive("18").Code)
+
+		json.Unmarshal(authedRequest(token, "GET", "/purchase-orders/"+po.Id, "").Body.Bytes(), &po)
+
+		if po.Status != repository.PurchaseOrderReceived {
+			t.Errorf("Expected order to be received, got %s", po.Status)
+		}
+
+		var wi repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+item.Id, "").Body.Bytes(), &wi)
+
+		if wi.Quantity != repository.NewDecimal(30) {
+			t.Errorf("Expected stock to be 30, got %s", wi.Quantity)
+		}
+
+		checkResponseCode(t, http.StatusConflict, receive("1").Code)
+	})
+}
+
+func TestValuationReport(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var item repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "caderno", "pack_unit": "cx", "pack_size": 10}`).Body.Bytes(), &item)
+
+	authedRequest(token, "POST", "/warehouse/"+item.Id+"/receipts", `{"quantity": 1, "unit": "cx", "unit_cost": 20}`)
+	authedRequest(token, "POST", "/warehouse/"+item.Id+"/receipts", `{"quantity": 10, "unit_cost": 4}`)
+	authedRequest(token, "POST", "/warehouse/"+item.Id+"/issues", `{"quantity": 5}`)
+
+	t.Run("Should refuse a unit cost on issues", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/warehouse/"+item.Id+"/issues", `{"quantity": 1, "unit_cost": 2}`).Code)
+	})
+
+	t.Run("Should value stock at the average cost", func(t *testing.T) {
+		t.Setenv("COSTING_METHOD", "average")
+
+		response := authedRequest(token, "GET", "/reports/valuation", "")
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var report internal.ValuationReport
+		json.Unmarshal(response.Body.Bytes(), &report)
+
+		if report.Total != repository.NewDecimal(45) {
+			t.Errorf("Expected stock to be worth 45, got %s", report.Total)
+		}
+	})
+
+	t.Run("Should value stock first in first out", func(t *testing.T) {
+		t.Setenv("COSTING_METHOD", "fifo")
+
+		var report internal.ValuationReport
+		json.Unmarshal(authedRequest(token, "GET", "/reports/valuation", "").Body.Bytes(), &report)
+
+		if report.Total != repository.NewDecimal(50) {
+			t.Errorf("Expected stock to be worth 50, got %s", report.Total)
+		}
+	})
+
+	t.Run("Should value nothing before the first receipt", func(t *testing.T) {
+		var report internal.ValuationReport
+		json.Unmarshal(authedRequest(token, "GET", "/reports/valuation?date=2000-01-01", "").Body.Bytes(), &report)
+
+		if len(report.Items) != 0 {
+			t.Errorf("Expected no items, got %v", report.Items)
+		}
+	})
+
+	t.Run("Should export CSV", func(t *testing.T) {
+		response := authedRequest(token, "GET", "/reports/valuation?format=csv", "")
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		if response.Header().Get("Content-Type") != "text/csv" {
+			t.Errorf("Expected a CSV response, got %q", response.Header().Get("Content-Type"))
+		}
+	})
+}
+
+func TestForecastRecommendations(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	var item repository.WarehouseItem
+	json.Unmarshal(authedRequest(token, "POST", "/warehouse", `{"name": "caderno", "quantity": 100}`).Body.Bytes(), &item)
+
+	authedRequest(token, "POST", "/warehouse/"+item.Id+"/issues", `{"quantity": 30}`)
+
+	s.DB.Exec("UPDATE stock_movements SET created_at = date_trunc('month', NOW()) - INTERVAL '45 days' WHERE kind = 'receipt'")
+	s.DB.Exec("UPDATE stock_movements SET created_at = date_trunc('month', NOW()) - INTERVAL '10 days' WHERE kind = 'issue'")
+
+	t.Run("Should only let administrators run forecasts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, authedRequest(token, "POST", "/forecasts", "").Code)
+	})
+
+	s.DB.Exec("UPDATE users SET role = 'admin'")
+
+	var recs []repository.Recommendation
+
+	t.Run("Should recommend levels with a rationale", func(t *testing.T) {
+		checkResponseCode(t, http.StatusUnprocessableEntity, authedRequest(token, "POST", "/forecasts", `{"alpha": 3}`).Code)
+
+		response := authedRequest(token, "POST", "/forecasts", `{"method": "moving_average", "window": 2}`)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		json.Unmarshal(response.Body.Bytes(), &recs)
+
+		if len(recs) != 1 || recs[0].ItemId != item.Id || !recs[0].Max.IsPositive() {
+			t.Fatalf("Expected a recommendation for the item, got %v", recs)
+		}
+
+		var rationale internal.Rationale
+		json.Unmarshal(recs[0].Rationale, &rationale)
+
+		if rationale.Months != 2 || rationale.Summary == "" {
+			t.Errorf("Expected a rationale over 2 months, got %+v", rationale)
+		}
+	})
+
+	t.Run("Should accept recommendations in bulk", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, authedRequest(token, "POST", "/recommendations/accept", "").Code)
+
+		var wi repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+item.Id, "").Body.Bytes(), &wi)
+
+		if wi.Min != recs[0].Min || wi.Max != recs[0].Max {
+			t.Errorf("Expected min %s and max %s, got %s and %s", recs[0].Min, recs[0].Max, wi.Min, wi.Max)
+		}
+
+		json.Unmarshal(authedRequest(token, "GET", "/recommendations?status=pending", "").Body.Bytes(), &recs)
+
+		if len(recs) != 0 {
+			t.Errorf("Expected no pending recommendations, got %d", len(recs))
+		}
+	})
+}
+
+func TestWarehouseItemLots(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	r, _ := http.NewRequest("POST", "/warehouse", bytes.NewBuffer([]byte(`{
+		"name": "rice",
+		"unit": "kg"
+	}`)))
+	r.Header.Set("Authorization", token)
+
+	response := executeRequest(r)
+
+	var item repository.WarehouseItem
+	json.Unmarshal(response.Body.Bytes(), &item)
+
+	receive := func(quantity, expiresAt string) {
+		r, _ := http.NewRequest("POST", "/warehouse/"+item.Id+"/receipts", bytes.NewBuffer([]byte(`{
+			"quantity": `+quantity+`,
+			"expires_at": "`+expiresAt+`"
+		}`)))
+		r.Header.Set("Authorization", token)
+
+		checkResponseCode(t, http.StatusCreated, executeRequest(r).Code)
+	}
+
+	receive("5", "2000-01-01")
+	receive("10", "2999-12-31")
+	receive("10", "2999-01-31")
+
+	t.Run("Should consume lots first-expiring-first-out skipping expired ones", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/warehouse/"+item.Id+"/issues", bytes.NewBuffer([]byte(`{
+			"quantity": 12
+		}`)))
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusCreated, response.Code)
+
+		r, _ = http.NewRequest("GET", "/warehouse/"+item.Id+"/lots", nil)
+		r.Header.Set("Authorization", token)
+
+		var lots []repository.Lot
+		json.Unmarshal(executeRequest(r).Body.Bytes(), &lots)
+
+		if len(lots) != 2 {
+			t.Fatalf("Expected 2 lots with stock, got %d", len(lots))
+		}
+
+		if lots[0].ExpiresAt.String() != "2000-01-01" || lots[0].Quantity != repository.NewDecimal(5) {
+			t.Errorf("Expected expired lot to be untouched, got %s of %s", lots[0].Quantity, lots[0].ExpiresAt)
+		}
+
+		if lots[1].ExpiresAt.String() != "2999-12-31" || lots[1].Quantity != repository.NewDecimal(8) {
+			t.Errorf("Expected latest lot to have 8 left, got %s of %s", lots[1].Quantity, lots[1].ExpiresAt)
+		}
+	})
+
+	t.Run("Should not ship expired lots", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/warehouse/"+item.Id+"/issues", bytes.NewBuffer([]byte(`{
+			"quantity": 10
+		}`)))
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusConflict, response.Code)
+	})
+
+	t.Run("Should list lots expiring within N days", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/warehouse/lots/expiring?days=7", nil)
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var lots []repository.Lot
+		json.Unmarshal(response.Body.Bytes(), &lots)
+
+		if len(lots) != 1 {
+			t.Errorf("Expected 1 expiring lot, got %d", len(lots))
+		}
+	})
+}
+
+func TestScanSessions(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	r, _ := http.NewRequest("POST", "/warehouse", bytes.NewBuffer([]byte(`{
+		"name": "caderno",
+		"pack_unit": "box",
+		"pack_size": 10
+	}`)))
+	r.Header.Set("Authorization", token)
+
+	var item repository.WarehouseItem
+	json.Unmarshal(executeRequest(r).Body.Bytes(), &item)
+
+	t.Run("Should reject barcodes with a wrong check digit", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/warehouse/"+item.Id+"/barcodes", bytes.NewBuffer([]byte(`{"code": "7891000100104"}`)))
+		r.Header.Set("Authorization", token)
+
+		checkResponseCode(t, http.StatusUnprocessableEntity, executeRequest(r).Code)
+	})
+
+	r, _ = http.NewRequest("POST", "/warehouse/"+item.Id+"/barcodes", bytes.NewBuffer([]byte(`{"code": "7891000100103"}`)))
+	r.Header.Set("Authorization", token)
+	executeRequest(r)
+
+	t.Run("Should find item by barcode", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/warehouse/barcode/7891000100103", nil)
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var got repository.WarehouseItem
+		json.Unmarshal(response.Body.Bytes(), &got)
+
+		if got.Id != item.Id {
+			t.Errorf("Expected item %q, got %q", item.Id, got.Id)
+		}
+	})
+
+	t.Run("Should receive scanned items when session is committed", func(t *testing.T) {
+		r, _ := http.NewRequest("POST", "/scan-sessions", bytes.NewBuffer([]byte(`{"kind": "receipt"}`)))
+		r.Header.Set("Authorization", token)
+
+		var ss repository.ScanSession
+		json.Unmarshal(executeRequest(r).Body.Bytes(), &ss)
+
+		for _, scan := range []string{`{"code": "7891000100103"}`, `{"code": "7891000100103", "quantity": 2, "unit": "box"}`} {
+			r, _ = http.NewRequest("POST", "/scan-sessions/"+ss.Id+"/scans", bytes.NewBuffer([]byte(scan)))
+			r.Header.Set("Authorization", token)
+
+			checkResponseCode(t, http.StatusOK, executeRequest(r).Code)
+		}
+
+		r, _ = http.NewRequest("POST", "/scan-sessions/"+ss.Id+"/commit", nil)
+		r.Header.Set("Authorization", token)
+
+		checkResponseCode(t, http.StatusOK, executeRequest(r).Code)
+
+		r, _ = http.NewRequest("GET", "/warehouse/"+item.Id, nil)
+		r.Header.Set("Authorization", token)
+
+		var got repository.WarehouseItem
+		json.Unmarshal(executeRequest(r).Body.Bytes(), &got)
+
+		if got.Quantity != repository.NewDecimal(21) {
+			t.Errorf("Expected quantity to be 21, got %s", got.Quantity)
+		}
+	})
+
+	t.Run("Should count against stock as it is when the session is committed", func(t *testing.T) {
+		var ss repository.ScanSession
+		json.Unmarshal(authedRequest(token, "POST", "/scan-sessions", `{"kind": "count"}`).Body.Bytes(), &ss)
+
+		checkResponseCode(t, http.StatusOK, authedRequest(token, "POST", "/scan-sessions/"+ss.Id+"/scans", `{"code": "7891000100103", "quantity": 15}`).Code)
+
+		checkResponseCode(t, http.StatusCreated, authedRequest(token, "POST", "/warehouse/"+item.Id+"/issues", `{"quantity": 5}`).Code)
+
+		checkResponseCode(t, http.StatusOK, authedRequest(token, "POST", "/scan-sessions/"+ss.Id+"/commit", "").Code)
+
+		var got repository.WarehouseItem
+		json.Unmarshal(authedRequest(token, "GET", "/warehouse/"+item.Id, "").Body.Bytes(), &got)
+
+		if got.Quantity != repository.NewDecimal(15) {
+			t.Errorf("Expected the count to set quantity to 15, got %s", got.Quantity)
+		}
+	})
+}
+
+func TestWarehouseItemsSearch(t *testing.T) {
+	clearTables()
+	token := createAndAuthUser()
+
+	for _, item := range []string{
+		`{"name": "lápis", "quantity": 5, "min": 10, "max": 50}`,
+		`{"name": "Caderno", "quantity": 80, "min": 10, "max": 50}`,
+		`{"name": "borracha", "quantity": 20, "min": 10, "max": 50}`,
+	} {
+		r, _ := http.NewRequest("POST", "/warehouse", bytes.NewBuffer([]byte(item)))
+		r.Header.Set("Authorization", token)
+		executeRequest(r)
+	}
+
+	search := func(t *testing.T, query string) []repository.WarehouseItem {
+		r, _ := http.NewRequest("GET", "/warehouse?"+query, nil)
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		var items []repository.WarehouseItem
+		json.Unmarshal(response.Body.Bytes(), &items)
+
+		return items
+	}
+
+	t.Run("Should search names ignoring accents and case", func(t *testing.T) {
+		items := search(t, "name=LAPIS")
+
+		if len(items) != 1 || items[0].Name != "lápis" {
+			t.Errorf("Expected only lápis, got %v", items)
+		}
+	})
+
+	t.Run("Should filter items below min and above max", func(t *testing.T) {
+		if items := search(t, "below_min=true"); len(items) != 1 || items[0].Name != "lápis" {
+			t.Errorf("Expected only lápis, got %v", items)
+		}
+
+		if items := search(t, "above_max=true"); len(items) != 1 || items[0].Name != "caderno" {
+			t.Errorf("Expected only caderno, got %v", items)
+		}
+	})
+
+	t.Run("Should sort by quantity descending", func(t *testing.T) {
+		items := search(t, "sort=quantity&order=desc&min_quantity=10")
+
+		if len(items) != 2 || items[0].Name != "caderno" || items[1].Name != "borracha" {
+			t.Errorf("Expected caderno then borracha, got %v", items)
+		}
+	})
+
+	t.Run("Should page through items following the Link header", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/warehouse?count=2&total=true", nil)
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		if total := response.Header().Get("X-Total-Count"); total != "3" {
+			t.Errorf("Expected total count to be 3, got %q", total)
+		}
+
+		next := ""
+
+		for _, link := range strings.Split(response.Header().Get("Link"), ", ") {
+			if strings.HasSuffix(link, `rel="next"`) {
+				next = strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			}
+		}
+
+		if next == "" {
+			t.Fatal("Expected a next link")
+		}
+
+		r, _ = http.NewRequest("GET", next, nil)
+		r.Header.Set("Authorization", token)
+
+		response = executeRequest(r)
+
+		var items []repository.WarehouseItem
+		json.Unmarshal(response.Body.Bytes(), &items)
+
+		if len(items) != 1 || items[0].Name != "lápis" {
+			t.Errorf("Expected only lápis on the second page, got %v", items)
+		}
+	})
+
+	t.Run("Should serve the first page for the deprecated start parameter", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/warehouse?count=2&start=2", nil)
+		r.Header.Set("Authorization", token)
+
+		response := executeRequest(r)
+
+		checkResponseCode(t, http.StatusOK, response.Code)
+
+		if response.Header().Get("Deprecation") != "true" {
+			t.Errorf("Expected a Deprecation header, got %q", response.Header().Get("Deprecation"))
+		}
+
+		if strings.Contains(response.Header().Get("Link"), "start=") {
+			t.Errorf("Expected links without start, got %q", response.Header().Get("Link"))
+		}
+
+		var items []repository.WarehouseItem
+		json.Unmarshal(response.Body.Bytes(), &items)
+
+		if len(items) != 2 {
+			t.Errorf("Expected the first 2 items, got %v", items)
+		}
+	})
+
+	t.Run("Should return 400 for a malformed start", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/warehouse?start=abc", nil)
+		r.Header.Set("Authorization", token)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(r).Code)
+	})
+
+	t.Run("Should return 400 for an invalid page size", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/warehouse?count=abc", nil)
+		r.Header.Set("Authorization", token)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(r).Code)
+	})
+
+	t.Run("Should return 400 for sort fields not in the whitelist", func(t *testing.T) {
+		r, _ := http.NewRequest("GET", "/warehouse?sort=password", nil)
+		r.Header.Set("Authorization", token)
+
+		checkResponseCode(t, http.StatusBadRequest, executeRequest(r).Code)
+	})
+}
+
+func createAndAuthUser() string {
+
+	r, _ := http.NewRequest("POST", "/user", bytes.NewBuffer(userCreationStr))
+	r.Header.Set("Content-Type", "application/json")
+
+	executeRequest(r)
+
+	u := repository.User{Email: "testuser@example.com"}
+	u.GetUserByEmail(s.DB)
+
+	r, _ = http.NewRequest("GET", "/user/confirm/"+u.Id, nil)
+
+	executeRequest(r)
+
+	r, _ = http.NewRequest("POST", "/auth", bytes.NewBuffer(userAuthStr))
+
+	authResponse := executeRequest(r)
+
+	var am map[string]interface{}
+
+	json.Unmarshal(authResponse.Body.Bytes(), &am)
+
+	tokenString := fmt.Sprintf("bearer %v", am["token"])
+
+	return tokenString
+}
+
+func getETag(id, token string) string {
+	r, _ := http.NewRequest("GET", "/warehouse/"+id, nil)
+	r.Header.Set("Authorization", token)
+
+	return executeRequest(r).Header().Get("ETag")
+}
+
+func executeRequest(r *http.Request) *httptest.ResponseRecorder {
+	rr := httptest.NewRecorder()
+	s.Router.ServeHTTP(rr, r)
+
+	return rr
+}
+
+// authedRequest executes a request with a JSON body on behalf of the user the
+// token was issued to.
+func authedRequest(token, method, url, body string) *httptest.ResponseRecorder {
+	r, _ := http.NewRequest(method, url, bytes.NewBuffer([]byte(body)))
+	r.Header.Set("Authorization", token)
+
+	return executeRequest(r)
+}
+
+func checkResponseCode(t *testing.T, expected, actual int) {
+	t.Helper()
+	if expected != actual {
+		t.Errorf("Expected response code %d. Got %d\n", expected, actual)
+	}
+}
+
+func clearTables() {
+	s.DB.Exec("DELETE FROM shipments")
+
+	s.DB.Exec("DELETE FROM allotments")
+
+	s.DB.Exec("DELETE FROM handouts")
+
+	s.DB.Exec("DELETE FROM entitlements")
+
+	s.DB.Exec("DELETE FROM stocktakes")
+
+	s.DB.Exec("DELETE FROM users")
+
+	s.DB.Exec("DELETE FROM scan_sessions")
+
+	s.DB.Exec("DELETE FROM reservations")
+
+	s.DB.Exec("DELETE FROM min_max_recommendations")
+
+	s.DB.Exec("DELETE FROM purchase_orders")
+
+	s.DB.Exec("DELETE FROM suppliers")
+
+	s.DB.Exec("DELETE FROM kit_components")
+
+	s.DB.Exec("DELETE FROM item_barcodes")
+
+	s.DB.Exec("DELETE FROM stock_movement_lots")
+
+	s.DB.Exec("DELETE FROM stock_lots")
+
+	s.DB.Exec("DELETE FROM stock_movements")
+
+	s.DB.Exec("DELETE FROM stock_snapshots")
+
+	s.DB.Exec("DELETE FROM warehouse_items")
+
+	s.DB.Exec("DELETE FROM products")
+
+	s.DB.Exec("DELETE FROM categories")
+
+	s.DB.Exec("DELETE FROM assets")
 
 	s.DB.Exec("DELETE FROM schools")
 }
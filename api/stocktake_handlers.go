@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+type stocktakeRequest struct {
+	Blind      bool     `json:"blind"`
+	CategoryId *string  `json:"category_id"`
+	ItemIds    []string `json:"item_ids"`
+}
+
+type stocktakeCountRequest struct {
+	ItemId   string             `json:"item_id"`
+	Quantity repository.Decimal `json:"quantity"`
+	Unit     string             `json:"unit"`
+}
+
+func (s *Server) CreateStocktakeHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req stocktakeRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	createdBy := fmt.Sprintf("%v", claims["user_id"])
+
+	st := repository.Stocktake{Blind: req.Blind, CategoryId: req.CategoryId, CreatedBy: &createdBy}
+
+	if err = st.CreateStocktake(s.DB, req.ItemIds); err != nil {
+		if errors.Is(err, repository.ErrItemLocked) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithStorageError(w, err, "Stocktake already exists")
+		return
+	}
+
+	if st.Blind && !s.isAdmin(claims) {
+		st.HideExpected(createdBy)
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, st)
+}
+
+func (s *Server) GetStocktakeHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	st := repository.Stocktake{Id: mux.Vars(r)["id"]}
+
+	if err = st.GetStocktakeById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Stocktake not found")
+		return
+	}
+
+	if st.Blind && st.Status == repository.StocktakeOpen && !s.isAdmin(claims) {
+		st.HideExpected(fmt.Sprintf("%v", claims["user_id"]))
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) CountStocktakeItemHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req stocktakeCountRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	v.Required("item_id", req.ItemId)
+	v.NonNegative("quantity", req.Quantity)
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	st := repository.Stocktake{Id: mux.Vars(r)["id"]}
+
+	if err = st.GetStocktakeById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Stocktake not found")
+		return
+	}
+
+	wi := repository.WarehouseItem{Id: req.ItemId}
+
+	if err = wi.GetAnyWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	quantity, err := wi.ToBaseUnits(req.Quantity, req.Unit)
+
+	if err != nil {
+		internal.RespondWithValidationErrors(w, internal.ValidationErrors{{Field: "unit", Code: "invalid", Message: err.Error()}})
+		return
+	}
+
+	if err = st.AddCount(s.DB, wi.Id, fmt.Sprintf("%v", claims["user_id"]), quantity); err != nil {
+		if errors.Is(err, repository.ErrStocktakeClosed) || errors.Is(err, repository.ErrItemNotInStocktake) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) ApproveStocktakeHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	st := repository.Stocktake{Id: mux.Vars(r)["id"]}
+
+	if err = st.GetStocktakeById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Stocktake not found")
+		return
+	}
+
+	if err = st.Approve(s.DB); err != nil {
+		if errors.Is(err, repository.ErrStocktakeDisputed) {
+			internal.RespondWithJSON(w, http.StatusConflict, map[string]any{
+				"error": "Disputed items must be recounted before approving", "item_ids": st.DisputedItems(),
+			})
+			return
+		}
+
+		if errors.Is(err, repository.ErrStocktakeClosed) || errors.Is(err, repository.ErrInsufficientStock) ||
+			errors.Is(err, repository.ErrItemArchived) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) CancelStocktakeHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	st := repository.Stocktake{Id: mux.Vars(r)["id"]}
+
+	if err = st.GetStocktakeById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Stocktake not found")
+		return
+	}
+
+	if err = st.Cancel(s.DB); err != nil {
+		if errors.Is(err, repository.ErrStocktakeClosed) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
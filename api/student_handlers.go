@@ -0,0 +1,360 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/xsadia/secred/internal"
+	"github.com/xsadia/secred/repository"
+)
+
+// ImportRosterHandler creates or updates a school's students from a CSV
+// roster. With replace=true, students missing from the roster are marked as
+// no longer enrolled.
+func (s *Server) ImportRosterHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	replace, err := strconv.ParseBool(r.URL.Query().Get("replace"))
+
+	if err != nil && r.URL.Query().Get("replace") != "" {
+		internal.RespondWithError(w, http.StatusBadRequest, "Invalid replace parameter")
+		return
+	}
+
+	fileName, status, err := saveUploadedCSV(r)
+
+	if err != nil {
+		internal.RespondWithError(w, status, err.Error())
+		return
+	}
+
+	defer os.Remove(fileName)
+
+	students, err := internal.ParseRosterCSV(fileName)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusNotAcceptable, err.Error())
+		return
+	}
+
+	var errs internal.ValidationErrors
+
+	enrolled := map[string]bool{}
+
+	for i, st := range students {
+		field := fmt.Sprintf("rows[%d]", i)
+
+		errs = append(errs, internal.ValidateStudent(st).PrefixFields(field)...)
+
+		if enrolled[st.EnrollmentId] {
+			errs = append(errs, internal.FieldError{
+				Field: field + ".enrollment_id", Code: "duplicate", Message: "enrollment_id is already on another row",
+			})
+		}
+
+		enrolled[st.EnrollmentId] = true
+	}
+
+	if len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	result, err := repository.ImportRoster(s.DB, mux.Vars(r)["id"], students, replace)
+
+	if err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) GetStudentsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	includeInactive, _ := strconv.ParseBool(r.URL.Query().Get("include_inactive"))
+
+	students, err := repository.GetStudents(s.DB, mux.Vars(r)["id"], includeInactive)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, students)
+}
+
+func (s *Server) CreateEntitlementHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	var e repository.Entitlement
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&e); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if errs := internal.ValidateEntitlement(e); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	if err = e.CreateEntitlement(s.DB); err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, e)
+}
+
+func (s *Server) GetEntitlementsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	entitlements, err := repository.GetEntitlements(s.DB, r.URL.Query().Get("school_id"))
+
+	if err != nil {
+		internal.RespondWithStorageError(w, err, "")
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, entitlements)
+}
+
+func (s *Server) DeleteEntitlementHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	e := repository.Entitlement{Id: mux.Vars(r)["id"]}
+
+	if err = e.DeleteEntitlement(s.DB); err != nil {
+		if errors.Is(err, repository.ErrEntitlementNotFound) {
+			internal.RespondWithError(w, http.StatusNotFound, "Entitlement not found")
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// CreateHandoutHandler records items given to a student.
+func (s *Server) CreateHandoutHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var h repository.Handout
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&h); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	if errs := internal.ValidateHandout(h); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	userId := fmt.Sprintf("%v", claims["user_id"])
+
+	h.StudentId = mux.Vars(r)["id"]
+	h.UserId = &userId
+
+	if err = h.CreateHandout(s.DB); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			internal.RespondWithError(w, http.StatusNotFound, "Student not found")
+		case errors.Is(err, repository.ErrStudentInactive):
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			internal.RespondWithStorageError(w, err, "")
+		}
+
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, h)
+}
+
+func (s *Server) GetHandoutsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	st := repository.Student{Id: mux.Vars(r)["id"]}
+
+	if err = st.GetStudentById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	handouts, err := repository.GetHandouts(s.DB, st.Id)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, handouts)
+}
+
+// GetEntitlementReportHandler reports, for each entitlement at a school, who
+// is still missing items in a year, the current one by default.
+func (s *Server) GetEntitlementReportHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	year := time.Now().UTC().Year()
+
+	if v := r.URL.Query().Get("year"); v != "" {
+		if year, err = strconv.Atoi(v); err != nil || year < 1 {
+			internal.RespondWithError(w, http.StatusBadRequest, "Invalid year parameter")
+			return
+		}
+	}
+
+	schoolId := mux.Vars(r)["id"]
+
+	rows, err := repository.GetStudentEntitlements(s.DB, schoolId, year)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, internal.NewEntitlementReport(schoolId, year, rows))
+}
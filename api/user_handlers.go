@@ -82,6 +82,11 @@ func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	defer r.Body.Close()
 
+	if errs := internal.ValidateUser(u); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
 	if err := u.GetUserByEmail(s.DB); err == nil {
 		internal.RespondWithError(w, http.StatusConflict, emailAlreadyInUserError)
 		return
@@ -90,7 +95,7 @@ func (s *Server) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	u.Password = internal.HashPassword([]byte(u.Password), 8)
 
 	if err := u.Create(s.DB); err != nil {
-		internal.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		internal.RespondWithStorageError(w, err, emailAlreadyInUserError)
 		return
 	}
 
@@ -136,6 +141,7 @@ func (s *Server) AuthUserHandler(w http.ResponseWriter, r *http.Request) {
 		Username:     u.Username,
 		RefreshToken: u.RefreshToken,
 		Activated:    u.Activated,
+		Role:         u.Role,
 	}
 
 	internal.RespondWithJSON(w, http.StatusOK, map[string]any{"token": token, "user": user})
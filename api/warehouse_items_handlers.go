@@ -2,7 +2,10 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"os"
 	"strconv"
@@ -29,30 +32,34 @@ func (s *Server) GetWareHouseItemsHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	var count, start int
-	startQuery := r.URL.Query().Get("start")
-	countQuery := r.URL.Query().Get("count")
+	f, err := internal.ParseWarehouseItemFilter(r.URL.Query())
 
-	if startQuery == "" {
-		start = 0
-	} else {
-		start, _ = strconv.Atoi(startQuery)
+	if err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	if countQuery == "" {
-		count = 10
-	} else {
-		count, _ = strconv.Atoi(countQuery)
+	p, err := internal.ParsePageRequest(r.URL.Query())
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	items, err := repository.GetWarehouseItems(s.DB, start, count)
+	page, err := repository.GetWarehouseItems(s.DB, f, p)
 
 	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			internal.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
 		return
 	}
 
-	internal.RespondWithJSON(w, http.StatusOK, items)
+	internal.SetPageHeaders(w, r.URL, page)
+	internal.RespondWithJSON(w, http.StatusOK, page.Items)
 }
 
 func (s *Server) GetWareHouseItemHandler(w http.ResponseWriter, r *http.Request) {
@@ -72,20 +79,77 @@ func (s *Server) GetWareHouseItemHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	includeArchived, err := strconv.ParseBool(r.URL.Query().Get("include_archived"))
+
+	if err != nil && r.URL.Query().Get("include_archived") != "" {
+		internal.RespondWithError(w, http.StatusBadRequest, "Invalid include_archived parameter")
+		return
+	}
+
+	asOf, err := internal.ParseAsOf(r.URL.Query())
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	var wi repository.WarehouseItem
 
 	vars := mux.Vars(r)
 
 	wi.Id = vars["id"]
 
-	if err = wi.GetWarehouseItemById(s.DB); err != nil {
+	switch {
+	case asOf != nil:
+		err = wi.GetWarehouseItemAsOf(s.DB, *asOf, includeArchived)
+	case includeArchived:
+		err = wi.GetAnyWarehouseItemById(s.DB)
+	default:
+		err = wi.GetWarehouseItemById(s.DB)
+	}
+
+	if err != nil {
 		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
 		return
 	}
 
+	// A past state has no version of its own to validate against.
+	if asOf == nil {
+		etag := internal.ETag(wi.Version, wi.Reserved)
+		w.Header().Set("ETag", etag)
+
+		if inm := r.Header.Get("If-None-Match"); inm != "" && internal.MatchETag(inm, etag, true) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	if err = wi.GetBarcodes(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
 	internal.RespondWithJSON(w, http.StatusOK, wi)
 }
 
+// checkIfMatch requires an If-Match header matching the item's entity tag,
+// responding with 428 or 412 when it is missing or stale.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, wi repository.WarehouseItem) bool {
+	ifMatch := r.Header.Get("If-Match")
+
+	if ifMatch == "" {
+		internal.RespondWithError(w, http.StatusPreconditionRequired, "If-Match header required")
+		return false
+	}
+
+	if !internal.MatchETag(ifMatch, internal.ETag(wi.Version, wi.Reserved), false) {
+		internal.RespondWithError(w, http.StatusPreconditionFailed, repository.ErrVersionMismatch.Error())
+		return false
+	}
+
+	return true
+}
+
 func (s *Server) CreateWarehouseItemHandler(w http.ResponseWriter, r *http.Request) {
 	ah := r.Header.Get("Authorization")
 
@@ -114,11 +178,17 @@ func (s *Server) CreateWarehouseItemHandler(w http.ResponseWriter, r *http.Reque
 
 	defer r.Body.Close()
 
+	if errs := internal.ValidateWarehouseItem(wi); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
+		return
+	}
+
 	if err := wi.CreateWarehouseItem(s.DB); err != nil {
-		internal.RespondWithError(w, http.StatusConflict, "Item already registered")
+		internal.RespondWithStorageError(w, err, "Item already registered")
 		return
 	}
 
+	w.Header().Set("ETag", internal.ETag(wi.Version, wi.Reserved))
 	internal.RespondWithJSON(w, http.StatusCreated, wi)
 }
 
@@ -143,27 +213,68 @@ func (s *Server) UpdateWarehouseItemHandler(w http.ResponseWriter, r *http.Reque
 
 	var wi repository.WarehouseItem
 
-	decoder := json.NewDecoder(r.Body)
+	wi.Id = vars["id"]
 
-	if err = decoder.Decode(&wi); err != nil {
+	if err = wi.GetWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	if !checkIfMatch(w, r, wi) {
+		return
+	}
+
+	patch, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
 		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
 		return
 	}
 
 	defer r.Body.Close()
 
-	wi.Id = vars["id"]
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	updated, err := internal.PatchWarehouseItem(wi, mediaType, patch)
+
+	var errs internal.ValidationErrors
+
+	if err != nil {
+		switch {
+		case errors.As(err, &errs):
+			internal.RespondWithValidationErrors(w, errs)
+		case errors.Is(err, internal.ErrUnsupportedPatch):
+			internal.RespondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, internal.ErrPatchTestFailed):
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			internal.RespondWithError(w, http.StatusBadRequest, err.Error())
+		}
 
-	if err = wi.GetWarehouseItemById(s.DB); err != nil {
-		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
 		return
 	}
 
-	if err = wi.UpdateWarehouseItem(s.DB); err != nil {
-		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+	if errs := internal.ValidateWarehouseItem(updated); len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
 		return
 	}
 
+	if err = updated.UpdateWarehouseItem(s.DB); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrVersionMismatch):
+			internal.RespondWithError(w, http.StatusPreconditionFailed, err.Error())
+		case errors.Is(err, repository.ErrNameTaken):
+			internal.RespondWithError(w, http.StatusConflict, "Item already registered")
+		case errors.Is(err, repository.ErrInsufficientStock), errors.Is(err, repository.ErrItemLocked):
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			internal.RespondWithStorageError(w, err, "Item already registered")
+		}
+
+		return
+	}
+
+	w.Header().Set("ETag", internal.ETag(updated.Version, updated.Reserved))
 	internal.RespondWithJSON(w, http.StatusNoContent, nil)
 }
 
@@ -195,7 +306,100 @@ func (s *Server) DeleteWarehouseItemHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !checkIfMatch(w, r, wi) {
+		return
+	}
+
 	if err = wi.DeleteWarehouseItem(s.DB); err != nil {
+		if errors.Is(err, repository.ErrVersionMismatch) {
+			internal.RespondWithError(w, http.StatusPreconditionFailed, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func (s *Server) RestoreWarehouseItemHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var wi repository.WarehouseItem
+
+	wi.Id = mux.Vars(r)["id"]
+
+	if err = wi.GetAnyWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	if err = wi.RestoreWarehouseItem(s.DB); err != nil {
+		if errors.Is(err, repository.ErrItemNotArchived) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", internal.ETag(wi.Version, wi.Reserved))
+	internal.RespondWithJSON(w, http.StatusOK, wi)
+}
+
+func (s *Server) PurgeWarehouseItemHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	claims, err := internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !s.isAdmin(claims) {
+		internal.RespondWithError(w, http.StatusForbidden, adminOnlyError)
+		return
+	}
+
+	var wi repository.WarehouseItem
+
+	wi.Id = mux.Vars(r)["id"]
+
+	if err = wi.GetAnyWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	if err = wi.PurgeWarehouseItem(s.DB); err != nil {
+		if errors.Is(err, repository.ErrItemHasHistory) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
 		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
 		return
 	}
@@ -203,6 +407,21 @@ func (s *Server) DeleteWarehouseItemHandler(w http.ResponseWriter, r *http.Reque
 	internal.RespondWithJSON(w, http.StatusNoContent, nil)
 }
 
+// csvUploadResult tells how many rows of a warehouse CSV were imported and
+// which were skipped, by their index in the file.
+type csvUploadResult struct {
+	Imported int             `json:"imported"`
+	Skipped  []csvSkippedRow `json:"skipped"`
+}
+
+type csvSkippedRow struct {
+	Row    int    `json:"row"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// UploadCSVWarehouse creates or updates every item in the file, row by row.
+// Rows for archived items are skipped and reported rather than imported.
 func (s *Server) UploadCSVWarehouse(w http.ResponseWriter, r *http.Request) {
 	ah := r.Header.Get("Authorization")
 
@@ -220,53 +439,406 @@ func (s *Server) UploadCSVWarehouse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	r.ParseMultipartForm(10 << 20)
+	fileName, status, err := saveUploadedCSV(r)
 
-	file, header, err := r.FormFile("csvFile")
+	if err != nil {
+		internal.RespondWithError(w, status, err.Error())
+		return
+	}
+
+	defer os.Remove(fileName)
+
+	wil, err := internal.ParseCSV(fileName)
 
 	if err != nil {
-		internal.RespondWithError(w, http.StatusBadRequest, err.Error())
+		internal.RespondWithError(w, http.StatusNotAcceptable, err.Error())
 		return
 	}
 
-	if err = internal.CheckMIMEContentType(header.Header); err != nil {
-		internal.RespondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+	var errs internal.ValidationErrors
+
+	for i, wi := range wil {
+		errs = append(errs, internal.ValidateWarehouseItem(wi).PrefixFields(fmt.Sprintf("rows[%d]", i))...)
+	}
+
+	if len(errs) > 0 {
+		internal.RespondWithValidationErrors(w, errs)
 		return
 	}
 
+	result := csvUploadResult{Imported: 0, Skipped: []csvSkippedRow{}}
+
+	for i := range wil {
+		err = wil[i].UpSertWarehouseItem(s.DB)
+
+		if errors.Is(err, repository.ErrItemArchived) {
+			result.Skipped = append(result.Skipped, csvSkippedRow{Row: i, Name: wil[i].Name, Reason: "item is archived"})
+			continue
+		}
+
+		if err != nil {
+			internal.RespondWithStorageError(w, err, "")
+			return
+		}
+
+		result.Imported++
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, result)
+}
+
+// saveUploadedCSV stores the csvFile form field in a temporary file, returning
+// its name and, on failure, the status to respond with. The caller removes the
+// file.
+func saveUploadedCSV(r *http.Request) (string, int, error) {
+	r.ParseMultipartForm(10 << 20)
+
+	file, header, err := r.FormFile("csvFile")
+
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+
 	defer file.Close()
 
+	if err = internal.CheckMIMEContentType(header.Header); err != nil {
+		return "", http.StatusUnsupportedMediaType, err
+	}
+
 	tempFile, err := ioutil.TempFile("./temp", "upload-*.csv")
 
 	if err != nil {
-		internal.RespondWithError(w, http.StatusBadRequest, err.Error())
-		return
+		return "", http.StatusBadRequest, err
 	}
 
 	defer tempFile.Close()
-	defer os.Remove(tempFile.Name())
 
 	fileBytes, err := ioutil.ReadAll(file)
 
+	if err == nil {
+		_, err = tempFile.Write(fileBytes)
+	}
+
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return "", http.StatusBadRequest, err
+	}
+
+	return tempFile.Name(), 0, nil
+}
+
+type stockMovementRequest struct {
+	Quantity  repository.Decimal  `json:"quantity"`
+	Unit      string              `json:"unit"`
+	Reason    string              `json:"reason"`
+	LotId     *string             `json:"lot_id"`
+	LotCode   string              `json:"lot_code"`
+	ExpiresAt *repository.Date    `json:"expires_at"`
+	UnitCost  *repository.Decimal `json:"unit_cost"`
+}
+
+func (s *Server) ReceiveWarehouseItemHandler(w http.ResponseWriter, r *http.Request) {
+	s.createStockMovement(w, r, repository.MovementReceipt)
+}
+
+func (s *Server) IssueWarehouseItemHandler(w http.ResponseWriter, r *http.Request) {
+	s.createStockMovement(w, r, repository.MovementIssue)
+}
+
+func (s *Server) createStockMovement(w http.ResponseWriter, r *http.Request, kind string) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req stockMovementRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	v.Positive("quantity", req.Quantity)
+	v.MaxLength("reason", req.Reason, 255)
+	v.MaxLength("lot_code", req.LotCode, 50)
+
+	if req.UnitCost != nil {
+		if kind != repository.MovementReceipt {
+			v.Add("unit_cost", "not_allowed", "unit_cost can only be set on receipts")
+		}
+
+		v.NonNegative("unit_cost", *req.UnitCost)
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	var wi repository.WarehouseItem
+
+	wi.Id = mux.Vars(r)["id"]
+
+	if err = wi.GetWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	quantity, err := wi.ToBaseUnits(req.Quantity, req.Unit)
+
+	if err != nil {
+		internal.RespondWithValidationErrors(w, internal.ValidationErrors{{Field: "unit", Code: "invalid", Message: err.Error()}})
+		return
+	}
+
+	if kind == repository.MovementIssue {
+		quantity = quantity.Neg()
+	}
+
+	m := repository.StockMovement{ItemId: wi.Id, Kind: kind, Quantity: quantity, Reason: req.Reason}
+
+	if kind == repository.MovementReceipt && (req.LotCode != "" || req.ExpiresAt != nil) {
+		m.Lot = &repository.Lot{Code: req.LotCode, ExpiresAt: req.ExpiresAt}
+	}
+
+	if req.UnitCost != nil {
+		cost, _ := wi.ToBaseUnitCost(*req.UnitCost, req.Unit)
+		m.UnitCost = &cost
+	}
+
+	if kind == repository.MovementIssue {
+		m.LotId = req.LotId
+	}
+
+	if err = m.Create(s.DB); err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) || errors.Is(err, repository.ErrExpiredStock) ||
+			errors.Is(err, repository.ErrItemArchived) || errors.Is(err, repository.ErrItemLocked) ||
+			errors.Is(err, repository.ErrStockReserved) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		if errors.Is(err, repository.ErrLotNotFound) {
+			internal.RespondWithError(w, http.StatusNotFound, "Lot not found")
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusCreated, m)
+}
+
+func (s *Server) GetWarehouseItemMovementsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	p, err := internal.ParsePageRequest(r.URL.Query())
+
 	if err != nil {
 		internal.RespondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	tempFile.Write(fileBytes)
+	page, err := repository.GetStockMovementsByItem(s.DB, mux.Vars(r)["id"], p)
+
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			internal.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
 
-	wil, err := internal.ParseCSV(tempFile.Name())
+	internal.SetPageHeaders(w, r.URL, page)
+	internal.RespondWithJSON(w, http.StatusOK, page.Items)
+}
+
+func (s *Server) GetWarehouseItemLotsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
 
 	if err != nil {
-		internal.RespondWithError(w, http.StatusNotAcceptable, err.Error())
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	for _, wi := range wil {
-		go func(curr repository.WarehouseItem) {
-			curr.UpSertWarehouseItem(s.DB)
-		}(wi)
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
 	}
 
-	internal.RespondWithJSON(w, http.StatusNoContent, nil)
+	lots, err := repository.GetLotsByItem(s.DB, mux.Vars(r)["id"])
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, lots)
+}
+
+func (s *Server) GetExpiringLotsHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	days := 30
+
+	if daysQuery := r.URL.Query().Get("days"); daysQuery != "" {
+		if days, err = strconv.Atoi(daysQuery); err != nil || days < 0 {
+			internal.RespondWithError(w, http.StatusBadRequest, "Invalid days parameter")
+			return
+		}
+	}
+
+	lots, err := repository.GetExpiringLots(s.DB, days)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, lots)
+}
+
+type adjustmentRequest struct {
+	Delta  repository.Decimal `json:"delta"`
+	Unit   string             `json:"unit"`
+	Reason string             `json:"reason"`
+	LotId  *string            `json:"lot_id"`
+}
+
+func (s *Server) AdjustWarehouseItemHandler(w http.ResponseWriter, r *http.Request) {
+	ah := r.Header.Get("Authorization")
+
+	token, err := internal.ValidateAuthHeader(ah)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	_, err = internal.VerifyToken(token)
+
+	if err != nil {
+		internal.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var req adjustmentRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	if err = decoder.Decode(&req); err != nil {
+		internal.RespondWithError(w, http.StatusBadRequest, invalidRequestPayloadError)
+		return
+	}
+
+	defer r.Body.Close()
+
+	var v internal.Validator
+
+	if req.Delta.IsZero() {
+		v.Add("delta", "zero", "delta must not be zero")
+	}
+
+	if v.Required("reason", req.Reason) {
+		v.MaxLength("reason", req.Reason, 255)
+	}
+
+	if len(v.Errors) > 0 {
+		internal.RespondWithValidationErrors(w, v.Errors)
+		return
+	}
+
+	var wi repository.WarehouseItem
+
+	wi.Id = mux.Vars(r)["id"]
+
+	if err = wi.GetWarehouseItemById(s.DB); err != nil {
+		internal.RespondWithError(w, http.StatusNotFound, "Item not found")
+		return
+	}
+
+	delta, err := wi.ToBaseUnits(req.Delta, req.Unit)
+
+	if err != nil {
+		internal.RespondWithValidationErrors(w, internal.ValidationErrors{{Field: "unit", Code: "invalid", Message: err.Error()}})
+		return
+	}
+
+	m := repository.StockMovement{
+		ItemId:   wi.Id,
+		Kind:     repository.MovementAdjustment,
+		Quantity: delta,
+		Reason:   req.Reason,
+		LotId:    req.LotId,
+	}
+
+	if err = m.Create(s.DB); err != nil {
+		if errors.Is(err, repository.ErrInsufficientStock) || errors.Is(err, repository.ErrItemArchived) ||
+			errors.Is(err, repository.ErrItemLocked) {
+			internal.RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+
+		if errors.Is(err, repository.ErrLotNotFound) {
+			internal.RespondWithError(w, http.StatusNotFound, "Lot not found")
+			return
+		}
+
+		internal.RespondWithError(w, http.StatusInternalServerError, internalServerError)
+		return
+	}
+
+	internal.RespondWithJSON(w, http.StatusOK, m)
 }
@@ -0,0 +1,55 @@
+// Command forecast recommends min and max levels for every item from its
+// issue history. It is meant to run periodically, e.g. monthly from cron.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/xsadia/secred/api"
+	"github.com/xsadia/secred/internal"
+)
+
+func main() {
+	godotenv.Load(".env")
+
+	p := internal.DefaultForecastParams()
+
+	vacations := flag.String("vacation-months", "1,7", "comma separated months without classes")
+	flag.StringVar(&p.Method, "method", p.Method, "moving_average or exponential_smoothing")
+	flag.IntVar(&p.Window, "window", p.Window, "months averaged by the moving average")
+	flag.Float64Var(&p.Alpha, "alpha", p.Alpha, "exponential smoothing factor")
+	flag.IntVar(&p.LeadTimeDays, "lead-time", p.LeadTimeDays, "days between ordering and receiving")
+	flag.IntVar(&p.ReviewDays, "review", p.ReviewDays, "days between orders")
+	flag.Float64Var(&p.ServiceZ, "service-z", p.ServiceZ, "safety stock z score")
+	flag.Parse()
+
+	var err error
+
+	if p.Calendar, err = internal.ParseSchoolCalendar(*vacations); err != nil {
+		log.Fatal(err)
+	}
+
+	if errs := p.Validate(); len(errs) > 0 {
+		log.Fatal(errs)
+	}
+
+	s := api.Server{}
+	s.InitializeDB(
+		os.Getenv("APP_DB_HOST"),
+		os.Getenv("APP_DB_USERNAME"),
+		os.Getenv("APP_DB_PASSWORD"),
+		os.Getenv("APP_DB_NAME"),
+	)
+
+	recs, err := internal.RunForecast(s.DB, p, time.Now())
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("%d recommendations pending review", len(recs))
+}
@@ -0,0 +1,50 @@
+// Command snapshot records every item's quantity at midnight so that point in
+// time queries only replay a day of movements. It is meant to run daily from
+// cron, some time after midnight; -days backfills earlier days.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/xsadia/secred/api"
+	"github.com/xsadia/secred/repository"
+)
+
+func main() {
+	godotenv.Load(".env")
+
+	days := flag.Int("days", 1, "number of past midnights to take snapshots at")
+	flag.Parse()
+
+	if *days < 1 {
+		log.Fatal("days must be at least 1")
+	}
+
+	s := api.Server{}
+	s.InitializeDB(
+		os.Getenv("APP_DB_HOST"),
+		os.Getenv("APP_DB_USERNAME"),
+		os.Getenv("APP_DB_PASSWORD"),
+		os.Getenv("APP_DB_NAME"),
+	)
+
+	now := time.Now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	// Newest first, so each earlier snapshot is worked out from the next one.
+	for d := 0; d < *days; d++ {
+		at := midnight.AddDate(0, 0, -d)
+
+		n, err := repository.TakeStockSnapshots(s.DB, at)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Printf("%d items recorded at %s", n, at.Format(time.RFC3339))
+	}
+}
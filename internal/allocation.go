@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"sort"
+
+	"github.com/xsadia/secred/repository"
+)
+
+type AllocationLine struct {
+	SchoolId   string             `json:"school_id"`
+	School     string             `json:"school"`
+	Enrollment int                `json:"enrollment"`
+	Packs      int64              `json:"packs"`
+	Quantity   repository.Decimal `json:"quantity"`
+}
+
+// AllocationPlan splits stock of an item between schools. Quantities are
+// whole multiples of Step, so whatever does not fill a step is Remaining.
+type AllocationPlan struct {
+	ItemId     string             `json:"item_id"`
+	Available  repository.Decimal `json:"available"`
+	Step       repository.Decimal `json:"step"`
+	Minimum    repository.Decimal `json:"minimum"`
+	MinimumMet bool               `json:"minimum_met"`
+	Allocated  repository.Decimal `json:"allocated"`
+	Remaining  repository.Decimal `json:"remaining"`
+	Lines      []AllocationLine   `json:"lines"`
+}
+
+// PlanAllocation splits available between the schools in proportion to their
+// enrollment, in whole steps such as a pack size. Every school first gets at
+// least minimum, rounded up to a step, and the rest is split proportionally,
+// with steps left over by rounding going to the largest remainders. When
+// there is not enough for every minimum, minimums are dropped and MinimumMet
+// is false. Schools without enrollment get nothing and are left out.
+func PlanAllocation(itemId string, available, step, minimum repository.Decimal, schools []repository.School) AllocationPlan {
+	if !step.IsPositive() {
+		step = repository.NewDecimal(1)
+	}
+
+	plan := AllocationPlan{
+		ItemId:     itemId,
+		Available:  available,
+		Step:       step,
+		Minimum:    minimum,
+		MinimumMet: true,
+		Lines:      []AllocationLine{},
+	}
+
+	var enrollment int64
+
+	for _, sc := range schools {
+		if sc.Enrollment > 0 {
+			plan.Lines = append(plan.Lines, AllocationLine{SchoolId: sc.Id, School: sc.Name, Enrollment: sc.Enrollment})
+			enrollment += int64(sc.Enrollment)
+		}
+	}
+
+	sort.SliceStable(plan.Lines, func(i, j int) bool {
+		return plan.Lines[i].Enrollment > plan.Lines[j].Enrollment
+	})
+
+	var packs int64
+
+	if available.IsPositive() {
+		packs = int64(available) / int64(step)
+	}
+
+	var minPacks int64
+
+	if minimum.IsPositive() {
+		minPacks = (int64(minimum) + int64(step) - 1) / int64(step)
+	}
+
+	if minPacks*int64(len(plan.Lines)) > packs {
+		plan.MinimumMet = minPacks == 0 || len(plan.Lines) == 0
+		minPacks = 0
+	}
+
+	if len(plan.Lines) > 0 {
+		rest := packs - minPacks*int64(len(plan.Lines))
+		remainders := make([]int64, len(plan.Lines))
+		left := rest
+
+		for i := range plan.Lines {
+			share := rest * int64(plan.Lines[i].Enrollment)
+			plan.Lines[i].Packs = minPacks + share/enrollment
+			remainders[i] = share % enrollment
+			left -= share / enrollment
+		}
+
+		order := make([]int, len(plan.Lines))
+
+		for i := range order {
+			order[i] = i
+		}
+
+		sort.SliceStable(order, func(i, j int) bool {
+			return remainders[order[i]] > remainders[order[j]]
+		})
+
+		for _, i := range order[:left] {
+			plan.Lines[i].Packs++
+		}
+	}
+
+	for i := range plan.Lines {
+		plan.Lines[i].Quantity = repository.Decimal(plan.Lines[i].Packs * int64(step))
+		plan.Allocated = plan.Allocated.Add(plan.Lines[i].Quantity)
+	}
+
+	plan.Remaining = available.Sub(plan.Allocated)
+
+	return plan
+}
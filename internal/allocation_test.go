@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
+
+func allocationSchools() []repository.School {
+	return []repository.School{
+		{Id: "1", Name: "pequena", Enrollment: 100},
+		{Id: "2", Name: "grande", Enrollment: 300},
+		{Id: "3", Name: "média", Enrollment: 200},
+		{Id: "4", Name: "fechada", Enrollment: 0},
+	}
+}
+
+func TestPlanAllocation(t *testing.T) {
+	t.Run("should split in proportion to enrollment", func(t *testing.T) {
+		plan := PlanAllocation("item", repository.NewDecimal(600), 0, 0, allocationSchools())
+
+		if len(plan.Lines) != 3 {
+			t.Fatalf("Expected schools without enrollment to be left out, got %v", plan.Lines)
+		}
+
+		if plan.Lines[0].SchoolId != "2" || plan.Lines[0].Quantity != repository.NewDecimal(300) ||
+			plan.Lines[2].SchoolId != "1" || plan.Lines[2].Quantity != repository.NewDecimal(100) {
+			t.Errorf("Expected 300, 200 and 100, got %v", plan.Lines)
+		}
+
+		if plan.Remaining != 0 {
+			t.Errorf("Expected nothing to remain, got %s", plan.Remaining)
+		}
+	})
+
+	t.Run("should round to whole packs", func(t *testing.T) {
+		plan := PlanAllocation("item", repository.NewDecimal(125), repository.NewDecimal(12), 0, allocationSchools())
+
+		// 10 packs of 12 split 5, 3.33 and 1.67: the 0.67 remainder wins.
+		if plan.Lines[0].Packs != 5 || plan.Lines[1].Packs != 3 || plan.Lines[2].Packs != 2 {
+			t.Errorf("Expected 5, 3 and 2 packs, got %v", plan.Lines)
+		}
+
+		if plan.Allocated != repository.NewDecimal(120) || plan.Remaining != repository.NewDecimal(5) {
+			t.Errorf("Expected 120 allocated and 5 remaining, got %s and %s", plan.Allocated, plan.Remaining)
+		}
+	})
+
+	t.Run("should give every school its minimum first", func(t *testing.T) {
+		plan := PlanAllocation("item", repository.NewDecimal(90), 0, repository.NewDecimal(20), allocationSchools())
+
+		if !plan.MinimumMet || plan.Lines[2].Quantity != repository.NewDecimal(25) {
+			t.Errorf("Expected the smallest school to get 25, got %v", plan.Lines)
+		}
+	})
+
+	t.Run("should drop minimums that cannot all be met", func(t *testing.T) {
+		plan := PlanAllocation("item", repository.NewDecimal(50), 0, repository.NewDecimal(20), allocationSchools())
+
+		if plan.MinimumMet || plan.Allocated != repository.NewDecimal(50) {
+			t.Errorf("Expected minimums to be dropped and 50 allocated, got %+v", plan)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+
+	"github.com/xsadia/secred/repository"
+)
+
+var (
+	semesterRegexp = regexp.MustCompile(`^(\d{4})-S([12])$`)
+
+	ErrInvalidSemester = errors.New("period must be a semester like 2026-S1")
+)
+
+type AllotmentLine struct {
+	ItemId   string             `json:"item_id"`
+	Item     string             `json:"item"`
+	Quantity repository.Decimal `json:"quantity"`
+	Unit     string             `json:"unit"`
+}
+
+// SchoolAllotment is what a school gets in the period. ShipmentId is set when
+// a shipment was already generated for it, and generating again skips it.
+type SchoolAllotment struct {
+	SchoolId   string          `json:"school_id"`
+	School     string          `json:"school"`
+	Enrollment int             `json:"enrollment"`
+	ShipmentId *string         `json:"shipment_id"`
+	Lines      []AllotmentLine `json:"lines"`
+}
+
+// AllotmentDemand is how much of an item the schools still to be served need
+// against what is available.
+type AllotmentDemand struct {
+	ItemId    string             `json:"item_id"`
+	Item      string             `json:"item"`
+	Required  repository.Decimal `json:"required"`
+	Available repository.Decimal `json:"available"`
+	Short     repository.Decimal `json:"short"`
+}
+
+type AllotmentPlan struct {
+	Period  string            `json:"period"`
+	Schools []SchoolAllotment `json:"schools"`
+	Items   []AllotmentDemand `json:"items"`
+}
+
+// ParseSemester parses periods like 2026-S1 into the year and the semester.
+func ParseSemester(s string) (int, int, error) {
+	m := semesterRegexp.FindStringSubmatch(s)
+
+	if m == nil {
+		return 0, 0, ErrInvalidSemester
+	}
+
+	year, _ := strconv.Atoi(m[1])
+	semester, _ := strconv.Atoi(m[2])
+
+	return year, semester, nil
+}
+
+// PlanAllotments works out what each school with students gets in the
+// semester: every allotment's quantity per student times its enrollment,
+// rounded up to whole units. Yearly allotments only count in the first
+// semester, and allotments of archived items not at all. shipments maps
+// schools already served in the period to their shipment, as
+// GetPeriodShipments returns it.
+func PlanAllotments(period string, semester int, allotments []repository.Allotment, schools []repository.School, shipments map[string]string) AllotmentPlan {
+	plan := AllotmentPlan{Period: period, Schools: []SchoolAllotment{}, Items: []AllotmentDemand{}}
+
+	due := []repository.Allotment{}
+	demand := map[string]int{}
+
+	for _, a := range allotments {
+		if a.Archived || a.Period == repository.AllotmentYear && semester != 1 {
+			continue
+		}
+
+		due = append(due, a)
+
+		if _, ok := demand[a.ItemId]; !ok {
+			demand[a.ItemId] = len(plan.Items)
+			plan.Items = append(plan.Items, AllotmentDemand{ItemId: a.ItemId, Item: a.ItemName, Available: a.Available})
+		}
+	}
+
+	for _, sc := range schools {
+		if sc.Enrollment <= 0 {
+			continue
+		}
+
+		sa := SchoolAllotment{SchoolId: sc.Id, School: sc.Name, Enrollment: sc.Enrollment, Lines: []AllotmentLine{}}
+
+		if id, ok := shipments[sc.Id]; ok {
+			sa.ShipmentId = &id
+		}
+
+		lines := map[string]int{}
+
+		for _, a := range due {
+			i := demand[a.ItemId]
+			quantity := a.Quantity.Mul(repository.NewDecimal(int64(sc.Enrollment))).Ceil()
+
+			if j, ok := lines[a.ItemId]; ok {
+				sa.Lines[j].Quantity = sa.Lines[j].Quantity.Add(quantity)
+			} else {
+				lines[a.ItemId] = len(sa.Lines)
+				sa.Lines = append(sa.Lines, AllotmentLine{ItemId: a.ItemId, Item: a.ItemName, Quantity: quantity, Unit: a.Unit})
+			}
+
+			if sa.ShipmentId == nil {
+				plan.Items[i].Required = plan.Items[i].Required.Add(quantity)
+			}
+		}
+
+		if len(sa.Lines) > 0 {
+			plan.Schools = append(plan.Schools, sa)
+		}
+	}
+
+	for i := range plan.Items {
+		if short := plan.Items[i].Required.Sub(plan.Items[i].Available); short.IsPositive() {
+			plan.Items[i].Short = short
+		}
+	}
+
+	return plan
+}
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
+
+func TestParseSemester(t *testing.T) {
+	if year, semester, err := ParseSemester("2026-S2"); err != nil || year != 2026 || semester != 2 {
+		t.Errorf("Expected 2026 and 2, got %d, %d and %v", year, semester, err)
+	}
+
+	for _, s := range []string{"2026", "2026-S3", "26-S1", "2026-s1"} {
+		if _, _, err := ParseSemester(s); err == nil {
+			t.Errorf("Expected an error for %q, got none", s)
+		}
+	}
+}
+
+func TestPlanAllotments(t *testing.T) {
+	quarter, _ := repository.ParseDecimal("0.25")
+
+	allotments := []repository.Allotment{
+		{ItemId: "caderno", ItemName: "caderno", Quantity: repository.NewDecimal(2), Period: repository.AllotmentSemester, Available: repository.NewDecimal(100)},
+		{ItemId: "caderno", ItemName: "caderno", Quantity: repository.NewDecimal(1), Period: repository.AllotmentYear, Available: repository.NewDecimal(100)},
+		{ItemId: "cola", ItemName: "cola", Quantity: quarter, Period: repository.AllotmentSemester, Available: repository.NewDecimal(100)},
+	}
+
+	schools := []repository.School{
+		{Id: "1", Name: "grande", Enrollment: 30},
+		{Id: "2", Name: "pequena", Enrollment: 5},
+		{Id: "3", Name: "fechada", Enrollment: 0},
+	}
+
+	t.Run("should multiply by enrollment and round up", func(t *testing.T) {
+		plan := PlanAllotments("2026-S1", 1, allotments, schools, nil)
+
+		if len(plan.Schools) != 2 {
+			t.Fatalf("Expected schools without students to be left out, got %v", plan.Schools)
+		}
+
+		small := plan.Schools[1]
+
+		if len(small.Lines) != 2 || small.Lines[0].Quantity != repository.NewDecimal(15) || small.Lines[1].Quantity != repository.NewDecimal(2) {
+			t.Errorf("Expected 15 notebooks and 2 glues, got %v", small.Lines)
+		}
+
+		if plan.Items[0].Required != repository.NewDecimal(105) || plan.Items[0].Short != repository.NewDecimal(5) {
+			t.Errorf("Expected 105 notebooks to be required and 5 short, got %v", plan.Items[0])
+		}
+	})
+
+	t.Run("should leave yearly allotments to the first semester", func(t *testing.T) {
+		plan := PlanAllotments("2026-S2", 2, allotments, schools, nil)
+
+		if plan.Schools[0].Lines[0].Quantity != repository.NewDecimal(60) {
+			t.Errorf("Expected 60 notebooks, got %v", plan.Schools[0].Lines)
+		}
+	})
+
+	t.Run("should leave out archived items", func(t *testing.T) {
+		archived := append(allotments, repository.Allotment{
+			ItemId: "lápis", ItemName: "lápis", Quantity: repository.NewDecimal(3), Period: repository.AllotmentSemester, Archived: true,
+		})
+
+		plan := PlanAllotments("2026-S2", 2, archived, schools, nil)
+
+		if len(plan.Items) != 2 || len(plan.Schools[0].Lines) != 2 {
+			t.Errorf("Expected only notebooks and glue, got %v", plan.Items)
+		}
+	})
+
+	t.Run("should not count schools already served", func(t *testing.T) {
+		plan := PlanAllotments("2026-S2", 2, allotments, schools, map[string]string{"1": "shipment"})
+
+		if plan.Schools[0].ShipmentId == nil || plan.Items[0].Required != repository.NewDecimal(10) {
+			t.Errorf("Expected only the small school to be required, got %v", plan.Items)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	errInvalidBarcodeLength = errors.New("barcode must have 8, 12, 13 or 14 digits")
+	errInvalidBarcodeDigits = errors.New("barcode must only contain digits")
+	errInvalidCheckDigit    = errors.New("invalid barcode check digit")
+)
+
+// NormalizeGTIN validates an EAN-8, UPC-A, EAN-13 or GTIN-14 code and returns
+// it left padded with zeros to 14 digits, so the same product is found no
+// matter which form the scanner emits.
+func NormalizeGTIN(code string) (string, error) {
+	code = strings.TrimSpace(code)
+
+	switch len(code) {
+	case 8, 12, 13, 14:
+	default:
+		return "", errInvalidBarcodeLength
+	}
+
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return "", errInvalidBarcodeDigits
+		}
+	}
+
+	if gtinCheckDigit(code[:len(code)-1]) != code[len(code)-1] {
+		return "", errInvalidCheckDigit
+	}
+
+	return strings.Repeat("0", 14-len(code)) + code, nil
+}
+
+// gtinCheckDigit computes the GS1 mod 10 check digit: digits are weighted 3
+// and 1 alternately starting from the rightmost one.
+func gtinCheckDigit(digits string) byte {
+	sum := 0
+
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+
+		if (len(digits)-1-i)%2 == 0 {
+			d *= 3
+		}
+
+		sum += d
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,44 @@
+package internal
+
+import "testing"
+
+func TestNormalizeGTIN(t *testing.T) {
+	t.Run("should accept valid codes and pad them to 14 digits", func(t *testing.T) {
+		cases := map[string]string{
+			"7891000100103":  "07891000100103",
+			"96385074":       "00000096385074",
+			"036000291452":   "00036000291452",
+			"17891000100100": "17891000100100",
+		}
+
+		for in, expected := range cases {
+			got, err := NormalizeGTIN(in)
+
+			if err != nil {
+				t.Errorf("Expected no error for %q, got %q", in, err.Error())
+			}
+
+			if got != expected {
+				t.Errorf("Expected %q, got %q", expected, got)
+			}
+		}
+	})
+
+	t.Run("should return error if check digit is wrong", func(t *testing.T) {
+		_, err := NormalizeGTIN("7891000100104")
+
+		if err != errInvalidCheckDigit {
+			t.Errorf("Expected error %q, got %v", errInvalidCheckDigit, err)
+		}
+	})
+
+	t.Run("should return error if code is malformed", func(t *testing.T) {
+		if _, err := NormalizeGTIN("12345"); err != errInvalidBarcodeLength {
+			t.Errorf("Expected error %q, got %v", errInvalidBarcodeLength, err)
+		}
+
+		if _, err := NormalizeGTIN("789100010010A"); err != errInvalidBarcodeDigits {
+			t.Errorf("Expected error %q, got %v", errInvalidBarcodeDigits, err)
+		}
+	})
+}
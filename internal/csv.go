@@ -3,9 +3,9 @@ package internal
 import (
 	"encoding/csv"
 	"errors"
+	"fmt"
 	"net/textproto"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/xsadia/secred/repository"
@@ -13,14 +13,17 @@ import (
 
 const badCSVHeadersError = "bad headers on CSV file"
 
-// TODO upgrade to Go 1.18 to use generics for school items as well
-func newWarehouseItemListFromCSV(lines [][]string) ([]repository.WarehouseItem, error) {
-	wil := make([]repository.WarehouseItem, len(lines)-1)
+// csvHeaderIndexes maps the lowercased headers on the first line to their
+// column, failing when a header is repeated or a required one is missing.
+func csvHeaderIndexes(lines [][]string, required ...string) (map[string]int, error) {
+	if len(lines) == 0 {
+		return nil, errors.New(badCSVHeadersError)
+	}
+
 	indexes := make(map[string]int, len(lines[0]))
 
 	for i, v := range lines[0] {
-
-		lowerV := strings.ToLower(v)
+		lowerV := strings.ToLower(strings.TrimSpace(v))
 
 		if _, ok := indexes[lowerV]; ok {
 			return nil, errors.New(badCSVHeadersError)
@@ -29,28 +32,62 @@ func newWarehouseItemListFromCSV(lines [][]string) ([]repository.WarehouseItem,
 		indexes[lowerV] = i
 	}
 
-	_, nameOk := indexes["name"]
-	_, quantityOk := indexes["quantity"]
-	_, minOk := indexes["min"]
-	_, maxOk := indexes["max"]
+	for _, h := range required {
+		if _, ok := indexes[h]; !ok {
+			return nil, errors.New(badCSVHeadersError)
+		}
+	}
+
+	return indexes, nil
+}
 
-	if !nameOk || !quantityOk || !minOk || !maxOk {
-		return nil, errors.New(badCSVHeadersError)
+func newWarehouseItemListFromCSV(lines [][]string) ([]repository.WarehouseItem, error) {
+	indexes, err := csvHeaderIndexes(lines, "name", "quantity", "min", "max")
+
+	if err != nil {
+		return nil, err
 	}
 
-	// Sadly O(n2) because I have to use Atoi and ToLower
+	wil := make([]repository.WarehouseItem, len(lines)-1)
+
+	// Sadly O(n2) because I have to use ParseDecimal and NormalizeName
 	for i := 1; i < len(lines); i++ {
 
 		var wi repository.WarehouseItem
 
-		quantity, _ := strconv.Atoi(lines[i][indexes["quantity"]])
-		min, _ := strconv.Atoi(lines[i][indexes["min"]])
-		max, _ := strconv.Atoi(lines[i][indexes["max"]])
+		if wi.Quantity, err = repository.ParseDecimal(lines[i][indexes["quantity"]]); err != nil {
+			return nil, fmt.Errorf("invalid quantity on line %d", i+1)
+		}
+
+		if wi.Min, err = repository.ParseDecimal(lines[i][indexes["min"]]); err != nil {
+			return nil, fmt.Errorf("invalid min on line %d", i+1)
+		}
+
+		if wi.Max, err = repository.ParseDecimal(lines[i][indexes["max"]]); err != nil {
+			return nil, fmt.Errorf("invalid max on line %d", i+1)
+		}
+
+		wi.Name = repository.NormalizeName(lines[i][indexes["name"]])
+
+		if idx, ok := indexes["category"]; ok {
+			wi.CategoryName = strings.TrimSpace(lines[i][idx])
+		}
+
+		if idx, ok := indexes["unit"]; ok {
+			wi.Unit = strings.ToLower(strings.TrimSpace(lines[i][idx]))
+		}
+
+		if idx, ok := indexes["pack_unit"]; ok {
+			if packUnit := strings.ToLower(strings.TrimSpace(lines[i][idx])); packUnit != "" {
+				wi.PackUnit = &packUnit
+			}
+		}
 
-		wi.Name = strings.ToLower(lines[i][indexes["name"]])
-		wi.Quantity = int32(quantity)
-		wi.Min = int32(min)
-		wi.Max = int32(max)
+		if idx, ok := indexes["pack_size"]; ok && strings.TrimSpace(lines[i][idx]) != "" {
+			if wi.PackSize, err = repository.ParseDecimal(lines[i][idx]); err != nil || !wi.PackSize.IsPositive() {
+				return nil, fmt.Errorf("invalid pack_size on line %d", i+1)
+			}
+		}
 
 		wil[i-1] = wi
 	}
@@ -58,27 +95,64 @@ func newWarehouseItemListFromCSV(lines [][]string) ([]repository.WarehouseItem,
 	return wil, nil
 }
 
-func ParseCSV(fileName string) ([]repository.WarehouseItem, error) {
+// newStudentListFromCSV reads a school roster with enrollment_id, name and an
+// optional grade column.
+func newStudentListFromCSV(lines [][]string) ([]repository.Student, error) {
+	indexes, err := csvHeaderIndexes(lines, "enrollment_id", "name")
+
+	if err != nil {
+		return nil, err
+	}
+
+	students := make([]repository.Student, len(lines)-1)
+
+	for i := 1; i < len(lines); i++ {
+		st := repository.Student{
+			EnrollmentId: strings.TrimSpace(lines[i][indexes["enrollment_id"]]),
+			Name:         strings.Join(strings.Fields(lines[i][indexes["name"]]), " "),
+		}
+
+		if idx, ok := indexes["grade"]; ok {
+			st.Grade = repository.NormalizeName(lines[i][idx])
+		}
+
+		students[i-1] = st
+	}
+
+	return students, nil
+}
+
+func readCSV(fileName string) ([][]string, error) {
 	f, err := os.Open(fileName)
 
 	if err != nil {
 		return nil, err
 	}
 
-	csvReader := csv.NewReader(f)
-	rec, err := csvReader.ReadAll()
+	defer f.Close()
+
+	return csv.NewReader(f).ReadAll()
+}
+
+func ParseCSV(fileName string) ([]repository.WarehouseItem, error) {
+	rec, err := readCSV(fileName)
 
 	if err != nil {
 		return nil, err
 	}
 
-	wil, err := newWarehouseItemListFromCSV(rec)
+	return newWarehouseItemListFromCSV(rec)
+}
+
+// ParseRosterCSV reads the students of a school roster file.
+func ParseRosterCSV(fileName string) ([]repository.Student, error) {
+	rec, err := readCSV(fileName)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return wil, nil
+	return newStudentListFromCSV(rec)
 }
 
 func CheckMIMEContentType(header textproto.MIMEHeader) error {
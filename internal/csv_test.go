@@ -1,6 +1,10 @@
 package internal
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
 
 func TestNewWarehouseItemListFromCSV(t *testing.T) {
 	t.Run("should return warehouse item list", func(t *testing.T) {
@@ -19,18 +23,62 @@ func TestNewWarehouseItemListFromCSV(t *testing.T) {
 			t.Errorf("Expected first item's name to be \"rice\", got %q", wil[0].Name)
 		}
 
-		if wil[0].Max != 50 {
-			t.Errorf("Expected first item's max to be 50, got %d", wil[0].Max)
+		if wil[0].Max != repository.NewDecimal(50) {
+			t.Errorf("Expected first item's max to be 50, got %s", wil[0].Max)
+		}
+
+		if wil[0].Min != repository.NewDecimal(25) {
+			t.Errorf("Expected first item's min to be 25, got %s", wil[0].Min)
+		}
+
+		if wil[0].Quantity != repository.NewDecimal(27) {
+			t.Errorf("Expected first item's quantity to be 27, got %s", wil[0].Quantity)
+		}
+
+	})
+
+	t.Run("should parse fractional quantities and units", func(t *testing.T) {
+		validInput := [][]string{
+			{"name", "max", "min", "quantity", "category", "unit", "pack_unit", "pack_size"},
+			{"Milk", "100", "20", "12,5", "Food", "L", "Box", "12"},
+		}
+
+		wil, err := newWarehouseItemListFromCSV(validInput)
+
+		if err != nil {
+			t.Fatalf("Expected no error. Got %q", err.Error())
+		}
+
+		if wil[0].Quantity.String() != "12.5" {
+			t.Errorf("Expected first item's quantity to be 12.5, got %s", wil[0].Quantity)
 		}
 
-		if wil[0].Min != 25 {
-			t.Errorf("Expected first item's min to be 25, got %d", wil[0].Min)
+		if wil[0].CategoryName != "Food" {
+			t.Errorf("Expected first item's category to be \"Food\", got %q", wil[0].CategoryName)
 		}
 
-		if wil[0].Quantity != 27 {
-			t.Errorf("Expected first item's quantity to be 27, got %d", wil[0].Quantity)
+		if wil[0].Unit != "l" {
+			t.Errorf("Expected first item's unit to be \"l\", got %q", wil[0].Unit)
 		}
 
+		if wil[0].PackUnit == nil || *wil[0].PackUnit != "box" {
+			t.Errorf("Expected first item's pack unit to be \"box\", got %v", wil[0].PackUnit)
+		}
+
+		if wil[0].PackSize != repository.NewDecimal(12) {
+			t.Errorf("Expected first item's pack size to be 12, got %s", wil[0].PackSize)
+		}
+	})
+
+	t.Run("should return error if a quantity is not a number", func(t *testing.T) {
+		invalidInput := [][]string{
+			{"name", "max", "min", "quantity"},
+			{"Rice", "50", "25", "lots"},
+		}
+
+		if _, err := newWarehouseItemListFromCSV(invalidInput); err == nil {
+			t.Error("Expected an error got none")
+		}
 	})
 
 	t.Run("Should return error if headers are not correct", func(t *testing.T) {
@@ -50,3 +98,30 @@ func TestNewWarehouseItemListFromCSV(t *testing.T) {
 		}
 	})
 }
+
+func TestNewStudentListFromCSV(t *testing.T) {
+	t.Run("should return student list", func(t *testing.T) {
+		validInput := [][]string{
+			{"Name", "enrollment_id", "grade"},
+			{" Ana  Souza ", " 2023001 ", "5º Ano"},
+		}
+
+		students, err := newStudentListFromCSV(validInput)
+
+		if err != nil {
+			t.Fatalf("Expected no error. Got %q", err.Error())
+		}
+
+		if students[0].Name != "Ana Souza" || students[0].EnrollmentId != "2023001" || students[0].Grade != "5º ano" {
+			t.Errorf("Expected Ana Souza, 2023001, 5º ano, got %v", students[0])
+		}
+	})
+
+	t.Run("should return error if enrollment_id is missing", func(t *testing.T) {
+		_, err := newStudentListFromCSV([][]string{{"name", "grade"}, {"Ana", "5"}})
+
+		if err == nil || err.Error() != badCSVHeadersError {
+			t.Errorf("Expected error to be %q, got %v", badCSVHeadersError, err)
+		}
+	})
+}
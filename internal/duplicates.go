@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/xsadia/secred/repository"
+)
+
+// DefaultDuplicateThreshold is the similarity above which two item names are
+// reported as likely duplicates.
+const DefaultDuplicateThreshold = 0.8
+
+// DuplicateCandidate is a pair of items whose names are alike enough to
+// probably be the same item.
+type DuplicateCandidate struct {
+	Item       repository.ItemName `json:"item"`
+	Duplicate  repository.ItemName `json:"duplicate"`
+	Similarity float64             `json:"similarity"`
+}
+
+// FindDuplicates compares every pair of names by their keys and returns the
+// pairs at least threshold similar, most similar first. Names are compared
+// both as written and with their words sorted, so "caderno brochura" matches
+// "brochura caderno".
+func FindDuplicates(names []repository.ItemName, threshold float64) []DuplicateCandidate {
+	sorted := make([]string, len(names))
+
+	for i, n := range names {
+		words := strings.Fields(n.Key)
+		sort.Strings(words)
+		sorted[i] = strings.Join(words, " ")
+	}
+
+	candidates := []DuplicateCandidate{}
+
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			similarity := math.Max(Similarity(names[i].Key, names[j].Key), Similarity(sorted[i], sorted[j]))
+
+			if similarity >= threshold {
+				candidates = append(candidates, DuplicateCandidate{
+					Item:       names[i],
+					Duplicate:  names[j],
+					Similarity: round2(similarity),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Similarity > candidates[j].Similarity
+	})
+
+	return candidates
+}
+
+// Similarity is one minus the edit distance between a and b relative to the
+// longer of the two, so equal strings are 1 and unrelated ones near 0.
+func Similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+
+	if longest == 0 {
+		return 1
+	}
+
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
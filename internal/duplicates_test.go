@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
+
+func TestSimilarity(t *testing.T) {
+	if s := Similarity("lapis", "lapis"); s != 1 {
+		t.Errorf("Expected equal names to be 1, got %v", s)
+	}
+
+	if s := Similarity("caderno", "cadernos"); s < 0.85 {
+		t.Errorf("Expected a plural to be similar, got %v", s)
+	}
+
+	if s := Similarity("caderno", "borracha"); s > 0.3 {
+		t.Errorf("Expected unrelated names not to be similar, got %v", s)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	names := []repository.ItemName{
+		{Id: "1", Name: "lápis", Key: "lapis"},
+		{Id: "2", Name: "lapis", Key: "lapis"},
+		{Id: "3", Name: "caderno brochura", Key: "caderno brochura"},
+		{Id: "4", Name: "brochura caderno", Key: "brochura caderno"},
+		{Id: "5", Name: "borracha", Key: "borracha"},
+	}
+
+	candidates := FindDuplicates(names, DefaultDuplicateThreshold)
+
+	if len(candidates) != 2 {
+		t.Fatalf("Expected 2 candidates, got %v", candidates)
+	}
+
+	for _, c := range candidates {
+		if c.Similarity != 1 {
+			t.Errorf("Expected similarity 1, got %v", c)
+		}
+	}
+
+	if candidates[0].Item.Id != "1" || candidates[0].Duplicate.Id != "2" {
+		t.Errorf("Expected lápis and lapis first, got %v", candidates[0])
+	}
+}
@@ -0,0 +1,68 @@
+package internal
+
+import "github.com/xsadia/secred/repository"
+
+// MissingHandout is a student who has not yet received all they are entitled
+// to.
+type MissingHandout struct {
+	StudentId    string             `json:"student_id"`
+	EnrollmentId string             `json:"enrollment_id"`
+	Name         string             `json:"name"`
+	Grade        string             `json:"grade"`
+	Received     repository.Decimal `json:"received"`
+	Missing      repository.Decimal `json:"missing"`
+}
+
+type EntitlementStatus struct {
+	EntitlementId string             `json:"entitlement_id"`
+	Name          string             `json:"name"`
+	Quantity      repository.Decimal `json:"quantity"`
+	Students      int                `json:"students"`
+	Fulfilled     int                `json:"fulfilled"`
+	Missing       []MissingHandout   `json:"missing"`
+}
+
+type EntitlementReport struct {
+	SchoolId     string              `json:"school_id"`
+	Year         int                 `json:"year"`
+	Entitlements []EntitlementStatus `json:"entitlements"`
+}
+
+// NewEntitlementReport tells, for each entitlement, how many students received
+// everything they are due and who is still missing items. rows must be
+// grouped by entitlement, as GetStudentEntitlements returns them.
+func NewEntitlementReport(schoolId string, year int, rows []repository.StudentEntitlement) EntitlementReport {
+	report := EntitlementReport{SchoolId: schoolId, Year: year, Entitlements: []EntitlementStatus{}}
+
+	for _, r := range rows {
+		n := len(report.Entitlements)
+
+		if n == 0 || report.Entitlements[n-1].EntitlementId != r.EntitlementId {
+			report.Entitlements = append(report.Entitlements, EntitlementStatus{
+				EntitlementId: r.EntitlementId,
+				Name:          r.Entitlement,
+				Quantity:      r.Quantity,
+				Missing:       []MissingHandout{},
+			})
+			n++
+		}
+
+		status := &report.Entitlements[n-1]
+		status.Students++
+
+		if missing := r.Quantity.Sub(r.Received); missing.IsPositive() {
+			status.Missing = append(status.Missing, MissingHandout{
+				StudentId:    r.StudentId,
+				EnrollmentId: r.EnrollmentId,
+				Name:         r.StudentName,
+				Grade:        r.Grade,
+				Received:     r.Received,
+				Missing:      missing,
+			})
+		} else {
+			status.Fulfilled++
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
+
+func TestEntitlementReport(t *testing.T) {
+	kit := func(student string, received int64) repository.StudentEntitlement {
+		return repository.StudentEntitlement{
+			EntitlementId: "kit", Entitlement: "kit escolar", Quantity: repository.NewDecimal(1),
+			StudentId: student, StudentName: student, Received: repository.NewDecimal(received),
+		}
+	}
+
+	shirts := repository.StudentEntitlement{
+		EntitlementId: "shirts", Entitlement: "camisetas", Quantity: repository.NewDecimal(2),
+		StudentId: "ana", StudentName: "ana", Received: repository.NewDecimal(1),
+	}
+
+	report := NewEntitlementReport("school", 2022, []repository.StudentEntitlement{
+		kit("ana", 1), kit("bia", 0), kit("caio", 2), shirts,
+	})
+
+	if len(report.Entitlements) != 2 {
+		t.Fatalf("Expected 2 entitlements, got %v", report.Entitlements)
+	}
+
+	k := report.Entitlements[0]
+
+	if k.Students != 3 || k.Fulfilled != 2 || len(k.Missing) != 1 || k.Missing[0].StudentId != "bia" {
+		t.Errorf("Expected bia to be missing the kit, got %v", k)
+	}
+
+	s := report.Entitlements[1]
+
+	if len(s.Missing) != 1 || s.Missing[0].Missing != repository.NewDecimal(1) {
+		t.Errorf("Expected ana to be missing 1 shirt, got %v", s)
+	}
+}
@@ -0,0 +1,318 @@
+package internal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xsadia/secred/repository"
+)
+
+const (
+	ForecastMovingAverage        = "moving_average"
+	ForecastExponentialSmoothing = "exponential_smoothing"
+
+	daysPerMonth = 30.0
+)
+
+var errInvalidVacationMonths = errors.New("vacation months must be numbers between 1 and 12")
+
+// SchoolCalendar marks the months with no classes. Consumption in those
+// months is expected to differ from the rest of the year.
+type SchoolCalendar [12]bool
+
+// DefaultSchoolCalendar has the January and July school holidays.
+var DefaultSchoolCalendar = SchoolCalendar{time.January - 1: true, time.July - 1: true}
+
+func (c SchoolCalendar) isVacation(m time.Month) bool {
+	return c[m-1]
+}
+
+// ParseSchoolCalendar parses a comma separated list of vacation months such as
+// "1,7,12".
+func ParseSchoolCalendar(s string) (SchoolCalendar, error) {
+	var c SchoolCalendar
+
+	for _, part := range strings.Split(s, ",") {
+		m, err := strconv.Atoi(strings.TrimSpace(part))
+
+		if err != nil || m < 1 || m > 12 {
+			return c, errInvalidVacationMonths
+		}
+
+		c[m-1] = true
+	}
+
+	return c, nil
+}
+
+type ForecastParams struct {
+	Method       string         `json:"method"`
+	Window       int            `json:"window"`
+	Alpha        float64        `json:"alpha"`
+	LeadTimeDays int            `json:"lead_time_days"`
+	ReviewDays   int            `json:"review_days"`
+	ServiceZ     float64        `json:"service_z"`
+	Calendar     SchoolCalendar `json:"-"`
+}
+
+// DefaultForecastParams smooths the last months' consumption and keeps
+// enough stock for a two week lead time at a 95% service level, reordering
+// monthly.
+func DefaultForecastParams() ForecastParams {
+	return ForecastParams{
+		Method:       ForecastExponentialSmoothing,
+		Window:       3,
+		Alpha:        0.3,
+		LeadTimeDays: 15,
+		ReviewDays:   30,
+		ServiceZ:     1.65,
+		Calendar:     DefaultSchoolCalendar,
+	}
+}
+
+func (p ForecastParams) Validate() ValidationErrors {
+	var v Validator
+
+	v.OneOf("method", p.Method, ForecastMovingAverage, ForecastExponentialSmoothing)
+
+	if p.Window < 1 || p.Window > 24 {
+		v.Add("window", "out_of_range", "window must be between 1 and 24 months")
+	}
+
+	if p.Alpha <= 0 || p.Alpha > 1 {
+		v.Add("alpha", "out_of_range", "alpha must be greater than 0 and at most 1")
+	}
+
+	if p.LeadTimeDays < 0 || p.LeadTimeDays > 365 {
+		v.Add("lead_time_days", "out_of_range", "lead_time_days must be between 0 and 365")
+	}
+
+	if p.ReviewDays < 1 || p.ReviewDays > 365 {
+		v.Add("review_days", "out_of_range", "review_days must be between 1 and 365")
+	}
+
+	if p.ServiceZ < 0 || p.ServiceZ > 4 {
+		v.Add("service_z", "out_of_range", "service_z must be between 0 and 4")
+	}
+
+	return v.Errors
+}
+
+// Rationale explains how a recommendation was reached.
+type Rationale struct {
+	Method         string             `json:"method"`
+	Months         int                `json:"months"`
+	AverageMonthly repository.Decimal `json:"average_monthly"`
+	SessionIndex   float64            `json:"session_index"`
+	VacationIndex  float64            `json:"vacation_index"`
+	LeadTimeDemand repository.Decimal `json:"lead_time_demand"`
+	ReviewDemand   repository.Decimal `json:"review_demand"`
+	SafetyStock    repository.Decimal `json:"safety_stock"`
+	Summary        string             `json:"summary"`
+}
+
+// Recommend forecasts consumption from monthly issue totals, oldest first and
+// ending with the last full month before now, and recommends min and max.
+// Min covers the lead time demand plus safety stock and max adds a review
+// period's demand. It returns false when nothing was issued.
+func Recommend(p ForecastParams, monthly []repository.Decimal, lastMonth time.Time, now time.Time) (repository.Decimal, repository.Decimal, Rationale, bool) {
+	r := Rationale{Method: p.Method, Months: len(monthly), SessionIndex: 1, VacationIndex: 1}
+
+	if len(monthly) == 0 {
+		return 0, 0, r, false
+	}
+
+	months := make([]time.Month, len(monthly))
+	values := make([]float64, len(monthly))
+
+	var total, sessionTotal, vacationTotal float64
+	var sessions, vacations int
+
+	for i, q := range monthly {
+		months[i] = lastMonth.AddDate(0, i-len(monthly)+1, 0).Month()
+		values[i] = q.Float64()
+		total += values[i]
+
+		if p.Calendar.isVacation(months[i]) {
+			vacationTotal += values[i]
+			vacations++
+		} else {
+			sessionTotal += values[i]
+			sessions++
+		}
+	}
+
+	if total <= 0 {
+		return 0, 0, r, false
+	}
+
+	average := total / float64(len(values))
+	r.AverageMonthly = repository.DecimalFromFloat(average)
+
+	// Seasonal indices need both kinds of month in the history, otherwise
+	// every month is treated alike.
+	if sessions > 0 && vacations > 0 {
+		r.SessionIndex = round2(sessionTotal / float64(sessions) / average)
+		r.VacationIndex = round2(vacationTotal / float64(vacations) / average)
+	}
+
+	index := func(m time.Month) float64 {
+		if p.Calendar.isVacation(m) {
+			return r.VacationIndex
+		}
+
+		return r.SessionIndex
+	}
+
+	// Months whose index is zero carry no information about the level.
+	deseasonalized := []float64{}
+
+	for i, v := range values {
+		if idx := index(months[i]); idx > 0 {
+			deseasonalized = append(deseasonalized, v/idx)
+		}
+	}
+
+	level := smooth(p, deseasonalized)
+	sigma := stddev(deseasonalized)
+
+	rate := func(day time.Time) float64 {
+		return level * index(day.Month()) / daysPerMonth
+	}
+
+	var leadTimeDemand, reviewDemand float64
+
+	for d := 0; d < p.LeadTimeDays+p.ReviewDays; d++ {
+		if d < p.LeadTimeDays {
+			leadTimeDemand += rate(now.AddDate(0, 0, d))
+		} else {
+			reviewDemand += rate(now.AddDate(0, 0, d))
+		}
+	}
+
+	safety := p.ServiceZ * sigma * math.Sqrt(float64(p.LeadTimeDays)/daysPerMonth)
+
+	r.LeadTimeDemand = repository.DecimalFromFloat(leadTimeDemand)
+	r.ReviewDemand = repository.DecimalFromFloat(reviewDemand)
+	r.SafetyStock = repository.DecimalFromFloat(safety)
+
+	min := r.LeadTimeDemand.Add(r.SafetyStock).Ceil()
+	max := min.Add(r.ReviewDemand.Ceil())
+
+	r.Summary = fmt.Sprintf(
+		"%d months of issues averaging %s a month (%s, session index %.2f, vacation index %.2f): "+
+			"%s expected over the %d day lead time plus %s safety stock gives min %s; "+
+			"%s more for the %d day review period gives max %s",
+		r.Months, r.AverageMonthly, methodName(p), r.SessionIndex, r.VacationIndex,
+		r.LeadTimeDemand, p.LeadTimeDays, r.SafetyStock, min,
+		r.ReviewDemand, p.ReviewDays, max,
+	)
+
+	return min, max, r, true
+}
+
+func methodName(p ForecastParams) string {
+	if p.Method == ForecastMovingAverage {
+		return fmt.Sprintf("%d month moving average", p.Window)
+	}
+
+	return fmt.Sprintf("exponential smoothing with alpha %.2f", p.Alpha)
+}
+
+func smooth(p ForecastParams, values []float64) float64 {
+	if p.Method == ForecastMovingAverage {
+		window := values
+
+		if len(window) > p.Window {
+			window = window[len(window)-p.Window:]
+		}
+
+		var sum float64
+
+		for _, v := range window {
+			sum += v
+		}
+
+		return sum / float64(len(window))
+	}
+
+	level := values[0]
+
+	for _, v := range values[1:] {
+		level = p.Alpha*v + (1-p.Alpha)*level
+	}
+
+	return level
+}
+
+func stddev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	var mean float64
+
+	for _, v := range values {
+		mean += v
+	}
+
+	mean /= float64(len(values))
+
+	var variance float64
+
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+
+	return math.Sqrt(variance / float64(len(values)-1))
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// RunForecast recommends min and max for every active item from up to two
+// years of issues before the current month, replacing the pending
+// recommendations. Items whose levels already match are left out.
+func RunForecast(db *sql.DB, p ForecastParams, now time.Time) ([]repository.Recommendation, error) {
+	until := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	issues, err := repository.GetMonthlyIssues(db, until.AddDate(-2, 0, 0), until)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recs := []repository.Recommendation{}
+
+	for _, mi := range issues {
+		min, max, rationale, ok := Recommend(p, mi.Months, until.AddDate(0, -1, 0), now)
+
+		if !ok || (min == mi.Min && max == mi.Max) {
+			continue
+		}
+
+		b, _ := json.Marshal(rationale)
+
+		recs = append(recs, repository.Recommendation{
+			ItemId:     mi.ItemId,
+			CurrentMin: mi.Min,
+			CurrentMax: mi.Max,
+			Min:        min,
+			Max:        max,
+			Rationale:  b,
+		})
+	}
+
+	if err = repository.ReplacePendingRecommendations(db, recs); err != nil {
+		return nil, err
+	}
+
+	return recs, nil
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xsadia/secred/repository"
+)
+
+func monthlyIssues(quantities ...int64) []repository.Decimal {
+	months := make([]repository.Decimal, len(quantities))
+
+	for i, q := range quantities {
+		months[i] = repository.NewDecimal(q)
+	}
+
+	return months
+}
+
+func TestRecommend(t *testing.T) {
+	june := time.Date(2022, time.June, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("should cover lead time and review period", func(t *testing.T) {
+		p := DefaultForecastParams()
+		now := time.Date(2022, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+		min, max, r, ok := Recommend(p, monthlyIssues(30, 30, 30, 30), june, now)
+
+		if !ok {
+			t.Fatal("Expected a recommendation")
+		}
+
+		if min != repository.NewDecimal(15) || max != repository.NewDecimal(45) {
+			t.Errorf("Expected min 15 and max 45, got %s and %s", min, max)
+		}
+
+		if r.Summary == "" || r.AverageMonthly != repository.NewDecimal(30) {
+			t.Errorf("Expected a rationale averaging 30 a month, got %+v", r)
+		}
+	})
+
+	t.Run("should expect nothing to be issued during school holidays", func(t *testing.T) {
+		p := DefaultForecastParams()
+		now := time.Date(2022, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+		// July 2021 to June 2022, with nothing issued in July and January.
+		history := monthlyIssues(0, 30, 30, 30, 30, 30, 0, 30, 30, 30, 30, 30)
+
+		min, max, r, _ := Recommend(p, history, june, now)
+
+		if r.SessionIndex != 1.2 || r.VacationIndex != 0 {
+			t.Errorf("Expected indices 1.2 and 0, got %v and %v", r.SessionIndex, r.VacationIndex)
+		}
+
+		if min != 0 || max != repository.NewDecimal(14) {
+			t.Errorf("Expected min 0 and max 14, got %s and %s", min, max)
+		}
+	})
+
+	t.Run("should only average the last months on a moving average", func(t *testing.T) {
+		p := DefaultForecastParams()
+		p.Method = ForecastMovingAverage
+		p.Calendar = SchoolCalendar{}
+		now := time.Date(2022, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+		_, _, r, _ := Recommend(p, monthlyIssues(300, 60, 60, 60), june, now)
+
+		if r.ReviewDemand != repository.NewDecimal(60) {
+			t.Errorf("Expected review demand of 60, got %s", r.ReviewDemand)
+		}
+	})
+
+	t.Run("should not recommend without issues", func(t *testing.T) {
+		if _, _, _, ok := Recommend(DefaultForecastParams(), monthlyIssues(0, 0), june, june); ok {
+			t.Error("Expected no recommendation")
+		}
+	})
+}
+
+func TestForecastParams(t *testing.T) {
+	p := DefaultForecastParams()
+
+	if errs := p.Validate(); len(errs) > 0 {
+		t.Errorf("Expected defaults to be valid, got %v", errs)
+	}
+
+	p.Method = "guess"
+	p.Alpha = 2
+
+	errs := p.Validate()
+
+	if !hasFieldError(errs, "method", "invalid") || !hasFieldError(errs, "alpha", "out_of_range") {
+		t.Errorf("Expected method and alpha errors, got %v", errs)
+	}
+}
+
+func TestParseSchoolCalendar(t *testing.T) {
+	c, err := ParseSchoolCalendar("1, 7,12")
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %q", err.Error())
+	}
+
+	if !c.isVacation(time.December) || c.isVacation(time.March) {
+		t.Errorf("Unexpected calendar %v", c)
+	}
+
+	if _, err := ParseSchoolCalendar("13"); err == nil {
+		t.Error("Expected an error for month 13, got none")
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+
+	"github.com/xsadia/secred/repository"
 )
 
 func ValidateAuthHeader(header string) (string, error) {
@@ -34,3 +37,37 @@ func RespondWithJSON(w http.ResponseWriter, code int, payload any) {
 	w.WriteHeader(code)
 	w.Write(response)
 }
+
+// ETag returns the entity tag for a warehouse item at the given version.
+// Reservations expire without writing to the item, so what is reserved is
+// part of the tag too.
+func ETag(version int32, reserved repository.Decimal) string {
+	return `"` + strconv.Itoa(int(version)) + "-" + reserved.String() + `"`
+}
+
+// MatchETag reports whether etag is listed in an If-Match or If-None-Match
+// header. If-Match requires the strong comparison, so weak tags in the header
+// only match when weak is true.
+func MatchETag(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(candidate, "W/") {
+			if !weak {
+				continue
+			}
+
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,10 @@
 package internal
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
 
 func TestValidateAuthHeader(t *testing.T) {
 	t.Run("Should return the token if header is set correctly", func(t *testing.T) {
@@ -37,3 +41,35 @@ func TestValidateAuthHeader(t *testing.T) {
 		}
 	})
 }
+
+func TestETag(t *testing.T) {
+	if ETag(2, repository.NewDecimal(5)) == ETag(2, 0) {
+		t.Error("Expected the reserved quantity to change the tag")
+	}
+}
+
+func TestMatchETag(t *testing.T) {
+	t.Run("Should match tags in a list", func(t *testing.T) {
+		if !MatchETag(`"1-0", "2-0"`, ETag(2, 0), false) {
+			t.Error("Expected \"2\" to match")
+		}
+
+		if MatchETag(`"1-0", "3-0"`, ETag(2, 0), false) {
+			t.Error("Expected \"2\" not to match")
+		}
+
+		if !MatchETag("*", ETag(7, 0), false) {
+			t.Error("Expected * to match anything")
+		}
+	})
+
+	t.Run("Should only match weak tags on weak comparison", func(t *testing.T) {
+		if MatchETag(`W/"2-0"`, ETag(2, 0), false) {
+			t.Error("Expected weak tag not to match on strong comparison")
+		}
+
+		if !MatchETag(`W/"2-0"`, ETag(2, 0), true) {
+			t.Error("Expected weak tag to match on weak comparison")
+		}
+	})
+}
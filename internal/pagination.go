@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/xsadia/secred/repository"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// ParsePageRequest reads the count, cursor and total parameters shared by
+// every list endpoint. Offsets are not supported since they skip or repeat
+// rows when the list changes between requests: the deprecated start
+// parameter is still accepted for older clients but ignored, so they get the
+// first page.
+func ParsePageRequest(q url.Values) (repository.PageRequest, error) {
+	p := repository.PageRequest{Count: repository.DefaultPageSize}
+
+	if startQuery := q.Get("start"); startQuery != "" {
+		if start, err := strconv.Atoi(startQuery); err != nil || start < 0 {
+			return p, errors.New("start must be a non-negative number")
+		}
+	}
+
+	if countQuery := q.Get("count"); countQuery != "" {
+		count, err := strconv.Atoi(countQuery)
+
+		if err != nil || count < 1 || count > repository.MaxPageSize {
+			return p, fmt.Errorf("count must be a number between 1 and %d", repository.MaxPageSize)
+		}
+
+		p.Count = count
+	}
+
+	if cursorQuery := q.Get("cursor"); cursorQuery != "" {
+		c, err := DecodeCursor(cursorQuery)
+
+		if err != nil {
+			return p, err
+		}
+
+		p.Cursor = c
+	}
+
+	withTotal, err := parseBoolParam(q, "total")
+
+	if err != nil {
+		return p, err
+	}
+
+	p.WithTotal = withTotal
+
+	return p, nil
+}
+
+func EncodeCursor(c *repository.Cursor) string {
+	b, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*repository.Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var c repository.Cursor
+
+	if err = json.Unmarshal(b, &c); err != nil || c.Id == "" {
+		return nil, errInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// PageLinks builds an RFC 8288 Link header value pointing at the first, next
+// and previous pages of the list at u, keeping every other query parameter.
+func PageLinks(u *url.URL, next, prev *repository.Cursor) string {
+	link := func(c *repository.Cursor, rel string) string {
+		q := u.Query()
+		q.Del("cursor")
+		q.Del("start")
+
+		if c != nil {
+			q.Set("cursor", EncodeCursor(c))
+		}
+
+		target := url.URL{Path: u.Path, RawQuery: q.Encode()}
+
+		return fmt.Sprintf("<%s>; rel=\"%s\"", target.String(), rel)
+	}
+
+	links := []string{link(nil, "first")}
+
+	if next != nil {
+		links = append(links, link(next, "next"))
+	}
+
+	if prev != nil {
+		links = append(links, link(prev, "prev"))
+	}
+
+	return strings.Join(links, ", ")
+}
+
+// SetPageHeaders adds the Link header and, when it was requested, the
+// X-Total-Count header for page. Requests still sending start are told it is
+// deprecated.
+func SetPageHeaders[T any](w http.ResponseWriter, u *url.URL, page repository.Page[T]) {
+	w.Header().Set("Link", PageLinks(u, page.Next, page.Prev))
+
+	if u.Query().Get("start") != "" {
+		w.Header().Set("Deprecation", "true")
+	}
+
+	if page.Total >= 0 {
+		w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	}
+}
@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
+
+func TestParsePageRequest(t *testing.T) {
+	t.Run("should default count and decode the cursor", func(t *testing.T) {
+		c := &repository.Cursor{Sort: "name:asc", Key: "rice", Id: "1"}
+		q := url.Values{"cursor": {EncodeCursor(c)}, "total": {"true"}}
+
+		p, err := ParsePageRequest(q)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		if p.Count != repository.DefaultPageSize {
+			t.Errorf("Expected count to be %d, got %d", repository.DefaultPageSize, p.Count)
+		}
+
+		if p.Cursor == nil || *p.Cursor != *c {
+			t.Errorf("Expected cursor %v, got %v", c, p.Cursor)
+		}
+
+		if !p.WithTotal {
+			t.Error("Expected total to be requested")
+		}
+	})
+
+	t.Run("should ignore the deprecated start parameter", func(t *testing.T) {
+		p, err := ParsePageRequest(url.Values{"start": {"10"}})
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		if p.Cursor != nil {
+			t.Errorf("Expected the first page, got cursor %v", p.Cursor)
+		}
+	})
+
+	t.Run("should return error for invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"count=abc", "count=0", "count=101", "start=abc", "start=-1", "cursor=!!", "cursor=e30"} {
+			q, _ := url.ParseQuery(query)
+
+			if _, err := ParsePageRequest(q); err == nil {
+				t.Errorf("Expected an error for %q, got none", query)
+			}
+		}
+	})
+}
+
+func TestPageLinks(t *testing.T) {
+	u, _ := url.Parse("/warehouse?name=arroz&count=5&cursor=old")
+	next := &repository.Cursor{Sort: "name:asc", Key: "b", Id: "2"}
+
+	links := PageLinks(u, next, nil)
+
+	if !strings.Contains(links, `</warehouse?count=5&name=arroz>; rel="first"`) {
+		t.Errorf("Expected first link without cursor, got %q", links)
+	}
+
+	if !strings.Contains(links, "cursor="+EncodeCursor(next)+`&name=arroz>; rel="next"`) {
+		t.Errorf("Expected next link with the next cursor, got %q", links)
+	}
+
+	if strings.Contains(links, `rel="prev"`) {
+		t.Errorf("Expected no prev link, got %q", links)
+	}
+}
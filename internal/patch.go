@@ -0,0 +1,440 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/xsadia/secred/repository"
+)
+
+var (
+	errInvalidPatch     = errors.New("invalid patch document")
+	ErrPatchTestFailed  = errors.New("patch test operation failed")
+	ErrUnsupportedPatch = errors.New("unsupported patch media type")
+)
+
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// DecodeJSON decodes b keeping numbers as json.Number so decimals survive a
+// round trip untouched.
+func DecodeJSON(b []byte) (any, error) {
+	var v any
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to doc: members of patch
+// replace the ones in doc, objects are merged recursively and null removes a
+// member.
+func MergePatch(doc, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+
+	if !ok {
+		return patch
+	}
+
+	docObject, ok := doc.(map[string]any)
+
+	if !ok {
+		docObject = map[string]any{}
+	}
+
+	for k, v := range patchObject {
+		if v == nil {
+			delete(docObject, k)
+			continue
+		}
+
+		docObject[k] = MergePatch(docObject[k], v)
+	}
+
+	return docObject
+}
+
+// ApplyJSONPatch applies the RFC 6902 JSON Patch operations in patch to doc.
+func ApplyJSONPatch(doc any, patch []byte) (any, error) {
+	var ops []patchOperation
+
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, errInvalidPatch
+	}
+
+	var err error
+
+	for _, op := range ops {
+		if doc, err = applyPatchOperation(doc, op); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+func applyPatchOperation(doc any, op patchOperation) (any, error) {
+	path, err := parsePointer(op.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+
+	if op.Value != nil {
+		if value, err = DecodeJSON(*op.Value); err != nil {
+			return nil, errInvalidPatch
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		if op.Value == nil {
+			return nil, errInvalidPatch
+		}
+
+		return addValue(doc, path, value)
+	case "remove":
+		doc, _, err = removeValue(doc, path)
+		return doc, err
+	case "replace":
+		if op.Value == nil {
+			return nil, errInvalidPatch
+		}
+
+		if doc, _, err = removeValue(doc, path); err != nil {
+			return nil, err
+		}
+
+		return addValue(doc, path, value)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, fmt.Errorf("%w: cannot move a value into itself", errInvalidPatch)
+			}
+
+			doc, value, err = removeValue(doc, from)
+		} else {
+			value, err = getValue(doc, from)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return addValue(doc, path, value)
+	case "test":
+		if op.Value == nil {
+			return nil, errInvalidPatch
+		}
+
+		current, err := getValue(doc, path)
+
+		if err != nil || !jsonEqual(current, value) {
+			return nil, ErrPatchTestFailed
+		}
+
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown operation %q", errInvalidPatch, op.Op)
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%w: invalid path %q", errInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, appending bool) (int, error) {
+	if appending && token == "-" {
+		return length, nil
+	}
+
+	i, err := strconv.Atoi(token)
+
+	limit := length
+
+	if appending {
+		limit++
+	}
+
+	if err != nil || i < 0 || i >= limit || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", errInvalidPatch, token)
+	}
+
+	return i, nil
+}
+
+func getValue(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			v, ok := node[token]
+
+			if !ok {
+				return nil, fmt.Errorf("%w: path not found", errInvalidPatch)
+			}
+
+			doc = v
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+
+			if err != nil {
+				return nil, err
+			}
+
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("%w: path not found", errInvalidPatch)
+		}
+	}
+
+	return doc, nil
+}
+
+func addValue(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := getValue(doc, path[:len(path)-1])
+
+	if err != nil {
+		return nil, err
+	}
+
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+		return doc, nil
+	case []any:
+		i, err := arrayIndex(last, len(node), true)
+
+		if err != nil {
+			return nil, err
+		}
+
+		node = append(node, nil)
+		copy(node[i+1:], node[i:])
+		node[i] = value
+
+		return replaceContainer(doc, path[:len(path)-1], node)
+	}
+
+	return nil, fmt.Errorf("%w: path not found", errInvalidPatch)
+}
+
+func removeValue(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("%w: cannot remove the whole document", errInvalidPatch)
+	}
+
+	parent, err := getValue(doc, path[:len(path)-1])
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		v, ok := node[last]
+
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: path not found", errInvalidPatch)
+		}
+
+		delete(node, last)
+
+		return doc, v, nil
+	case []any:
+		i, err := arrayIndex(last, len(node), false)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		v := node[i]
+		node = append(node[:i:i], node[i+1:]...)
+		doc, err = replaceContainer(doc, path[:len(path)-1], node)
+
+		return doc, v, err
+	}
+
+	return nil, nil, fmt.Errorf("%w: path not found", errInvalidPatch)
+}
+
+// replaceContainer stores a resized array back at path, since slices cannot
+// grow or shrink in place.
+func replaceContainer(doc any, path []string, container []any) (any, error) {
+	if len(path) == 0 {
+		return container, nil
+	}
+
+	parent, err := getValue(doc, path[:len(path)-1])
+
+	if err != nil {
+		return nil, err
+	}
+
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = container
+	case []any:
+		i, _ := strconv.Atoi(last)
+		node[i] = container
+	}
+
+	return doc, nil
+}
+
+// jsonEqual compares decoded JSON values, treating numbers numerically so
+// that 2 and 2.0 are equal.
+func jsonEqual(a, b any) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+
+	if aok && bok {
+		ar, aok := new(big.Rat).SetString(an.String())
+		br, bok := new(big.Rat).SetString(bn.String())
+
+		return aok && bok && ar.Cmp(br) == 0
+	}
+
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+
+		for k, v := range av {
+			if w, ok := bv[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+
+		return true
+	case []any:
+		bv, ok := b.([]any)
+
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// readOnlyItemFields change through their own endpoints, if at all. Product
+// and variant are set when an item is attached to a product.
+var readOnlyItemFields = []string{
+	"id", "version", "category", "barcodes", "archived_at", "on_hand", "reserved", "available", "product_id", "variant",
+}
+
+// PatchWarehouseItem applies a JSON Merge Patch (the default, also used for
+// plain application/json) or a JSON Patch to wi, returning the updated item.
+// Only the fields present in the patch change. Changing a read-only field
+// fails with ValidationErrors.
+func PatchWarehouseItem(wi repository.WarehouseItem, mediaType string, patch []byte) (repository.WarehouseItem, error) {
+	b, _ := json.Marshal(wi)
+	original, _ := DecodeJSON(b)
+	doc, _ := DecodeJSON(b)
+
+	var err error
+
+	switch mediaType {
+	case "application/json-patch+json":
+		if doc, err = ApplyJSONPatch(doc, patch); err != nil {
+			return wi, err
+		}
+	case "", "application/json", "application/merge-patch+json":
+		p, err := DecodeJSON(patch)
+
+		if err != nil {
+			return wi, errInvalidPatch
+		}
+
+		if _, ok := p.(map[string]any); !ok {
+			return wi, errInvalidPatch
+		}
+
+		doc = MergePatch(doc, p)
+	default:
+		return wi, ErrUnsupportedPatch
+	}
+
+	patched, ok := doc.(map[string]any)
+
+	if !ok {
+		return wi, errInvalidPatch
+	}
+
+	for _, field := range readOnlyItemFields {
+		if !jsonEqual(original.(map[string]any)[field], patched[field]) {
+			return wi, ValidationErrors{{Field: field, Code: "read_only", Message: field + " is read-only"}}
+		}
+	}
+
+	b, _ = json.Marshal(patched)
+
+	var updated repository.WarehouseItem
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(&updated); err != nil {
+		return wi, fmt.Errorf("%w: %s", errInvalidPatch, err.Error())
+	}
+
+	return updated, nil
+}
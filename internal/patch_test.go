@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
+
+func TestMergePatch(t *testing.T) {
+	doc, _ := DecodeJSON([]byte(`{"name": "rice", "min": 1.5, "max": 10, "pack_unit": "bag", "tags": {"a": 1, "b": 2}}`))
+	patch, _ := DecodeJSON([]byte(`{"name": "arroz", "pack_unit": null, "tags": {"a": null, "c": 3}}`))
+
+	got, _ := json.Marshal(MergePatch(doc, patch))
+	expected := `{"max":10,"min":1.5,"name":"arroz","tags":{"b":2,"c":3}}`
+
+	if string(got) != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	t.Run("should apply operations in order", func(t *testing.T) {
+		doc, _ := DecodeJSON([]byte(`{"name": "rice", "min": 2, "list": [1, 2]}`))
+
+		got, err := ApplyJSONPatch(doc, []byte(`[
+			{"op": "test", "path": "/min", "value": 2.0},
+			{"op": "replace", "path": "/name", "value": "arroz"},
+			{"op": "add", "path": "/list/1", "value": 5},
+			{"op": "add", "path": "/list/-", "value": 9},
+			{"op": "remove", "path": "/list/0"},
+			{"op": "copy", "from": "/min", "path": "/max"},
+			{"op": "move", "from": "/name", "path": "/a~1b"}
+		]`))
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		b, _ := json.Marshal(got)
+		expected := `{"a/b":"arroz","list":[5,2,9],"max":2,"min":2}`
+
+		if string(b) != expected {
+			t.Errorf("Expected %s, got %s", expected, b)
+		}
+	})
+
+	t.Run("should fail when a test operation does not match", func(t *testing.T) {
+		doc, _ := DecodeJSON([]byte(`{"min": 2}`))
+
+		if _, err := ApplyJSONPatch(doc, []byte(`[{"op": "test", "path": "/min", "value": 3}]`)); err != ErrPatchTestFailed {
+			t.Errorf("Expected error %q, got %v", ErrPatchTestFailed, err)
+		}
+	})
+
+	t.Run("should return error for invalid operations", func(t *testing.T) {
+		doc, _ := DecodeJSON([]byte(`{"min": 2}`))
+
+		for _, patch := range []string{
+			`{"op": "add"}`,
+			`[{"op": "jump", "path": "/min"}]`,
+			`[{"op": "remove", "path": "/max"}]`,
+			`[{"op": "replace", "path": "/max", "value": 1}]`,
+			`[{"op": "add", "path": "min", "value": 1}]`,
+		} {
+			if _, err := ApplyJSONPatch(doc, []byte(patch)); err == nil {
+				t.Errorf("Expected an error for %s, got none", patch)
+			}
+		}
+	})
+}
+
+func TestPatchWarehouseItem(t *testing.T) {
+	wi := repository.WarehouseItem{
+		Id:       "1",
+		Name:     "rice",
+		Min:      repository.NewDecimal(5),
+		Max:      repository.NewDecimal(50),
+		Quantity: repository.NewDecimal(20),
+		Unit:     "kg",
+		PackSize: repository.NewDecimal(1),
+		Version:  3,
+	}
+
+	t.Run("should only change fields present in a merge patch", func(t *testing.T) {
+		got, err := PatchWarehouseItem(wi, "application/merge-patch+json", []byte(`{"name": "arroz", "max": 60.5}`))
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		if got.Name != "arroz" || got.Max.String() != "60.5" {
+			t.Errorf("Expected name and max to change, got %q and %s", got.Name, got.Max)
+		}
+
+		if got.Min != wi.Min || got.Quantity != wi.Quantity || got.Unit != wi.Unit {
+			t.Errorf("Expected other fields to be kept, got %+v", got)
+		}
+	})
+
+	t.Run("should apply a JSON Patch", func(t *testing.T) {
+		got, err := PatchWarehouseItem(wi, "application/json-patch+json", []byte(`[{"op": "replace", "path": "/min", "value": 7}]`))
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		if got.Min != repository.NewDecimal(7) || got.Max != wi.Max {
+			t.Errorf("Expected only min to change, got %+v", got)
+		}
+	})
+
+	t.Run("should refuse to change read-only and unknown fields", func(t *testing.T) {
+		for _, patch := range []string{`{"id": "2"}`, `{"version": 9}`, `{"colour": "red"}`, `{"name": 12}`} {
+			if _, err := PatchWarehouseItem(wi, "application/json", []byte(patch)); err == nil {
+				t.Errorf("Expected an error for %s, got none", patch)
+			}
+		}
+	})
+
+	t.Run("should report read-only fields as validation errors", func(t *testing.T) {
+		for _, patch := range []string{`{"product_id": "1"}`, `{"variant": {"size": "g"}}`} {
+			_, err := PatchWarehouseItem(wi, "application/json", []byte(patch))
+
+			var errs ValidationErrors
+
+			if !errors.As(err, &errs) || errs[0].Code != "read_only" {
+				t.Errorf("Expected a read_only error for %s, got %v", patch, err)
+			}
+		}
+	})
+
+	t.Run("should refuse unsupported media types", func(t *testing.T) {
+		if _, err := PatchWarehouseItem(wi, "text/plain", []byte(`{}`)); err != ErrUnsupportedPatch {
+			t.Errorf("Expected error %q, got %v", ErrUnsupportedPatch, err)
+		}
+	})
+}
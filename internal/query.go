@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xsadia/secred/repository"
+)
+
+// ParseWarehouseItemFilter reads the search, filter and sort parameters of
+// GET /warehouse. Unknown sort fields and malformed values are errors instead
+// of being silently ignored.
+func ParseWarehouseItemFilter(q url.Values) (repository.WarehouseItemFilter, error) {
+	f := repository.WarehouseItemFilter{
+		Name:      strings.TrimSpace(q.Get("name")),
+		ProductId: q.Get("product_id"),
+		Sort:      "name",
+	}
+	var err error
+
+	if f.BelowMin, err = parseBoolParam(q, "below_min"); err != nil {
+		return f, err
+	}
+
+	if f.AboveMax, err = parseBoolParam(q, "above_max"); err != nil {
+		return f, err
+	}
+
+	if f.MinQuantity, err = parseDecimalParam(q, "min_quantity"); err != nil {
+		return f, err
+	}
+
+	if f.MaxQuantity, err = parseDecimalParam(q, "max_quantity"); err != nil {
+		return f, err
+	}
+
+	if f.IncludeArchived, err = parseBoolParam(q, "include_archived"); err != nil {
+		return f, err
+	}
+
+	if f.AsOf, err = ParseAsOf(q); err != nil {
+		return f, err
+	}
+
+	if sort := q.Get("sort"); sort != "" {
+		if _, ok := repository.WarehouseItemSortFields[sort]; !ok {
+			return f, fmt.Errorf("invalid sort field %q", sort)
+		}
+
+		f.Sort = sort
+	}
+
+	switch strings.ToLower(q.Get("order")) {
+	case "", "asc":
+	case "desc":
+		f.Desc = true
+	default:
+		return f, fmt.Errorf("invalid order %q", q.Get("order"))
+	}
+
+	return f, nil
+}
+
+// ParseAsOf reads the as_of parameter of point in time queries: either an
+// RFC 3339 timestamp or a date, which means the end of that day in UTC like
+// the valuation report's.
+func ParseAsOf(q url.Values) (*time.Time, error) {
+	v := q.Get("as_of")
+
+	if v == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return &t, nil
+	}
+
+	d, err := repository.ParseDate(v)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid as_of parameter")
+	}
+
+	t := d.AddDate(0, 0, 1)
+
+	return &t, nil
+}
+
+func parseBoolParam(q url.Values, key string) (bool, error) {
+	v := q.Get(key)
+
+	if v == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+
+	if err != nil {
+		return false, fmt.Errorf("invalid %s parameter", key)
+	}
+
+	return b, nil
+}
+
+func parseDecimalParam(q url.Values, key string) (*repository.Decimal, error) {
+	v := q.Get(key)
+
+	if v == "" {
+		return nil, nil
+	}
+
+	d, err := repository.ParseDecimal(v)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter", key)
+	}
+
+	return &d, nil
+}
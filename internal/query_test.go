@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseWarehouseItemFilter(t *testing.T) {
+	t.Run("should parse filters and sort", func(t *testing.T) {
+		q, _ := url.ParseQuery("name=L%C3%A1pis&below_min=true&min_quantity=1.5&sort=quantity&order=desc")
+
+		f, err := ParseWarehouseItemFilter(q)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		if f.Name != "Lápis" {
+			t.Errorf("Expected name to be %q, got %q", "Lápis", f.Name)
+		}
+
+		if !f.BelowMin || f.AboveMax {
+			t.Errorf("Expected only below_min to be set, got below_min=%v above_max=%v", f.BelowMin, f.AboveMax)
+		}
+
+		if f.MinQuantity == nil || f.MinQuantity.String() != "1.5" {
+			t.Errorf("Expected min_quantity to be 1.5, got %v", f.MinQuantity)
+		}
+
+		if f.MaxQuantity != nil {
+			t.Errorf("Expected max_quantity to be unset, got %v", f.MaxQuantity)
+		}
+
+		if f.Sort != "quantity" || !f.Desc {
+			t.Errorf("Expected to sort by quantity descending, got %q desc=%v", f.Sort, f.Desc)
+		}
+	})
+
+	t.Run("should default to sorting by name", func(t *testing.T) {
+		f, _ := ParseWarehouseItemFilter(url.Values{})
+
+		if f.Sort != "name" || f.Desc {
+			t.Errorf("Expected to sort by name ascending, got %q desc=%v", f.Sort, f.Desc)
+		}
+	})
+
+	t.Run("should parse include_archived", func(t *testing.T) {
+		q, _ := url.ParseQuery("include_archived=true")
+
+		f, err := ParseWarehouseItemFilter(q)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		if !f.IncludeArchived {
+			t.Error("Expected include_archived to be set")
+		}
+	})
+
+	t.Run("should parse as_of", func(t *testing.T) {
+		q, _ := url.ParseQuery("as_of=2022-03-01T10:00:00Z")
+
+		f, err := ParseWarehouseItemFilter(q)
+
+		if err != nil {
+			t.Fatalf("Expected no error, got %q", err.Error())
+		}
+
+		if f.AsOf == nil || !f.AsOf.Equal(time.Date(2022, time.March, 1, 10, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected as_of to be 2022-03-01 10:00, got %v", f.AsOf)
+		}
+	})
+
+	t.Run("should return error for fields not in the whitelist", func(t *testing.T) {
+		for _, query := range []string{"sort=password", "order=sideways", "below_min=maybe", "max_quantity=lots", "include_archived=sometimes", "as_of=yesterday"} {
+			q, _ := url.ParseQuery(query)
+
+			if _, err := ParseWarehouseItemFilter(q); err == nil {
+				t.Errorf("Expected an error for %q, got none", query)
+			}
+		}
+	})
+}
+
+func TestParseAsOf(t *testing.T) {
+	q, _ := url.ParseQuery("as_of=2022-03-01")
+
+	asOf, err := ParseAsOf(q)
+
+	if err != nil {
+		t.Fatalf("Expected no error, got %q", err.Error())
+	}
+
+	if asOf == nil || !asOf.Equal(time.Date(2022, time.March, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected a date to mean the end of that day, got %v", asOf)
+	}
+
+	if asOf, _ := ParseAsOf(url.Values{}); asOf != nil {
+		t.Errorf("Expected no as_of, got %v", asOf)
+	}
+}
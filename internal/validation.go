@@ -0,0 +1,416 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xsadia/secred/repository"
+)
+
+const validationFailedError = "Validation failed"
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+
+	for i, e := range v {
+		messages[i] = e.Field + ": " + e.Message
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Validator collects field errors for a request body.
+type Validator struct {
+	Errors ValidationErrors
+}
+
+func (v *Validator) Add(field, code, message string) {
+	v.Errors = append(v.Errors, FieldError{Field: field, Code: code, Message: message})
+}
+
+func (v *Validator) Required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, "required", field+" is required")
+		return false
+	}
+
+	return true
+}
+
+// MaxLength counts characters rather than bytes, like Postgres VARCHAR(n).
+func (v *Validator) MaxLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.Add(field, "too_long", fmt.Sprintf("%s must have at most %d characters", field, max))
+	}
+}
+
+func (v *Validator) NonNegative(field string, d repository.Decimal) {
+	if d.IsNegative() {
+		v.Add(field, "negative", field+" must not be negative")
+	}
+}
+
+func (v *Validator) Positive(field string, d repository.Decimal) {
+	if !d.IsPositive() {
+		v.Add(field, "not_positive", field+" must be greater than zero")
+	}
+}
+
+func (v *Validator) OneOf(field, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+
+	v.Add(field, "invalid", fmt.Sprintf("%s must be one of %s", field, strings.Join(allowed, ", ")))
+}
+
+func ValidateWarehouseItem(wi repository.WarehouseItem) ValidationErrors {
+	var v Validator
+
+	if name := repository.NormalizeName(wi.Name); v.Required("name", name) {
+		v.MaxLength("name", name, 50)
+	}
+
+	v.NonNegative("quantity", wi.Quantity)
+	v.NonNegative("min", wi.Min)
+	v.NonNegative("max", wi.Max)
+
+	if wi.Min > wi.Max {
+		v.Add("min", "greater_than_max", "min must not be greater than max")
+	}
+
+	v.MaxLength("unit", wi.Unit, 10)
+
+	if wi.PackUnit != nil {
+		v.MaxLength("pack_unit", *wi.PackUnit, 10)
+	}
+
+	v.NonNegative("pack_size", wi.PackSize)
+
+	return v.Errors
+}
+
+func ValidateUser(u repository.User) ValidationErrors {
+	var v Validator
+
+	if v.Required("email", u.Email) {
+		v.MaxLength("email", u.Email, 255)
+
+		if _, err := mail.ParseAddress(u.Email); err != nil {
+			v.Add("email", "invalid", "email must be a valid e-mail address")
+		}
+	}
+
+	if v.Required("username", u.Username) {
+		v.MaxLength("username", u.Username, 80)
+	}
+
+	if v.Required("password", u.Password) && utf8.RuneCountInString(u.Password) < 6 {
+		v.Add("password", "too_short", "password must have at least 6 characters")
+	}
+
+	return v.Errors
+}
+
+func ValidateSchool(sc repository.School) ValidationErrors {
+	var v Validator
+
+	if v.Required("name", sc.Name) {
+		v.MaxLength("name", sc.Name, 255)
+	}
+
+	if sc.Enrollment < 0 {
+		v.Add("enrollment", "negative", "enrollment must not be negative")
+	}
+
+	return v.Errors
+}
+
+func ValidateCategory(c repository.Category) ValidationErrors {
+	var v Validator
+
+	if v.Required("name", c.Name) {
+		v.MaxLength("name", c.Name, 50)
+	}
+
+	return v.Errors
+}
+
+func ValidateSupplier(sp repository.Supplier) ValidationErrors {
+	var v Validator
+
+	if v.Required("name", sp.Name) {
+		v.MaxLength("name", sp.Name, 100)
+	}
+
+	if sp.TaxId != nil {
+		v.MaxLength("tax_id", *sp.TaxId, 20)
+	}
+
+	if sp.Email != nil {
+		v.MaxLength("email", *sp.Email, 255)
+
+		if _, err := mail.ParseAddress(*sp.Email); err != nil {
+			v.Add("email", "invalid", "email must be a valid e-mail address")
+		}
+	}
+
+	if sp.Phone != nil {
+		v.MaxLength("phone", *sp.Phone, 30)
+	}
+
+	return v.Errors
+}
+
+func ValidatePurchaseOrder(po repository.PurchaseOrder) ValidationErrors {
+	var v Validator
+
+	v.Required("supplier_id", po.SupplierId)
+	v.MaxLength("notes", po.Notes, 255)
+
+	if len(po.Lines) == 0 {
+		v.Add("lines", "required", "lines must have at least one line")
+	}
+
+	ordered := map[string]bool{}
+
+	for i, l := range po.Lines {
+		field := fmt.Sprintf("lines[%d]", i)
+
+		if v.Required(field+".item_id", l.ItemId) && ordered[l.ItemId] {
+			v.Add(field+".item_id", "duplicate", "item is already ordered in another line")
+		}
+
+		ordered[l.ItemId] = true
+
+		v.Positive(field+".quantity", l.Quantity)
+	}
+
+	return v.Errors
+}
+
+// ValidateKit checks the kit's components. An empty list is allowed and turns
+// the kit back into a plain item.
+func ValidateKit(k repository.Kit) ValidationErrors {
+	var v Validator
+
+	listed := map[string]bool{}
+
+	for i, c := range k.Components {
+		field := fmt.Sprintf("components[%d]", i)
+
+		if v.Required(field+".item_id", c.ItemId) {
+			switch {
+			case c.ItemId == k.ItemId:
+				v.Add(field+".item_id", "invalid", "a kit cannot be its own component")
+			case listed[c.ItemId]:
+				v.Add(field+".item_id", "duplicate", "item is already listed as a component")
+			}
+		}
+
+		listed[c.ItemId] = true
+
+		v.Positive(field+".quantity", c.Quantity)
+	}
+
+	return v.Errors
+}
+
+func ValidateAsset(a repository.Asset) ValidationErrors {
+	var v Validator
+
+	if v.Required("serial_number", a.SerialNumber) {
+		v.MaxLength("serial_number", a.SerialNumber, 100)
+	}
+
+	if v.Required("kind", a.Kind) {
+		v.MaxLength("kind", a.Kind, 30)
+	}
+
+	v.MaxLength("model", a.Model, 100)
+
+	if a.Condition != "" {
+		v.OneOf("condition", a.Condition, repository.AssetConditions...)
+	}
+
+	v.MaxLength("notes", a.Notes, 255)
+
+	return v.Errors
+}
+
+func ValidateMaintenanceTicket(t repository.MaintenanceTicket) ValidationErrors {
+	var v Validator
+
+	if v.Required("description", t.Description) {
+		v.MaxLength("description", t.Description, 255)
+	}
+
+	return v.Errors
+}
+
+func ValidateProduct(p repository.Product) ValidationErrors {
+	var v Validator
+
+	if name := repository.NormalizeName(p.Name); v.Required("name", name) {
+		v.MaxLength("name", name, 50)
+	}
+
+	if len(p.Attributes) == 0 {
+		v.Add("attributes", "required", "attributes must have at least one attribute")
+	}
+
+	listed := map[string]bool{}
+
+	for i, a := range p.Attributes {
+		field := fmt.Sprintf("attributes[%d]", i)
+		a = repository.NormalizeName(a)
+
+		if v.Required(field, a) {
+			v.MaxLength(field, a, 30)
+
+			if listed[a] {
+				v.Add(field, "duplicate", "attribute is already listed")
+			}
+		}
+
+		listed[a] = true
+	}
+
+	return v.Errors
+}
+
+// ValidateVariant checks that a variant gives a value to each of the
+// product's attributes and to nothing else.
+func ValidateVariant(p repository.Product, variant repository.Variant) ValidationErrors {
+	var v Validator
+
+	values := map[string]string{}
+
+	for a, value := range variant {
+		values[repository.NormalizeName(a)] = repository.NormalizeName(value)
+	}
+
+	for _, a := range p.Attributes {
+		field := "variant." + a
+
+		if v.Required(field, values[a]) {
+			v.MaxLength(field, values[a], 30)
+		}
+
+		delete(values, a)
+	}
+
+	for a := range values {
+		v.Add("variant."+a, "unknown", "product has no attribute "+a)
+	}
+
+	return v.Errors
+}
+
+func ValidateStudent(st repository.Student) ValidationErrors {
+	var v Validator
+
+	if v.Required("enrollment_id", st.EnrollmentId) {
+		v.MaxLength("enrollment_id", st.EnrollmentId, 30)
+	}
+
+	if v.Required("name", st.Name) {
+		v.MaxLength("name", st.Name, 100)
+	}
+
+	v.MaxLength("grade", st.Grade, 20)
+
+	return v.Errors
+}
+
+func ValidateEntitlement(e repository.Entitlement) ValidationErrors {
+	var v Validator
+
+	if v.Required("name", e.Name) {
+		v.MaxLength("name", e.Name, 100)
+	}
+
+	if (e.ItemId == nil) == (e.ProductId == nil) {
+		v.Add("item_id", "invalid", "exactly one of item_id and product_id must be set")
+	}
+
+	v.Positive("quantity", e.Quantity)
+
+	if v.Required("period", e.Period) {
+		v.OneOf("period", e.Period, repository.EntitlementPeriods...)
+	}
+
+	v.MaxLength("grade", repository.NormalizeName(e.Grade), 20)
+
+	return v.Errors
+}
+
+func ValidateHandout(h repository.Handout) ValidationErrors {
+	var v Validator
+
+	v.Required("item_id", h.ItemId)
+	v.Positive("quantity", h.Quantity)
+	v.MaxLength("notes", h.Notes, 255)
+
+	return v.Errors
+}
+
+func ValidateAllotment(a repository.Allotment) ValidationErrors {
+	var v Validator
+
+	if v.Required("name", a.Name) {
+		v.MaxLength("name", a.Name, 100)
+	}
+
+	v.Required("item_id", a.ItemId)
+	v.Positive("quantity", a.Quantity)
+
+	if v.Required("period", a.Period) {
+		v.OneOf("period", a.Period, repository.AllotmentPeriods...)
+	}
+
+	return v.Errors
+}
+
+// PrefixFields nests errors under a parent field, e.g. "rows[2].name".
+func (v ValidationErrors) PrefixFields(prefix string) ValidationErrors {
+	prefixed := make(ValidationErrors, len(v))
+
+	for i, e := range v {
+		e.Field = prefix + "." + e.Field
+		prefixed[i] = e
+	}
+
+	return prefixed
+}
+
+func RespondWithValidationErrors(w http.ResponseWriter, errs ValidationErrors) {
+	RespondWithJSON(w, http.StatusUnprocessableEntity, map[string]any{"error": validationFailedError, "errors": errs})
+}
+
+// RespondWithStorageError maps Postgres constraint errors to a response: 409
+// for unique violations, 422 for data the database refused and 500 otherwise.
+func RespondWithStorageError(w http.ResponseWriter, err error, conflictMessage string) {
+	switch {
+	case repository.IsUniqueViolation(err):
+		RespondWithError(w, http.StatusConflict, conflictMessage)
+	case repository.IsInvalidData(err):
+		field := repository.ConstraintField(err)
+		RespondWithValidationErrors(w, ValidationErrors{{Field: field, Code: "invalid", Message: field + " is invalid"}})
+	default:
+		RespondWithError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
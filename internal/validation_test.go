@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
+
+func hasFieldError(errs ValidationErrors, field, code string) bool {
+	for _, e := range errs {
+		if e.Field == field && e.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestValidateWarehouseItem(t *testing.T) {
+	t.Run("should accept a valid item", func(t *testing.T) {
+		wi := repository.WarehouseItem{Name: "lápis", Min: repository.NewDecimal(1), Max: repository.NewDecimal(5)}
+
+		if errs := ValidateWarehouseItem(wi); len(errs) > 0 {
+			t.Errorf("Expected no errors, got %v", errs)
+		}
+	})
+
+	t.Run("should report every invalid field", func(t *testing.T) {
+		wi := repository.WarehouseItem{
+			Name:     strings.Repeat("á", 51),
+			Quantity: repository.NewDecimal(-1),
+			Min:      repository.NewDecimal(10),
+			Max:      repository.NewDecimal(5),
+		}
+
+		errs := ValidateWarehouseItem(wi)
+
+		for _, expected := range []FieldError{
+			{Field: "name", Code: "too_long"},
+			{Field: "quantity", Code: "negative"},
+			{Field: "min", Code: "greater_than_max"},
+		} {
+			if !hasFieldError(errs, expected.Field, expected.Code) {
+				t.Errorf("Expected %s error on %s, got %v", expected.Code, expected.Field, errs)
+			}
+		}
+	})
+
+	t.Run("should count characters instead of bytes", func(t *testing.T) {
+		if errs := ValidateWarehouseItem(repository.WarehouseItem{Name: strings.Repeat("á", 50)}); len(errs) > 0 {
+			t.Errorf("Expected no errors, got %v", errs)
+		}
+	})
+}
+
+func TestValidateUser(t *testing.T) {
+	errs := ValidateUser(repository.User{Email: "not an email", Password: "123"})
+
+	for _, expected := range []FieldError{
+		{Field: "email", Code: "invalid"},
+		{Field: "username", Code: "required"},
+		{Field: "password", Code: "too_short"},
+	} {
+		if !hasFieldError(errs, expected.Field, expected.Code) {
+			t.Errorf("Expected %s error on %s, got %v", expected.Code, expected.Field, errs)
+		}
+	}
+}
+
+func TestValidatePurchaseOrder(t *testing.T) {
+	t.Run("should require lines", func(t *testing.T) {
+		errs := ValidatePurchaseOrder(repository.PurchaseOrder{SupplierId: "1"})
+
+		if !hasFieldError(errs, "lines", "required") {
+			t.Errorf("Expected required error on lines, got %v", errs)
+		}
+	})
+
+	t.Run("should validate each line", func(t *testing.T) {
+		po := repository.PurchaseOrder{
+			SupplierId: "1",
+			Lines: []repository.PurchaseOrderLine{
+				{ItemId: "a", Quantity: repository.NewDecimal(1)},
+				{ItemId: "a", Quantity: repository.NewDecimal(2)},
+				{ItemId: "b"},
+			},
+		}
+
+		errs := ValidatePurchaseOrder(po)
+
+		for _, expected := range []FieldError{
+			{Field: "lines[1].item_id", Code: "duplicate"},
+			{Field: "lines[2].quantity", Code: "not_positive"},
+		} {
+			if !hasFieldError(errs, expected.Field, expected.Code) {
+				t.Errorf("Expected %s error on %s, got %v", expected.Code, expected.Field, errs)
+			}
+		}
+
+		if len(errs) != 2 {
+			t.Errorf("Expected 2 errors, got %v", errs)
+		}
+	})
+}
+
+func TestValidateKit(t *testing.T) {
+	k := repository.Kit{
+		ItemId: "kit",
+		Components: []repository.KitComponent{
+			{ItemId: "a", Quantity: repository.NewDecimal(1)},
+			{ItemId: "a", Quantity: repository.NewDecimal(2)},
+			{ItemId: "kit", Quantity: repository.NewDecimal(1)},
+			{ItemId: "b"},
+		},
+	}
+
+	errs := ValidateKit(k)
+
+	for _, expected := range []FieldError{
+		{Field: "components[1].item_id", Code: "duplicate"},
+		{Field: "components[2].item_id", Code: "invalid"},
+		{Field: "components[3].quantity", Code: "not_positive"},
+	} {
+		if !hasFieldError(errs, expected.Field, expected.Code) {
+			t.Errorf("Expected %s error on %s, got %v", expected.Code, expected.Field, errs)
+		}
+	}
+
+	if len(errs) != 3 {
+		t.Errorf("Expected 3 errors, got %v", errs)
+	}
+
+	if errs := ValidateKit(repository.Kit{ItemId: "kit"}); len(errs) > 0 {
+		t.Errorf("Expected no components to be valid, got %v", errs)
+	}
+}
+
+func TestValidateAsset(t *testing.T) {
+	errs := ValidateAsset(repository.Asset{Kind: "laptop", Condition: "scratched"})
+
+	for _, expected := range []FieldError{
+		{Field: "serial_number", Code: "required"},
+		{Field: "condition", Code: "invalid"},
+	} {
+		if !hasFieldError(errs, expected.Field, expected.Code) {
+			t.Errorf("Expected %s error on %s, got %v", expected.Code, expected.Field, errs)
+		}
+	}
+
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+
+	if errs := ValidateAsset(repository.Asset{SerialNumber: "SN-1", Kind: "laptop"}); len(errs) > 0 {
+		t.Errorf("Expected an asset without condition to be valid, got %v", errs)
+	}
+}
+
+func TestValidateMaintenanceTicket(t *testing.T) {
+	if errs := ValidateMaintenanceTicket(repository.MaintenanceTicket{Description: " "}); !hasFieldError(errs, "description", "required") {
+		t.Errorf("Expected required error on description, got %v", errs)
+	}
+
+	if errs := ValidateMaintenanceTicket(repository.MaintenanceTicket{Description: "Lamp is out"}); len(errs) > 0 {
+		t.Errorf("Expected ticket to be valid, got %v", errs)
+	}
+}
+
+func TestValidateProduct(t *testing.T) {
+	errs := ValidateProduct(repository.Product{Name: "Camiseta", Attributes: []string{"Size", "size ", ""}})
+
+	for _, expected := range []FieldError{
+		{Field: "attributes[1]", Code: "duplicate"},
+		{Field: "attributes[2]", Code: "required"},
+	} {
+		if !hasFieldError(errs, expected.Field, expected.Code) {
+			t.Errorf("Expected %s error on %s, got %v", expected.Code, expected.Field, errs)
+		}
+	}
+
+	if errs := ValidateProduct(repository.Product{Name: "Camiseta"}); !hasFieldError(errs, "attributes", "required") {
+		t.Errorf("Expected required error on attributes, got %v", errs)
+	}
+}
+
+func TestValidateVariant(t *testing.T) {
+	p := repository.Product{Name: "camiseta", Attributes: []string{"size", "color"}}
+
+	errs := ValidateVariant(p, repository.Variant{"Size": "M", "sleeve": "long"})
+
+	for _, expected := range []FieldError{
+		{Field: "variant.color", Code: "required"},
+		{Field: "variant.sleeve", Code: "unknown"},
+	} {
+		if !hasFieldError(errs, expected.Field, expected.Code) {
+			t.Errorf("Expected %s error on %s, got %v", expected.Code, expected.Field, errs)
+		}
+	}
+
+	if len(errs) != 2 {
+		t.Errorf("Expected 2 errors, got %v", errs)
+	}
+
+	if errs := ValidateVariant(p, repository.Variant{"size": "m", "color": "azul"}); len(errs) > 0 {
+		t.Errorf("Expected variant to be valid, got %v", errs)
+	}
+}
+
+func TestPrefixFields(t *testing.T) {
+	errs := ValidateSchool(repository.School{}).PrefixFields("rows[0]")
+
+	if !hasFieldError(errs, "rows[0].name", "required") {
+		t.Errorf("Expected required error on rows[0].name, got %v", errs)
+	}
+}
@@ -0,0 +1,248 @@
+package internal
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/xsadia/secred/repository"
+)
+
+const (
+	CostingAverage = "average"
+	CostingFIFO    = "fifo"
+)
+
+var ErrUnknownCostingMethod = errors.New("unknown costing method")
+
+// CostingMethod returns the deployment's costing method, set through the
+// COSTING_METHOD environment variable. Weighted average is the default.
+func CostingMethod() (string, error) {
+	method := strings.ToLower(strings.TrimSpace(os.Getenv("COSTING_METHOD")))
+
+	switch method {
+	case "":
+		return CostingAverage, nil
+	case CostingAverage, CostingFIFO:
+		return method, nil
+	}
+
+	return "", ErrUnknownCostingMethod
+}
+
+type ItemValuation struct {
+	ItemId    string             `json:"item_id"`
+	Name      string             `json:"name"`
+	Category  string             `json:"category"`
+	Quantity  repository.Decimal `json:"quantity"`
+	Unit      string             `json:"unit"`
+	UnitCost  repository.Decimal `json:"unit_cost"`
+	Value     repository.Decimal `json:"value"`
+	ProductId *string            `json:"product_id,omitempty"`
+	Product   string             `json:"product,omitempty"`
+}
+
+type CategoryValuation struct {
+	Category string             `json:"category"`
+	Items    int                `json:"items"`
+	Value    repository.Decimal `json:"value"`
+}
+
+// ProductValuation rolls a product's variants up. Only items that are variants
+// of a product are counted.
+type ProductValuation struct {
+	ProductId string             `json:"product_id"`
+	Product   string             `json:"product"`
+	Items     int                `json:"items"`
+	Quantity  repository.Decimal `json:"quantity"`
+	Value     repository.Decimal `json:"value"`
+}
+
+type ValuationReport struct {
+	Date       repository.Date     `json:"date"`
+	Method     string              `json:"method"`
+	Total      repository.Decimal  `json:"total"`
+	Categories []CategoryValuation `json:"categories"`
+	Products   []ProductValuation  `json:"products"`
+	Items      []ItemValuation     `json:"items"`
+}
+
+type costLayer struct {
+	quantity repository.Decimal
+	cost     repository.Decimal
+}
+
+// ValueStock replays an item's movements and returns the quantity left and
+// what it is worth under method. Incoming stock without a unit cost, like a
+// positive adjustment, is valued at the item's current cost.
+func ValueStock(method string, movements []repository.StockMovement) (repository.Decimal, repository.Decimal) {
+	if method == CostingFIFO {
+		return valueFIFO(movements)
+	}
+
+	return valueAverage(movements)
+}
+
+func valueAverage(movements []repository.StockMovement) (repository.Decimal, repository.Decimal) {
+	var quantity, value, lastCost repository.Decimal
+
+	for _, m := range movements {
+		if m.Quantity.IsPositive() {
+			cost := lastCost
+
+			if m.UnitCost != nil {
+				cost = *m.UnitCost
+				lastCost = cost
+			} else if quantity.IsPositive() {
+				cost = value.Div(quantity)
+			}
+
+			quantity = quantity.Add(m.Quantity)
+			value = value.Add(m.Quantity.Mul(cost))
+
+			continue
+		}
+
+		out := m.Quantity.Neg()
+
+		if out >= quantity {
+			quantity, value = 0, 0
+			continue
+		}
+
+		value = value.Sub(value.Div(quantity).Mul(out))
+		quantity = quantity.Sub(out)
+	}
+
+	return quantity, value
+}
+
+func valueFIFO(movements []repository.StockMovement) (repository.Decimal, repository.Decimal) {
+	var layers []costLayer
+	var lastCost repository.Decimal
+
+	for _, m := range movements {
+		if m.Quantity.IsPositive() {
+			cost := lastCost
+
+			if m.UnitCost != nil {
+				cost = *m.UnitCost
+				lastCost = cost
+			}
+
+			layers = append(layers, costLayer{quantity: m.Quantity, cost: cost})
+
+			continue
+		}
+
+		out := m.Quantity.Neg()
+
+		for out.IsPositive() && len(layers) > 0 {
+			if layers[0].quantity > out {
+				layers[0].quantity = layers[0].quantity.Sub(out)
+				break
+			}
+
+			out = out.Sub(layers[0].quantity)
+			layers = layers[1:]
+		}
+	}
+
+	var quantity, value repository.Decimal
+
+	for _, l := range layers {
+		quantity = quantity.Add(l.quantity)
+		value = value.Add(l.quantity.Mul(l.cost))
+	}
+
+	return quantity, value
+}
+
+// NewValuationReport values every item with stock left and totals the values
+// by category. Histories are expected in item name order.
+func NewValuationReport(method string, date repository.Date, histories []repository.ItemHistory) ValuationReport {
+	report := ValuationReport{
+		Date:       date,
+		Method:     method,
+		Categories: []CategoryValuation{},
+		Products:   []ProductValuation{},
+		Items:      []ItemValuation{},
+	}
+
+	categories := map[string]int{}
+	products := map[string]int{}
+
+	for _, h := range histories {
+		quantity, value := ValueStock(method, h.Movements)
+
+		if quantity.IsZero() {
+			continue
+		}
+
+		report.Items = append(report.Items, ItemValuation{
+			ItemId:    h.ItemId,
+			Name:      h.Name,
+			Category:  h.Category,
+			Quantity:  quantity,
+			Unit:      h.Unit,
+			UnitCost:  value.Div(quantity),
+			Value:     value,
+			ProductId: h.ProductId,
+			Product:   h.Product,
+		})
+
+		i, ok := categories[h.Category]
+
+		if !ok {
+			i = len(report.Categories)
+			categories[h.Category] = i
+			report.Categories = append(report.Categories, CategoryValuation{Category: h.Category})
+		}
+
+		report.Categories[i].Items++
+		report.Categories[i].Value = report.Categories[i].Value.Add(value)
+		report.Total = report.Total.Add(value)
+
+		if h.ProductId == nil {
+			continue
+		}
+
+		j, ok := products[*h.ProductId]
+
+		if !ok {
+			j = len(report.Products)
+			products[*h.ProductId] = j
+			report.Products = append(report.Products, ProductValuation{ProductId: *h.ProductId, Product: h.Product})
+		}
+
+		report.Products[j].Items++
+		report.Products[j].Quantity = report.Products[j].Quantity.Add(quantity)
+		report.Products[j].Value = report.Products[j].Value.Add(value)
+	}
+
+	return report
+}
+
+// WriteValuationCSV writes one row per item, followed by a row per category
+// total and the grand total.
+func WriteValuationCSV(w io.Writer, report ValuationReport) error {
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{"category", "item_id", "name", "quantity", "unit", "unit_cost", "value"})
+
+	for _, i := range report.Items {
+		cw.Write([]string{i.Category, i.ItemId, i.Name, i.Quantity.String(), i.Unit, i.UnitCost.String(), i.Value.String()})
+	}
+
+	for _, c := range report.Categories {
+		cw.Write([]string{c.Category, "", "total", "", "", "", c.Value.String()})
+	}
+
+	cw.Write([]string{"", "", "total", "", "", "", report.Total.String()})
+
+	cw.Flush()
+
+	return cw.Error()
+}
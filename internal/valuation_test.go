@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/xsadia/secred/repository"
+)
+
+func costedMovement(quantity int64, cost *int64) repository.StockMovement {
+	m := repository.StockMovement{Quantity: repository.NewDecimal(quantity)}
+
+	if cost != nil {
+		c := repository.NewDecimal(*cost)
+		m.UnitCost = &c
+	}
+
+	return m
+}
+
+func valuationMovements() []repository.StockMovement {
+	two, four := int64(2), int64(4)
+
+	return []repository.StockMovement{
+		costedMovement(10, &two),
+		costedMovement(10, &four),
+		costedMovement(-5, nil),
+	}
+}
+
+func TestValueStock(t *testing.T) {
+	t.Run("should use the weighted average cost", func(t *testing.T) {
+		quantity, value := ValueStock(CostingAverage, valuationMovements())
+
+		if quantity != repository.NewDecimal(15) || value != repository.NewDecimal(45) {
+			t.Errorf("Expected 15 units worth 45, got %s worth %s", quantity, value)
+		}
+	})
+
+	t.Run("should consume the oldest layers first on FIFO", func(t *testing.T) {
+		quantity, value := ValueStock(CostingFIFO, valuationMovements())
+
+		if quantity != repository.NewDecimal(15) || value != repository.NewDecimal(50) {
+			t.Errorf("Expected 15 units worth 50, got %s worth %s", quantity, value)
+		}
+	})
+
+	t.Run("should value uncosted stock at the current cost", func(t *testing.T) {
+		movements := append(valuationMovements(), costedMovement(5, nil))
+
+		if _, value := ValueStock(CostingAverage, movements); value != repository.NewDecimal(60) {
+			t.Errorf("Expected average value to be 60, got %s", value)
+		}
+
+		if _, value := ValueStock(CostingFIFO, movements); value != repository.NewDecimal(70) {
+			t.Errorf("Expected FIFO value to be 70, got %s", value)
+		}
+	})
+}
+
+func TestCostingMethod(t *testing.T) {
+	t.Setenv("COSTING_METHOD", "")
+
+	if method, _ := CostingMethod(); method != CostingAverage {
+		t.Errorf("Expected default to be %q, got %q", CostingAverage, method)
+	}
+
+	t.Setenv("COSTING_METHOD", "LIFO")
+
+	if _, err := CostingMethod(); err == nil {
+		t.Error("Expected an error for an unknown method, got none")
+	}
+}
+
+func TestValuationReport(t *testing.T) {
+	date, _ := repository.ParseDate("2022-06-30")
+	shirt := "shirt"
+
+	report := NewValuationReport(CostingAverage, date, []repository.ItemHistory{
+		{ItemId: "1", Name: "caderno", Category: "papelaria", Unit: "un", Movements: valuationMovements()},
+		{ItemId: "2", Name: "lápis", Category: "papelaria", Unit: "un", Movements: valuationMovements()},
+		{ItemId: "3", Name: "mesa", Category: "mobília", Unit: "un", Movements: valuationMovements()[:1]},
+		{ItemId: "5", Name: "camiseta p", Category: "uniformes", Unit: "un", ProductId: &shirt, Product: "camiseta", Movements: valuationMovements()},
+		{ItemId: "6", Name: "camiseta m", Category: "uniformes", Unit: "un", ProductId: &shirt, Product: "camiseta", Movements: valuationMovements()[:1]},
+		{ItemId: "4", Name: "velho", Category: "mobília", Unit: "un", Movements: []repository.StockMovement{costedMovement(1, nil), costedMovement(-1, nil)}},
+	})
+
+	if len(report.Items) != 5 {
+		t.Fatalf("Expected items without stock to be left out, got %d items", len(report.Items))
+	}
+
+	if len(report.Categories) != 3 || report.Categories[0].Value != repository.NewDecimal(90) {
+		t.Errorf("Expected papelaria to be worth 90, got %v", report.Categories)
+	}
+
+	if report.Total != repository.NewDecimal(175) {
+		t.Errorf("Expected total to be 175, got %s", report.Total)
+	}
+
+	if len(report.Products) != 1 || report.Products[0].Items != 2 ||
+		report.Products[0].Quantity != repository.NewDecimal(25) || report.Products[0].Value != repository.NewDecimal(65) {
+		t.Errorf("Expected camiseta variants to roll up to 25 worth 65, got %v", report.Products)
+	}
+
+	var b bytes.Buffer
+
+	if err := WriteValuationCSV(&b, report); err != nil {
+		t.Fatalf("Expected no error, got %q", err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+
+	if len(lines) != 10 || lines[len(lines)-1] != ",,total,,,,175" {
+		t.Errorf("Unexpected CSV output %q", b.String())
+	}
+}
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS stock_movements;
+
+ALTER TABLE warehouse_items
+    DROP COLUMN pack_size,
+    DROP COLUMN pack_unit,
+    DROP COLUMN unit,
+    DROP COLUMN category_id,
+    ALTER COLUMN quantity TYPE int,
+    ALTER COLUMN min TYPE int,
+    ALTER COLUMN max TYPE int;
+
+DROP TABLE IF EXISTS categories;
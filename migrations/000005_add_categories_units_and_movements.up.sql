@@ -0,0 +1,24 @@
+CREATE TABLE categories (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE
+);
+
+ALTER TABLE warehouse_items
+    ALTER COLUMN quantity TYPE NUMERIC(18,4),
+    ALTER COLUMN min TYPE NUMERIC(18,4),
+    ALTER COLUMN max TYPE NUMERIC(18,4),
+    ADD COLUMN category_id uuid REFERENCES categories (id) ON DELETE SET NULL,
+    ADD COLUMN unit VARCHAR(10) NOT NULL DEFAULT 'un',
+    ADD COLUMN pack_unit VARCHAR(10),
+    ADD COLUMN pack_size NUMERIC(18,4) NOT NULL DEFAULT 1 CHECK (pack_size > 0);
+
+CREATE TABLE stock_movements (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    quantity NUMERIC(18,4) NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX stock_movements_item_id_created_at_idx ON stock_movements (item_id, created_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS stock_movement_lots;
+
+DROP TABLE IF EXISTS stock_lots;
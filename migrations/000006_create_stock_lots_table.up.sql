@@ -0,0 +1,17 @@
+CREATE TABLE stock_lots (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    code VARCHAR(50) NOT NULL DEFAULT '',
+    expires_at DATE,
+    quantity NUMERIC(18,4) NOT NULL DEFAULT 0 CHECK (quantity >= 0),
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX stock_lots_item_id_expires_at_idx ON stock_lots (item_id, expires_at);
+
+CREATE TABLE stock_movement_lots (
+    movement_id uuid NOT NULL REFERENCES stock_movements (id) ON DELETE CASCADE,
+    lot_id uuid NOT NULL REFERENCES stock_lots (id) ON DELETE CASCADE,
+    quantity NUMERIC(18,4) NOT NULL,
+    PRIMARY KEY (movement_id, lot_id)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS scan_session_lines;
+
+DROP TABLE IF EXISTS scan_sessions;
+
+DROP TABLE IF EXISTS item_barcodes;
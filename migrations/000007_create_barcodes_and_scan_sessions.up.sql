@@ -0,0 +1,22 @@
+CREATE TABLE item_barcodes (
+    code VARCHAR(14) PRIMARY KEY,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX item_barcodes_item_id_idx ON item_barcodes (item_id);
+
+CREATE TABLE scan_sessions (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('receipt', 'count')),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE TABLE scan_session_lines (
+    session_id uuid NOT NULL REFERENCES scan_sessions (id) ON DELETE CASCADE,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    quantity NUMERIC(18,4) NOT NULL DEFAULT 0,
+    PRIMARY KEY (session_id, item_id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE warehouse_items
+DROP COLUMN version;
@@ -0,0 +1,2 @@
+ALTER TABLE warehouse_items
+ADD COLUMN version int NOT NULL DEFAULT 1;
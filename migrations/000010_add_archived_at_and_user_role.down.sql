@@ -0,0 +1,5 @@
+ALTER TABLE users
+DROP COLUMN role;
+
+ALTER TABLE warehouse_items
+DROP COLUMN archived_at;
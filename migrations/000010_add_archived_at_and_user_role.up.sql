@@ -0,0 +1,5 @@
+ALTER TABLE warehouse_items
+ADD COLUMN archived_at TIMESTAMPTZ;
+
+ALTER TABLE users
+ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'operator';
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS stocktake_counts;
+
+DROP TABLE IF EXISTS stocktake_lines;
+
+DROP TABLE IF EXISTS stocktakes;
@@ -0,0 +1,28 @@
+CREATE TABLE stocktakes (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    blind BOOLEAN NOT NULL DEFAULT FALSE,
+    category_id uuid REFERENCES categories (id) ON DELETE SET NULL,
+    created_by uuid REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE TABLE stocktake_lines (
+    stocktake_id uuid NOT NULL REFERENCES stocktakes (id) ON DELETE CASCADE,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    expected NUMERIC(18,4) NOT NULL,
+    PRIMARY KEY (stocktake_id, item_id)
+);
+
+CREATE INDEX stocktake_lines_item_id_idx ON stocktake_lines (item_id);
+
+CREATE TABLE stocktake_counts (
+    stocktake_id uuid NOT NULL,
+    item_id uuid NOT NULL,
+    counted_by uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    quantity NUMERIC(18,4) NOT NULL CHECK (quantity >= 0),
+    counted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (stocktake_id, item_id, counted_by),
+    FOREIGN KEY (stocktake_id, item_id) REFERENCES stocktake_lines (stocktake_id, item_id) ON DELETE CASCADE
+);
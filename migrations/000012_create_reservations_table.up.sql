@@ -0,0 +1,15 @@
+CREATE TABLE reservations (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    document_type VARCHAR(30) NOT NULL,
+    document_id VARCHAR(100) NOT NULL,
+    quantity NUMERIC(18,4) NOT NULL CHECK (quantity > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX reservations_item_id_idx ON reservations (item_id) WHERE status = 'active';
+
+CREATE INDEX reservations_document_idx ON reservations (document_type, document_id);
@@ -0,0 +1,32 @@
+CREATE TABLE suppliers (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    tax_id VARCHAR(20),
+    email VARCHAR(255),
+    phone VARCHAR(30),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE purchase_orders (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    supplier_id uuid NOT NULL REFERENCES suppliers (id),
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    expected_at DATE,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+CREATE INDEX purchase_orders_supplier_id_idx ON purchase_orders (supplier_id);
+
+CREATE TABLE purchase_order_lines (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    purchase_order_id uuid NOT NULL REFERENCES purchase_orders (id) ON DELETE CASCADE,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id),
+    quantity NUMERIC(18,4) NOT NULL CHECK (quantity > 0),
+    received NUMERIC(18,4) NOT NULL DEFAULT 0 CHECK (received >= 0),
+    expected_at DATE,
+    UNIQUE (purchase_order_id, item_id)
+);
+
+CREATE INDEX purchase_order_lines_item_id_idx ON purchase_order_lines (item_id);
@@ -0,0 +1,2 @@
+ALTER TABLE stock_movements
+ADD COLUMN unit_cost NUMERIC(18,4) CHECK (unit_cost >= 0);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS min_max_recommendations;
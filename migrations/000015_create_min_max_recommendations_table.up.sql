@@ -0,0 +1,14 @@
+CREATE TABLE min_max_recommendations (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    current_min NUMERIC(18,4) NOT NULL,
+    current_max NUMERIC(18,4) NOT NULL,
+    min NUMERIC(18,4) NOT NULL,
+    max NUMERIC(18,4) NOT NULL,
+    rationale JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    decided_at TIMESTAMPTZ
+);
+
+CREATE INDEX min_max_recommendations_status_idx ON min_max_recommendations (status);
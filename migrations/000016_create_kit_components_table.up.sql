@@ -0,0 +1,9 @@
+CREATE TABLE kit_components (
+    kit_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    component_id uuid NOT NULL REFERENCES warehouse_items (id),
+    quantity NUMERIC(18,4) NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (kit_id, component_id),
+    CHECK (kit_id <> component_id)
+);
+
+CREATE INDEX kit_components_component_id_idx ON kit_components (component_id);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS item_merges;
+
+DROP INDEX IF EXISTS warehouse_items_name_key_idx;
+
+ALTER TABLE warehouse_items
+DROP COLUMN name_key;
@@ -0,0 +1,38 @@
+-- Normalize existing names the way the application now does, unless that
+-- would make two items share a name.
+UPDATE warehouse_items SET name = normalized.name
+FROM (
+    SELECT id, lower(regexp_replace(btrim(name), '\s+', ' ', 'g')) AS name,
+    COUNT(*) OVER (PARTITION BY lower(regexp_replace(btrim(name), '\s+', ' ', 'g'))) AS n
+    FROM warehouse_items
+) AS normalized
+WHERE normalized.id = warehouse_items.id AND normalized.n = 1;
+
+ALTER TABLE warehouse_items ADD COLUMN name_key VARCHAR(100);
+
+UPDATE warehouse_items SET name_key = unaccent(lower(regexp_replace(btrim(name), '\s+', ' ', 'g')));
+
+-- Items that already clash keep a distinct key until they are merged.
+UPDATE warehouse_items SET name_key = name_key || ':' || id
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (PARTITION BY name_key ORDER BY id) AS n FROM warehouse_items
+    ) AS ranked
+    WHERE ranked.n > 1
+);
+
+ALTER TABLE warehouse_items ALTER COLUMN name_key SET NOT NULL;
+
+CREATE UNIQUE INDEX warehouse_items_name_key_idx ON warehouse_items (name_key);
+
+CREATE TABLE item_merges (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    merged_item_id uuid NOT NULL,
+    merged_name VARCHAR(50) NOT NULL,
+    merged_quantity NUMERIC(18,4) NOT NULL,
+    merged_by uuid REFERENCES users (id) ON DELETE SET NULL,
+    merged_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX item_merges_item_id_idx ON item_merges (item_id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS stock_snapshots;
+
+ALTER TABLE warehouse_items
+DROP COLUMN created_at;
@@ -0,0 +1,22 @@
+ALTER TABLE warehouse_items
+ADD COLUMN created_at TIMESTAMPTZ;
+
+CREATE TABLE stock_snapshots (
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    taken_at TIMESTAMPTZ NOT NULL,
+    quantity NUMERIC(18,4) NOT NULL,
+    PRIMARY KEY (item_id, taken_at)
+);
+
+-- Items created before this column existed date from their first movement,
+-- or else their first snapshot. Items with neither are taken to have always
+-- existed, so point in time queries never leave them out.
+UPDATE warehouse_items SET created_at = COALESCE(
+    (SELECT MIN(created_at) FROM stock_movements WHERE item_id = warehouse_items.id),
+    (SELECT MIN(taken_at) FROM stock_snapshots WHERE item_id = warehouse_items.id),
+    '-infinity'
+);
+
+ALTER TABLE warehouse_items
+ALTER COLUMN created_at SET DEFAULT NOW(),
+ALTER COLUMN created_at SET NOT NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS asset_events;
+
+DROP TABLE IF EXISTS assets;
@@ -0,0 +1,30 @@
+CREATE TABLE assets (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    serial_number VARCHAR(100) NOT NULL UNIQUE,
+    kind VARCHAR(30) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    condition VARCHAR(20) NOT NULL DEFAULT 'good'
+        CHECK (condition IN ('new', 'good', 'fair', 'poor', 'broken')),
+    school_id uuid REFERENCES schools (id),
+    due_at DATE,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    retired_at TIMESTAMPTZ
+);
+
+CREATE INDEX assets_school_id_idx ON assets (school_id);
+
+CREATE TABLE asset_events (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    asset_id uuid NOT NULL REFERENCES assets (id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL
+        CHECK (kind IN ('registered', 'checked_out', 'checked_in', 'retired')),
+    school_id uuid REFERENCES schools (id) ON DELETE SET NULL,
+    condition VARCHAR(20) NOT NULL,
+    due_at DATE,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    user_id uuid REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX asset_events_asset_id_idx ON asset_events (asset_id, created_at);
@@ -0,0 +1,10 @@
+DELETE FROM asset_events WHERE kind IN ('repair_opened', 'repair_closed');
+
+ALTER TABLE asset_events DROP CONSTRAINT asset_events_kind_check;
+
+ALTER TABLE asset_events ADD CONSTRAINT asset_events_kind_check
+    CHECK (kind IN ('registered', 'checked_out', 'checked_in', 'retired'));
+
+DROP TABLE IF EXISTS maintenance_ticket_notes;
+
+DROP TABLE IF EXISTS maintenance_tickets;
@@ -0,0 +1,35 @@
+CREATE TABLE maintenance_tickets (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    asset_id uuid NOT NULL REFERENCES assets (id) ON DELETE CASCADE,
+    school_id uuid REFERENCES schools (id) ON DELETE SET NULL,
+    description VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'in_progress', 'resolved', 'cancelled')),
+    technician_id uuid REFERENCES users (id) ON DELETE SET NULL,
+    opened_by uuid REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMPTZ
+);
+
+-- An asset is under repair while it has an active ticket, and has at most one.
+CREATE UNIQUE INDEX maintenance_tickets_active_asset_idx ON maintenance_tickets (asset_id)
+    WHERE status IN ('open', 'in_progress');
+
+CREATE INDEX maintenance_tickets_status_idx ON maintenance_tickets (status);
+
+CREATE TABLE maintenance_ticket_notes (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    ticket_id uuid NOT NULL REFERENCES maintenance_tickets (id) ON DELETE CASCADE,
+    status VARCHAR(20),
+    body VARCHAR(255) NOT NULL DEFAULT '',
+    user_id uuid REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX maintenance_ticket_notes_ticket_id_idx ON maintenance_ticket_notes (ticket_id, created_at);
+
+ALTER TABLE asset_events DROP CONSTRAINT asset_events_kind_check;
+
+ALTER TABLE asset_events ADD CONSTRAINT asset_events_kind_check
+    CHECK (kind IN ('registered', 'checked_out', 'checked_in', 'retired', 'repair_opened', 'repair_closed'));
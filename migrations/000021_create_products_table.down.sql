@@ -0,0 +1,6 @@
+ALTER TABLE warehouse_items
+    DROP CONSTRAINT IF EXISTS warehouse_items_variant_check,
+    DROP COLUMN IF EXISTS variant,
+    DROP COLUMN IF EXISTS product_id;
+
+DROP TABLE IF EXISTS products;
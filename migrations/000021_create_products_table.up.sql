@@ -0,0 +1,18 @@
+CREATE TABLE products (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    name VARCHAR(50) NOT NULL,
+    name_key VARCHAR(100) NOT NULL UNIQUE,
+    attributes TEXT[] NOT NULL,
+    category_id uuid REFERENCES categories (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- A variant is an ordinary item that belongs to a product, told apart from
+-- its siblings by its attribute values, e.g. {"size": "m", "color": "blue"}.
+ALTER TABLE warehouse_items
+    ADD COLUMN product_id uuid REFERENCES products (id),
+    ADD COLUMN variant JSONB,
+    ADD CONSTRAINT warehouse_items_variant_check CHECK ((product_id IS NULL) = (variant IS NULL));
+
+CREATE UNIQUE INDEX warehouse_items_product_variant_idx ON warehouse_items (product_id, variant)
+    WHERE product_id IS NOT NULL;
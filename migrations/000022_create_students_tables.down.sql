@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS handouts;
+
+DROP TABLE IF EXISTS entitlements;
+
+DROP TABLE IF EXISTS students;
@@ -0,0 +1,40 @@
+CREATE TABLE students (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    school_id uuid NOT NULL REFERENCES schools (id) ON DELETE CASCADE,
+    enrollment_id VARCHAR(30) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    grade VARCHAR(20) NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (school_id, enrollment_id)
+);
+
+-- An entitlement gives every active student of a school, or of every school,
+-- a quantity of an item or of any variant of a product, once or every year.
+-- An empty grade means every grade.
+CREATE TABLE entitlements (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    item_id uuid REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    product_id uuid REFERENCES products (id) ON DELETE CASCADE,
+    quantity NUMERIC(18,4) NOT NULL CHECK (quantity > 0),
+    period VARCHAR(10) NOT NULL CHECK (period IN ('once', 'year')),
+    grade VARCHAR(20) NOT NULL DEFAULT '',
+    school_id uuid REFERENCES schools (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((item_id IS NULL) <> (product_id IS NULL))
+);
+
+CREATE TABLE handouts (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    student_id uuid NOT NULL REFERENCES students (id) ON DELETE CASCADE,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id),
+    quantity NUMERIC(18,4) NOT NULL CHECK (quantity > 0),
+    handed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    user_id uuid REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX handouts_student_id_idx ON handouts (student_id, handed_at);
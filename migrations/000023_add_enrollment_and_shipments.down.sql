@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS shipment_lines;
+
+DROP TABLE IF EXISTS shipments;
+
+ALTER TABLE schools DROP COLUMN IF EXISTS enrollment;
@@ -0,0 +1,26 @@
+ALTER TABLE schools ADD COLUMN enrollment INTEGER NOT NULL DEFAULT 0 CHECK (enrollment >= 0);
+
+UPDATE schools SET enrollment = counts.n
+FROM (SELECT school_id, COUNT(*) AS n FROM students WHERE active GROUP BY school_id) AS counts
+WHERE counts.school_id = schools.id;
+
+CREATE TABLE shipments (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    school_id uuid NOT NULL REFERENCES schools (id),
+    status VARCHAR(20) NOT NULL DEFAULT 'draft'
+        CHECK (status IN ('draft', 'dispatched', 'cancelled')),
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    created_by uuid REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ
+);
+
+CREATE INDEX shipments_school_id_idx ON shipments (school_id, created_at);
+
+CREATE TABLE shipment_lines (
+    shipment_id uuid NOT NULL REFERENCES shipments (id) ON DELETE CASCADE,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id),
+    quantity NUMERIC(18,4) NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (shipment_id, item_id)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS shipments_school_id_period_key;
+
+ALTER TABLE shipments DROP COLUMN IF EXISTS period;
+
+DROP TABLE IF EXISTS allotments;
@@ -0,0 +1,17 @@
+-- An allotment sends every school a quantity of an item per enrolled student,
+-- every semester or once a year in the first semester.
+CREATE TABLE allotments (
+    id uuid DEFAULT uuid_generate_v4() PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    item_id uuid NOT NULL REFERENCES warehouse_items (id) ON DELETE CASCADE,
+    quantity NUMERIC(18,4) NOT NULL CHECK (quantity > 0),
+    period VARCHAR(10) NOT NULL CHECK (period IN ('semester', 'year')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Shipments generated from allotments record the semester they are for, such
+-- as 2026-S1, so that generating it again skips schools already served.
+ALTER TABLE shipments ADD COLUMN period VARCHAR(7);
+
+CREATE UNIQUE INDEX shipments_school_id_period_key ON shipments (school_id, period)
+    WHERE period IS NOT NULL AND status <> 'cancelled';
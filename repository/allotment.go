@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	AllotmentSemester = "semester"
+	AllotmentYear     = "year"
+
+	allotmentColumns = `allotments.id, allotments.name, allotments.item_id, warehouse_items.name,
+		warehouse_items.unit, allotments.quantity, allotments.period, allotments.created_at,
+		warehouse_items.archived_at IS NOT NULL, warehouse_items.quantity - ` + reservedQuantity
+
+	allotmentTables = `allotments JOIN warehouse_items ON warehouse_items.id = allotments.item_id`
+)
+
+var AllotmentPeriods = []string{AllotmentSemester, AllotmentYear}
+
+var ErrAllotmentNotFound = errors.New("allotment not found")
+
+// Allotment is a standard quantity of an item every school gets per enrolled
+// student, every semester or once a year in the first semester. Available is
+// the item's stock that is not reserved. Allotments of archived items are kept
+// but no longer sent.
+type Allotment struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	ItemId    string    `json:"item_id"`
+	ItemName  string    `json:"item,omitempty"`
+	Unit      string    `json:"unit"`
+	Quantity  Decimal   `json:"quantity"`
+	Period    string    `json:"period"`
+	CreatedAt time.Time `json:"created_at"`
+	Archived  bool      `json:"archived"`
+	Available Decimal   `json:"available"`
+}
+
+func (a *Allotment) scan(row rowScanner) error {
+	return row.Scan(
+		&a.Id, &a.Name, &a.ItemId, &a.ItemName, &a.Unit, &a.Quantity, &a.Period, &a.CreatedAt, &a.Archived, &a.Available,
+	)
+}
+
+func (a *Allotment) CreateAllotment(db *sql.DB) error {
+	if err := db.QueryRow(
+		"INSERT INTO allotments (name, item_id, quantity, period) VALUES ($1, $2, $3, $4) RETURNING id",
+		a.Name, a.ItemId, a.Quantity, a.Period,
+	).Scan(&a.Id); err != nil {
+		return err
+	}
+
+	return a.scan(db.QueryRow("SELECT "+allotmentColumns+" FROM "+allotmentTables+" WHERE allotments.id = $1", a.Id))
+}
+
+func GetAllotments(db *sql.DB) ([]Allotment, error) {
+	rows, err := db.Query("SELECT " + allotmentColumns + " FROM " + allotmentTables + " ORDER BY allotments.name, allotments.id")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	allotments := []Allotment{}
+
+	for rows.Next() {
+		var a Allotment
+
+		if err := a.scan(rows); err != nil {
+			return nil, err
+		}
+
+		allotments = append(allotments, a)
+	}
+
+	return allotments, rows.Err()
+}
+
+// DeleteAllotment removes the rule. Shipments already generated from it are
+// kept.
+func (a *Allotment) DeleteAllotment(db *sql.DB) error {
+	res, err := db.Exec("DELETE FROM allotments WHERE id = $1", a.Id)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrAllotmentNotFound
+	}
+
+	return nil
+}
+
+// GetPeriodShipments maps each school to the shipment generated for it in
+// the period, leaving out cancelled ones.
+func GetPeriodShipments(db *sql.DB, period string) (map[string]string, error) {
+	rows, err := db.Query(
+		"SELECT school_id, id FROM shipments WHERE period = $1 AND status <> 'cancelled'", period,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	shipments := map[string]string{}
+
+	for rows.Next() {
+		var schoolId, id string
+
+		if err := rows.Scan(&schoolId, &id); err != nil {
+			return nil, err
+		}
+
+		shipments[schoolId] = id
+	}
+
+	return shipments, rows.Err()
+}
@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	AssetNew    = "new"
+	AssetGood   = "good"
+	AssetFair   = "fair"
+	AssetPoor   = "poor"
+	AssetBroken = "broken"
+
+	AssetRegistered   = "registered"
+	AssetCheckedOut   = "checked_out"
+	AssetCheckedIn    = "checked_in"
+	AssetRetired      = "retired"
+	AssetRepairOpened = "repair_opened"
+	AssetRepairClosed = "repair_closed"
+
+	// Statuses assets are listed by. An asset is in the warehouse unless a
+	// school holds it, and overdue when that school is past its due date.
+	AssetInWarehouse = "in_warehouse"
+	AssetOverdue     = "overdue"
+	AssetUnderRepair = "under_repair"
+
+	assetUnderRepair = `EXISTS (SELECT 1 FROM maintenance_tickets
+		WHERE maintenance_tickets.asset_id = assets.id AND maintenance_tickets.status IN ('open', 'in_progress'))`
+
+	assetColumns = `assets.id, assets.serial_number, assets.kind, assets.model, assets.condition,
+		assets.school_id, COALESCE(schools.name, ''), assets.due_at,
+		assets.school_id IS NOT NULL AND assets.due_at < CURRENT_DATE,
+		` + assetUnderRepair + `, assets.notes, assets.created_at, assets.retired_at`
+
+	assetTables = `assets LEFT JOIN schools ON schools.id = assets.school_id`
+
+	assetEventColumns = `asset_events.id, asset_events.asset_id, asset_events.kind, asset_events.school_id,
+		COALESCE(schools.name, ''), asset_events.condition, asset_events.due_at, asset_events.notes,
+		asset_events.user_id, asset_events.created_at`
+)
+
+var AssetConditions = []string{AssetNew, AssetGood, AssetFair, AssetPoor, AssetBroken}
+
+var (
+	ErrAssetRetired       = errors.New("asset is retired")
+	ErrAssetCheckedOut    = errors.New("asset is checked out to a school")
+	ErrAssetNotCheckedOut = errors.New("asset is not checked out")
+	ErrAssetBroken        = errors.New("asset is broken")
+	ErrAssetUnderRepair   = errors.New("asset is under repair")
+)
+
+// Asset is a piece of equipment tracked by its serial number, such as a
+// laptop or a projector. It is held by the warehouse unless SchoolId is set.
+type Asset struct {
+	Id           string     `json:"id"`
+	SerialNumber string     `json:"serial_number"`
+	Kind         string     `json:"kind"`
+	Model        string     `json:"model"`
+	Condition    string     `json:"condition"`
+	SchoolId     *string    `json:"school_id"`
+	SchoolName   string     `json:"school,omitempty"`
+	DueAt        *Date      `json:"due_at"`
+	Overdue      bool       `json:"overdue"`
+	UnderRepair  bool       `json:"under_repair"`
+	Notes        string     `json:"notes"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RetiredAt    *time.Time `json:"retired_at"`
+}
+
+// AssetEvent is an entry in an asset's history, recording its holder and
+// condition after the event.
+type AssetEvent struct {
+	Id         string    `json:"id"`
+	AssetId    string    `json:"asset_id"`
+	Kind       string    `json:"kind"`
+	SchoolId   *string   `json:"school_id"`
+	SchoolName string    `json:"school,omitempty"`
+	Condition  string    `json:"condition"`
+	DueAt      *Date     `json:"due_at"`
+	Notes      string    `json:"notes"`
+	UserId     *string   `json:"user_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AssetFilter narrows GetAssets. Status is one of AssetInWarehouse,
+// AssetCheckedOut, AssetOverdue, AssetUnderRepair or AssetRetired; retired
+// assets are left out otherwise.
+type AssetFilter struct {
+	Kind     string
+	SchoolId string
+	Status   string
+}
+
+func (a *Asset) scan(row rowScanner) error {
+	return row.Scan(
+		&a.Id, &a.SerialNumber, &a.Kind, &a.Model, &a.Condition, &a.SchoolId, &a.SchoolName,
+		&a.DueAt, &a.Overdue, &a.UnderRepair, &a.Notes, &a.CreatedAt, &a.RetiredAt,
+	)
+}
+
+func (a *Asset) get(q querier, lock bool) error {
+	query := "SELECT " + assetColumns + " FROM " + assetTables + " WHERE assets.id = $1"
+
+	if lock {
+		query += " FOR UPDATE OF assets"
+	}
+
+	return a.scan(q.QueryRow(query, a.Id))
+}
+
+func (a *Asset) GetAssetById(db *sql.DB) error {
+	return a.get(db, false)
+}
+
+func (a *Asset) CreateAsset(db *sql.DB, userId *string) error {
+	if a.Condition == "" {
+		a.Condition = AssetGood
+	}
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = tx.QueryRow(
+		`INSERT INTO assets (serial_number, kind, model, condition, notes)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		a.SerialNumber, a.Kind, a.Model, a.Condition, a.Notes,
+	).Scan(&a.Id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = a.record(tx, AssetRegistered, a.Notes, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func GetAssets(db *sql.DB, f AssetFilter) ([]Asset, error) {
+	var args queryArgs
+
+	conditions := []string{}
+
+	if f.Kind != "" {
+		conditions = append(conditions, "assets.kind = "+args.add(f.Kind))
+	}
+
+	if f.SchoolId != "" {
+		conditions = append(conditions, "assets.school_id = "+args.add(f.SchoolId))
+	}
+
+	switch f.Status {
+	case AssetRetired:
+		conditions = append(conditions, "assets.retired_at IS NOT NULL")
+	case AssetInWarehouse:
+		conditions = append(conditions, "assets.retired_at IS NULL", "assets.school_id IS NULL")
+	case AssetCheckedOut:
+		conditions = append(conditions, "assets.school_id IS NOT NULL")
+	case AssetOverdue:
+		conditions = append(conditions, "assets.school_id IS NOT NULL", "assets.due_at < CURRENT_DATE")
+	case AssetUnderRepair:
+		conditions = append(conditions, assetUnderRepair)
+	default:
+		conditions = append(conditions, "assets.retired_at IS NULL")
+	}
+
+	rows, err := db.Query(
+		"SELECT "+assetColumns+" FROM "+assetTables+whereClause(conditions)+" ORDER BY assets.kind, assets.serial_number",
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	assets := []Asset{}
+
+	for rows.Next() {
+		var a Asset
+
+		if err := a.scan(rows); err != nil {
+			return nil, err
+		}
+
+		assets = append(assets, a)
+	}
+
+	return assets, rows.Err()
+}
+
+// CheckOut loans the asset to a school until dueAt, which may be nil for open
+// ended loans. Only assets in the warehouse that are neither broken nor under
+// repair can go out.
+func (a *Asset) CheckOut(db *sql.DB, schoolId string, dueAt *Date, notes string, userId *string) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = a.get(tx, true); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	switch {
+	case a.RetiredAt != nil:
+		err = ErrAssetRetired
+	case a.SchoolId != nil:
+		err = ErrAssetCheckedOut
+	case a.UnderRepair:
+		err = ErrAssetUnderRepair
+	case a.Condition == AssetBroken:
+		err = ErrAssetBroken
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec("UPDATE assets SET school_id = $1, due_at = $2 WHERE id = $3", schoolId, dueAt, a.Id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = a.get(tx, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = a.record(tx, AssetCheckedOut, notes, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CheckIn returns the asset to the warehouse, recording the condition it came
+// back in. An empty condition keeps the current one.
+func (a *Asset) CheckIn(db *sql.DB, condition, notes string, userId *string) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = a.get(tx, true); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if a.SchoolId == nil {
+		tx.Rollback()
+		return ErrAssetNotCheckedOut
+	}
+
+	if condition == "" {
+		condition = a.Condition
+	}
+
+	if _, err = tx.Exec(
+		"UPDATE assets SET school_id = NULL, due_at = NULL, condition = $1 WHERE id = $2", condition, a.Id,
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// The event names the school the asset came back from.
+	a.Condition = condition
+	a.DueAt = nil
+
+	if err = a.record(tx, AssetCheckedIn, notes, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = a.get(tx, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Retire takes the asset out of use for good. It must be in the warehouse and
+// not under repair.
+func (a *Asset) Retire(db *sql.DB, notes string, userId *string) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = a.get(tx, true); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	switch {
+	case a.RetiredAt != nil:
+		err = ErrAssetRetired
+	case a.SchoolId != nil:
+		err = ErrAssetCheckedOut
+	case a.UnderRepair:
+		err = ErrAssetUnderRepair
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.QueryRow(
+		"UPDATE assets SET retired_at = NOW() WHERE id = $1 RETURNING retired_at", a.Id,
+	).Scan(&a.RetiredAt); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = a.record(tx, AssetRetired, notes, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (a *Asset) record(tx *sql.Tx, kind, notes string, userId *string) error {
+	_, err := tx.Exec(
+		`INSERT INTO asset_events (asset_id, kind, school_id, condition, due_at, notes, user_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		a.Id, kind, a.SchoolId, a.Condition, a.DueAt, notes, userId,
+	)
+
+	return err
+}
+
+// GetHistory lists the asset's events, oldest first.
+func (a *Asset) GetHistory(db *sql.DB) ([]AssetEvent, error) {
+	rows, err := db.Query(
+		"SELECT "+assetEventColumns+` FROM asset_events LEFT JOIN schools ON schools.id = asset_events.school_id
+		 WHERE asset_events.asset_id = $1 ORDER BY asset_events.created_at, asset_events.id`,
+		a.Id,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := []AssetEvent{}
+
+	for rows.Next() {
+		var e AssetEvent
+
+		if err := rows.Scan(
+			&e.Id, &e.AssetId, &e.Kind, &e.SchoolId, &e.SchoolName, &e.Condition,
+			&e.DueAt, &e.Notes, &e.UserId, &e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
@@ -0,0 +1,59 @@
+package repository
+
+import "database/sql"
+
+type Barcode struct {
+	Code   string `json:"code"`
+	ItemId string `json:"item_id"`
+}
+
+func (b *Barcode) CreateBarcode(db *sql.DB) error {
+	_, err := db.Exec("INSERT INTO item_barcodes (code, item_id) VALUES ($1, $2)", b.Code, b.ItemId)
+
+	return err
+}
+
+func (b *Barcode) DeleteBarcode(db *sql.DB) (bool, error) {
+	res, err := db.Exec("DELETE FROM item_barcodes WHERE code = $1 AND item_id = $2", b.Code, b.ItemId)
+
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+
+	return n > 0, err
+}
+
+func (wi *WarehouseItem) GetWarehouseItemByBarcode(db *sql.DB, code string) error {
+	return wi.scan(db.QueryRow(
+		"SELECT "+warehouseItemColumns+" FROM "+warehouseItemTables+
+			" JOIN item_barcodes ON item_barcodes.item_id = warehouse_items.id"+
+			" WHERE item_barcodes.code = $1 AND warehouse_items.archived_at IS NULL",
+		code,
+	))
+}
+
+func (wi *WarehouseItem) GetBarcodes(db *sql.DB) error {
+	rows, err := db.Query("SELECT code FROM item_barcodes WHERE item_id = $1 ORDER BY created_at", wi.Id)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	wi.Barcodes = []string{}
+
+	for rows.Next() {
+		var code string
+
+		if err := rows.Scan(&code); err != nil {
+			return err
+		}
+
+		wi.Barcodes = append(wi.Barcodes, code)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var ErrBatchAborted = errors.New("batch was aborted by an earlier operation")
+
+// Batch runs several operations in a single transaction. By default the first
+// failure aborts the batch; with continueOnError every operation runs under
+// its own savepoint, so a failed one is undone without losing the others.
+type Batch struct {
+	tx              *sql.Tx
+	continueOnError bool
+	aborted         bool
+}
+
+func BeginBatch(db *sql.DB, continueOnError bool) (*Batch, error) {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Batch{tx: tx, continueOnError: continueOnError}, nil
+}
+
+// Do runs fn as part of the batch and returns its error. Once the batch is
+// aborted fn is not run and ErrBatchAborted is returned.
+func (b *Batch) Do(fn func(tx *sql.Tx) error) error {
+	if b.aborted {
+		return ErrBatchAborted
+	}
+
+	if !b.continueOnError {
+		err := fn(b.tx)
+		b.aborted = err != nil
+
+		return err
+	}
+
+	if _, err := b.tx.Exec("SAVEPOINT batch_operation"); err != nil {
+		b.aborted = true
+		return err
+	}
+
+	if err := fn(b.tx); err != nil {
+		if _, rbErr := b.tx.Exec("ROLLBACK TO SAVEPOINT batch_operation"); rbErr != nil {
+			b.aborted = true
+		}
+
+		return err
+	}
+
+	if _, err := b.tx.Exec("RELEASE SAVEPOINT batch_operation"); err != nil {
+		b.aborted = true
+		return err
+	}
+
+	return nil
+}
+
+func (b *Batch) Aborted() bool {
+	return b.aborted
+}
+
+// Commit commits the batch, or rolls it back and returns ErrBatchAborted if
+// it was aborted.
+func (b *Batch) Commit() error {
+	if b.aborted {
+		b.tx.Rollback()
+		return ErrBatchAborted
+	}
+
+	return b.tx.Commit()
+}
+
+func (b *Batch) Rollback() error {
+	return b.tx.Rollback()
+}
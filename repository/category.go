@@ -0,0 +1,45 @@
+package repository
+
+import "database/sql"
+
+type Category struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (c *Category) CreateCategory(db *sql.DB) error {
+	return db.QueryRow("INSERT INTO categories (name) VALUES ($1) RETURNING id", c.Name).Scan(&c.Id)
+}
+
+func (c *Category) getOrCreate(tx *sql.Tx) error {
+	return tx.QueryRow(
+		`INSERT INTO categories (name) VALUES ($1)
+		 ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		 RETURNING id`,
+		c.Name,
+	).Scan(&c.Id)
+}
+
+func GetCategories(db *sql.DB) ([]Category, error) {
+	rows, err := db.Query("SELECT id, name FROM categories ORDER BY name")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	categories := []Category{}
+
+	for rows.Next() {
+		var c Category
+
+		if err := rows.Scan(&c.Id, &c.Name); err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, c)
+	}
+
+	return categories, nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Date is a calendar day serialized as "2006-01-02".
+type Date struct {
+	time.Time
+}
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(dateLayout, s)
+
+	if err != nil {
+		return Date{}, err
+	}
+
+	return Date{t}, nil
+}
+
+func (d Date) String() string {
+	return d.Format(dateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(d.String())), nil
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+
+	if err != nil {
+		return fmt.Errorf("invalid date %s", b)
+	}
+
+	*d, err = ParseDate(s)
+
+	return err
+}
+
+func (d *Date) Scan(src any) error {
+	t, ok := src.(time.Time)
+
+	if !ok {
+		return fmt.Errorf("cannot scan %T into Date", src)
+	}
+
+	d.Time = t
+
+	return nil
+}
+
+func (d Date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
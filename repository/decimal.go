@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact fixed point number with four decimal places. It is used
+// for quantities and costs so that 0.1 kg of rice stays 0.1 kg.
+type Decimal int64
+
+const (
+	decimalPlaces = 4
+	decimalScale  = 10000
+)
+
+var (
+	errInvalidDecimal  = errors.New("invalid decimal")
+	errDecimalOverflow = errors.New("decimal overflow")
+)
+
+// NewDecimal returns the Decimal for the whole number i.
+func NewDecimal(i int64) Decimal {
+	return Decimal(i * decimalScale)
+}
+
+// ParseDecimal parses strings like "12", "-0.5" or "1,25". A comma is accepted
+// as the decimal separator as long as there is no dot in the string.
+func ParseDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+
+	if !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+
+	if s == "" {
+		return 0, errInvalidDecimal
+	}
+
+	negative := false
+
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+
+	if intPart == "" && fracPart == "" {
+		return 0, errInvalidDecimal
+	}
+
+	if len(fracPart) > decimalPlaces {
+		if strings.Trim(fracPart[decimalPlaces:], "0") != "" {
+			return 0, fmt.Errorf("%w: more than %d decimal places", errInvalidDecimal, decimalPlaces)
+		}
+
+		fracPart = fracPart[:decimalPlaces]
+	}
+
+	fracPart += strings.Repeat("0", decimalPlaces-len(fracPart))
+
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return 0, errInvalidDecimal
+		}
+	}
+
+	v, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+
+	if err != nil {
+		return 0, errDecimalOverflow
+	}
+
+	if negative {
+		v = -v
+	}
+
+	return Decimal(v), nil
+}
+
+func (d Decimal) String() string {
+	v := int64(d)
+	sign := ""
+
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	intPart := v / decimalScale
+	fracPart := v % decimalScale
+
+	if fracPart == 0 {
+		return sign + strconv.FormatInt(intPart, 10)
+	}
+
+	frac := strings.TrimRight(fmt.Sprintf("%0*d", decimalPlaces, fracPart), "0")
+
+	return sign + strconv.FormatInt(intPart, 10) + "." + frac
+}
+
+func (d Decimal) Add(o Decimal) Decimal {
+	return d + o
+}
+
+func (d Decimal) Sub(o Decimal) Decimal {
+	return d - o
+}
+
+func (d Decimal) Neg() Decimal {
+	return -d
+}
+
+// Mul multiplies two decimals, rounding half away from zero.
+func (d Decimal) Mul(o Decimal) Decimal {
+	p := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(int64(o)))
+
+	return Decimal(roundQuo(p, big.NewInt(decimalScale)))
+}
+
+// Div divides two decimals, rounding half away from zero. Dividing by zero
+// returns zero.
+func (d Decimal) Div(o Decimal) Decimal {
+	if o == 0 {
+		return 0
+	}
+
+	n := new(big.Int).Mul(big.NewInt(int64(d)), big.NewInt(decimalScale))
+
+	return Decimal(roundQuo(n, big.NewInt(int64(o))))
+}
+
+// Floor returns the largest whole number less than or equal to d.
+func (d Decimal) Floor() Decimal {
+	r := d % decimalScale
+
+	if r < 0 {
+		return d - r - decimalScale
+	}
+
+	return d - r
+}
+
+// Ceil returns the smallest whole number greater than or equal to d.
+func (d Decimal) Ceil() Decimal {
+	r := d % decimalScale
+
+	if r > 0 {
+		return d - r + decimalScale
+	}
+
+	return d - r
+}
+
+// IntPart returns the whole number part of d.
+func (d Decimal) IntPart() int64 {
+	return int64(d) / decimalScale
+}
+
+func (d Decimal) Float64() float64 {
+	return float64(d) / decimalScale
+}
+
+// DecimalFromFloat converts f rounding to four decimal places.
+func DecimalFromFloat(f float64) Decimal {
+	if f < 0 {
+		return Decimal(f*decimalScale - 0.5)
+	}
+
+	return Decimal(f*decimalScale + 0.5)
+}
+
+func (d Decimal) IsZero() bool {
+	return d == 0
+}
+
+func (d Decimal) IsNegative() bool {
+	return d < 0
+}
+
+func (d Decimal) IsPositive() bool {
+	return d > 0
+}
+
+// IsWhole reports whether d has no fractional part.
+func (d Decimal) IsWhole() bool {
+	return d%decimalScale == 0
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts both JSON numbers and strings.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	s := string(b)
+
+	if s == "null" {
+		return nil
+	}
+
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	if strings.ContainsAny(s, "eE") {
+		return errInvalidDecimal
+	}
+
+	v, err := ParseDecimal(s)
+
+	if err != nil {
+		return err
+	}
+
+	*d = v
+
+	return nil
+}
+
+func (d *Decimal) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*d = 0
+		return nil
+	case int64:
+		*d = NewDecimal(v)
+		return nil
+	case float64:
+		*d = DecimalFromFloat(v)
+		return nil
+	case []byte:
+		return d.scanString(string(v))
+	case string:
+		return d.scanString(v)
+	}
+
+	return fmt.Errorf("cannot scan %T into Decimal", src)
+}
+
+func (d *Decimal) scanString(s string) error {
+	v, err := ParseDecimal(s)
+
+	if err != nil {
+		return err
+	}
+
+	*d = v
+
+	return nil
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func roundQuo(n, m *big.Int) int64 {
+	q, r := new(big.Int).QuoRem(n, m, new(big.Int))
+
+	r.Abs(r).Mul(r, big.NewInt(2))
+
+	if r.Cmp(new(big.Int).Abs(m)) >= 0 {
+		if (n.Sign() < 0) != (m.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	return q.Int64()
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseDecimal(t *testing.T) {
+	t.Run("should parse decimals", func(t *testing.T) {
+		cases := map[string]string{
+			"12":      "12",
+			"0.5":     "0.5",
+			"-1.25":   "-1.25",
+			"1,75":    "1.75",
+			".5":      "0.5",
+			"3.10000": "3.1",
+		}
+
+		for in, expected := range cases {
+			d, err := ParseDecimal(in)
+
+			if err != nil {
+				t.Errorf("Expected no error parsing %q, got %q", in, err.Error())
+			}
+
+			if d.String() != expected {
+				t.Errorf("Expected %q to parse as %q, got %q", in, expected, d.String())
+			}
+		}
+	})
+
+	t.Run("should return error for invalid decimals", func(t *testing.T) {
+		for _, in := range []string{"", "abc", "1.2.3", "0.00001", "-", "1e3"} {
+			if _, err := ParseDecimal(in); err == nil {
+				t.Errorf("Expected an error parsing %q, got none", in)
+			}
+		}
+	})
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a, _ := ParseDecimal("2.5")
+	b, _ := ParseDecimal("0.3")
+
+	if got := a.Mul(b).String(); got != "0.75" {
+		t.Errorf("Expected 2.5 * 0.3 to be 0.75, got %s", got)
+	}
+
+	if got := NewDecimal(1).Div(NewDecimal(3)).String(); got != "0.3333" {
+		t.Errorf("Expected 1 / 3 to be 0.3333, got %s", got)
+	}
+
+	if got := NewDecimal(2).Div(NewDecimal(3)).String(); got != "0.6667" {
+		t.Errorf("Expected 2 / 3 to be 0.6667, got %s", got)
+	}
+
+	if got := a.Floor().String(); got != "2" {
+		t.Errorf("Expected floor of 2.5 to be 2, got %s", got)
+	}
+
+	if got := a.Neg().Floor().String(); got != "-3" {
+		t.Errorf("Expected floor of -2.5 to be -3, got %s", got)
+	}
+
+	if got := a.Ceil().String(); got != "3" {
+		t.Errorf("Expected ceil of 2.5 to be 3, got %s", got)
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var v struct {
+		Quantity Decimal `json:"quantity"`
+	}
+
+	if err := json.Unmarshal([]byte(`{"quantity": 1.05}`), &v); err != nil {
+		t.Fatalf("Expected no error, got %q", err.Error())
+	}
+
+	if v.Quantity.String() != "1.05" {
+		t.Errorf("Expected quantity to be 1.05, got %s", v.Quantity)
+	}
+
+	if err := json.Unmarshal([]byte(`{"quantity": "2.5"}`), &v); err != nil {
+		t.Fatalf("Expected no error, got %q", err.Error())
+	}
+
+	b, _ := json.Marshal(v)
+
+	if string(b) != `{"quantity":2.5}` {
+		t.Errorf("Expected %q, got %q", `{"quantity":2.5}`, string(b))
+	}
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	EntitlementOnce = "once"
+	EntitlementYear = "year"
+
+	entitlementColumns = `entitlements.id, entitlements.name, entitlements.item_id, entitlements.product_id,
+		COALESCE(warehouse_items.name, products.name), entitlements.quantity, entitlements.period,
+		entitlements.grade, entitlements.school_id, entitlements.created_at`
+
+	entitlementTables = `entitlements
+		LEFT JOIN warehouse_items ON warehouse_items.id = entitlements.item_id
+		LEFT JOIN products ON products.id = entitlements.product_id`
+)
+
+var EntitlementPeriods = []string{EntitlementOnce, EntitlementYear}
+
+var ErrEntitlementNotFound = errors.New("entitlement not found")
+
+// Entitlement is what each active student is due: Quantity of an item, or of
+// any variant of a product so every size counts, either once or every calendar
+// year. It applies to students of one grade, or of every grade when Grade is
+// empty, at one school or at every school when SchoolId is nil.
+type Entitlement struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	ItemId    *string   `json:"item_id"`
+	ProductId *string   `json:"product_id"`
+	Target    string    `json:"target"`
+	Quantity  Decimal   `json:"quantity"`
+	Period    string    `json:"period"`
+	Grade     string    `json:"grade"`
+	SchoolId  *string   `json:"school_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// StudentEntitlement is an entitlement as it applies to one student, with how
+// much of it the student has received in the period.
+type StudentEntitlement struct {
+	EntitlementId string
+	Entitlement   string
+	Quantity      Decimal
+	StudentId     string
+	EnrollmentId  string
+	StudentName   string
+	Grade         string
+	Received      Decimal
+}
+
+func (e *Entitlement) scan(row rowScanner) error {
+	return row.Scan(
+		&e.Id, &e.Name, &e.ItemId, &e.ProductId, &e.Target, &e.Quantity, &e.Period,
+		&e.Grade, &e.SchoolId, &e.CreatedAt,
+	)
+}
+
+func (e *Entitlement) CreateEntitlement(db *sql.DB) error {
+	e.Grade = NormalizeName(e.Grade)
+
+	if err := db.QueryRow(
+		`INSERT INTO entitlements (name, item_id, product_id, quantity, period, grade, school_id)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		e.Name, e.ItemId, e.ProductId, e.Quantity, e.Period, e.Grade, e.SchoolId,
+	).Scan(&e.Id); err != nil {
+		return err
+	}
+
+	return e.scan(db.QueryRow("SELECT "+entitlementColumns+" FROM "+entitlementTables+" WHERE entitlements.id = $1", e.Id))
+}
+
+// GetEntitlements lists the entitlements that apply at the school, or all of
+// them when schoolId is empty.
+func GetEntitlements(db *sql.DB, schoolId string) ([]Entitlement, error) {
+	var args queryArgs
+
+	conditions := []string{}
+
+	if schoolId != "" {
+		conditions = append(conditions,
+			"(entitlements.school_id IS NULL OR entitlements.school_id = "+args.add(schoolId)+")")
+	}
+
+	rows, err := db.Query(
+		"SELECT "+entitlementColumns+" FROM "+entitlementTables+whereClause(conditions)+
+			" ORDER BY entitlements.name, entitlements.id",
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entitlements := []Entitlement{}
+
+	for rows.Next() {
+		var e Entitlement
+
+		if err := e.scan(rows); err != nil {
+			return nil, err
+		}
+
+		entitlements = append(entitlements, e)
+	}
+
+	return entitlements, rows.Err()
+}
+
+// DeleteEntitlement removes the rule. Handouts made under it are kept.
+func (e *Entitlement) DeleteEntitlement(db *sql.DB) error {
+	res, err := db.Exec("DELETE FROM entitlements WHERE id = $1", e.Id)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrEntitlementNotFound
+	}
+
+	return nil
+}
+
+// GetStudentEntitlements pairs every active student of the school with each
+// entitlement that applies to them, counting what they received in year for
+// yearly entitlements and ever for the others.
+func GetStudentEntitlements(db *sql.DB, schoolId string, year int) ([]StudentEntitlement, error) {
+	rows, err := db.Query(
+		`SELECT entitlements.id, entitlements.name, entitlements.quantity,
+		 students.id, students.enrollment_id, students.name, students.grade,
+		 COALESCE((
+			SELECT SUM(handouts.quantity) FROM handouts
+			JOIN warehouse_items ON warehouse_items.id = handouts.item_id
+			WHERE handouts.student_id = students.id
+			AND (handouts.item_id = entitlements.item_id OR warehouse_items.product_id = entitlements.product_id)
+			AND (entitlements.period = 'once' OR EXTRACT(YEAR FROM handouts.handed_at) = $2)
+		 ), 0)
+		 FROM students
+		 JOIN entitlements ON (entitlements.school_id IS NULL OR entitlements.school_id = students.school_id)
+		 AND (entitlements.grade = '' OR entitlements.grade = students.grade)
+		 WHERE students.school_id = $1 AND students.active
+		 ORDER BY entitlements.name, entitlements.id, students.grade, students.name, students.id`,
+		schoolId, year,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	list := []StudentEntitlement{}
+
+	for rows.Next() {
+		var se StudentEntitlement
+
+		if err := rows.Scan(
+			&se.EntitlementId, &se.Entitlement, &se.Quantity, &se.StudentId, &se.EnrollmentId,
+			&se.StudentName, &se.Grade, &se.Received,
+		); err != nil {
+			return nil, err
+		}
+
+		list = append(list, se)
+	}
+
+	return list, rows.Err()
+}
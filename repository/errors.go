@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	checkViolation      = "23514"
+	notNullViolation    = "23502"
+	stringTooLong       = "22001"
+	invalidText         = "22P02"
+)
+
+func pqError(err error) *pq.Error {
+	var pqErr *pq.Error
+
+	if errors.As(err, &pqErr) {
+		return pqErr
+	}
+
+	return nil
+}
+
+func IsUniqueViolation(err error) bool {
+	pqErr := pqError(err)
+
+	return pqErr != nil && pqErr.Code == uniqueViolation
+}
+
+// IsInvalidData reports whether the database refused a value because of a
+// constraint other than uniqueness or because it did not fit its column.
+func IsInvalidData(err error) bool {
+	pqErr := pqError(err)
+
+	if pqErr == nil {
+		return false
+	}
+
+	switch pqErr.Code {
+	case foreignKeyViolation, checkViolation, notNullViolation, stringTooLong, invalidText:
+		return true
+	}
+
+	return false
+}
+
+// ConstraintField returns the column a constraint error is about, when
+// Postgres tells.
+func ConstraintField(err error) string {
+	pqErr := pqError(err)
+
+	if pqErr == nil {
+		return ""
+	}
+
+	if pqErr.Column != "" {
+		return pqErr.Column
+	}
+
+	if strings.HasPrefix(pqErr.Detail, "Key (") {
+		if end := strings.Index(pqErr.Detail, ")"); end > 0 {
+			return pqErr.Detail[len("Key ("):end]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const handoutColumns = `handouts.id, handouts.student_id, handouts.item_id, warehouse_items.name,
+	handouts.quantity, handouts.handed_at, handouts.notes, handouts.user_id, handouts.created_at`
+
+var ErrStudentInactive = errors.New("student is no longer enrolled")
+
+// Handout records items given to a student, usually by their school out of
+// stock already issued to it, so it does not move warehouse stock.
+type Handout struct {
+	Id        string    `json:"id"`
+	StudentId string    `json:"student_id"`
+	ItemId    string    `json:"item_id"`
+	ItemName  string    `json:"item,omitempty"`
+	Quantity  Decimal   `json:"quantity"`
+	HandedAt  time.Time `json:"handed_at"`
+	Notes     string    `json:"notes"`
+	UserId    *string   `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (h *Handout) scan(row rowScanner) error {
+	return row.Scan(
+		&h.Id, &h.StudentId, &h.ItemId, &h.ItemName, &h.Quantity, &h.HandedAt, &h.Notes, &h.UserId, &h.CreatedAt,
+	)
+}
+
+// CreateHandout records the handout, at the current time unless HandedAt is
+// set. Only active students can receive items.
+func (h *Handout) CreateHandout(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	var active bool
+
+	if err = tx.QueryRow("SELECT active FROM students WHERE id = $1 FOR SHARE", h.StudentId).Scan(&active); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if !active {
+		tx.Rollback()
+		return ErrStudentInactive
+	}
+
+	var handedAt *time.Time
+
+	if !h.HandedAt.IsZero() {
+		handedAt = &h.HandedAt
+	}
+
+	if err = tx.QueryRow(
+		`INSERT INTO handouts (student_id, item_id, quantity, handed_at, notes, user_id)
+		 VALUES ($1, $2, $3, COALESCE($4, NOW()), $5, $6) RETURNING id`,
+		h.StudentId, h.ItemId, h.Quantity, handedAt, h.Notes, h.UserId,
+	).Scan(&h.Id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = h.scan(tx.QueryRow(
+		"SELECT "+handoutColumns+" FROM handouts JOIN warehouse_items ON warehouse_items.id = handouts.item_id WHERE handouts.id = $1",
+		h.Id,
+	)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetHandouts lists what the student received, most recent first.
+func GetHandouts(db *sql.DB, studentId string) ([]Handout, error) {
+	rows, err := db.Query(
+		"SELECT "+handoutColumns+` FROM handouts JOIN warehouse_items ON warehouse_items.id = handouts.item_id
+		 WHERE handouts.student_id = $1 ORDER BY handouts.handed_at DESC, handouts.id`,
+		studentId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	handouts := []Handout{}
+
+	for rows.Next() {
+		var h Handout
+
+		if err := h.scan(rows); err != nil {
+			return nil, err
+		}
+
+		handouts = append(handouts, h)
+	}
+
+	return handouts, rows.Err()
+}
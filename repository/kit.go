@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrNotAKit   = errors.New("item is not a kit")
+	ErrNestedKit = errors.New("kits cannot contain other kits")
+)
+
+// Kit is an item assembled from other items, such as a student kit made of a
+// notebook, pencils and an eraser. Assembled kits are held as the kit item's
+// own stock; Buildable is how many more the available components allow.
+type Kit struct {
+	ItemId     string         `json:"item_id"`
+	Name       string         `json:"name"`
+	Quantity   Decimal        `json:"quantity"`
+	Available  Decimal        `json:"available"`
+	Buildable  Decimal        `json:"buildable"`
+	Components []KitComponent `json:"components"`
+}
+
+// KitComponent is how much of an item, in its base unit, goes into one kit.
+type KitComponent struct {
+	ItemId    string  `json:"item_id"`
+	Name      string  `json:"name"`
+	Quantity  Decimal `json:"quantity"`
+	Unit      string  `json:"unit"`
+	Available Decimal `json:"available"`
+
+	lastCost *Decimal
+}
+
+func (k *Kit) load(q querier) error {
+	if err := q.QueryRow(
+		"SELECT name, quantity, quantity - "+reservedQuantity+" FROM warehouse_items WHERE id = $1 AND archived_at IS NULL",
+		k.ItemId,
+	).Scan(&k.Name, &k.Quantity, &k.Available); err != nil {
+		return err
+	}
+
+	rows, err := q.Query(
+		`SELECT warehouse_items.id, warehouse_items.name, kit_components.quantity, warehouse_items.unit,
+		 CASE WHEN warehouse_items.archived_at IS NULL THEN warehouse_items.quantity - `+reservedQuantity+` ELSE 0 END,
+		 (SELECT unit_cost FROM stock_movements WHERE item_id = warehouse_items.id AND unit_cost IS NOT NULL
+		  ORDER BY created_at DESC, id DESC LIMIT 1)
+		 FROM kit_components JOIN warehouse_items ON warehouse_items.id = kit_components.component_id
+		 WHERE kit_components.kit_id = $1 ORDER BY warehouse_items.id`,
+		k.ItemId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	k.Components = []KitComponent{}
+
+	for rows.Next() {
+		var c KitComponent
+
+		if err := rows.Scan(&c.ItemId, &c.Name, &c.Quantity, &c.Unit, &c.Available, &c.lastCost); err != nil {
+			return err
+		}
+
+		k.Components = append(k.Components, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	k.setBuildable()
+
+	return nil
+}
+
+// setBuildable sets Buildable to the number of whole kits the least available
+// component is enough for.
+func (k *Kit) setBuildable() {
+	k.Buildable = 0
+
+	for i, c := range k.Components {
+		n := Decimal(0)
+
+		if c.Available.IsPositive() {
+			n = NewDecimal(int64(c.Available) / int64(c.Quantity))
+		}
+
+		if i == 0 || n < k.Buildable {
+			k.Buildable = n
+		}
+	}
+}
+
+// unitCost is what one kit costs from its components' latest unit costs, or
+// nil when a component has never been received with a cost.
+func (k *Kit) unitCost() *Decimal {
+	var cost Decimal
+
+	for _, c := range k.Components {
+		if c.lastCost == nil {
+			return nil
+		}
+
+		cost = cost.Add(c.Quantity.Mul(*c.lastCost))
+	}
+
+	return &cost
+}
+
+// GetKit loads the kit with its components, failing with ErrNotAKit when the
+// item has none.
+func (k *Kit) GetKit(db *sql.DB) error {
+	if err := k.load(db); err != nil {
+		return err
+	}
+
+	if len(k.Components) == 0 {
+		return ErrNotAKit
+	}
+
+	return nil
+}
+
+func GetKits(db *sql.DB) ([]Kit, error) {
+	rows, err := db.Query(
+		`SELECT DISTINCT kit_components.kit_id, warehouse_items.name FROM kit_components
+		 JOIN warehouse_items ON warehouse_items.id = kit_components.kit_id
+		 WHERE warehouse_items.archived_at IS NULL ORDER BY warehouse_items.name, kit_components.kit_id`,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	kits := []Kit{}
+
+	for rows.Next() {
+		var k Kit
+
+		if err := rows.Scan(&k.ItemId, &k.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		kits = append(kits, k)
+	}
+
+	rows.Close()
+
+	for i := range kits {
+		if err := kits[i].load(db); err != nil {
+			return nil, err
+		}
+	}
+
+	return kits, nil
+}
+
+// SetComponents replaces the kit's components with k.Components. An empty list
+// turns the kit back into a plain item. Kits cannot be components themselves,
+// so a kit is never built from another kit.
+func (k *Kit) SetComponents(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	var nested bool
+
+	if err = tx.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM kit_components WHERE component_id = $1)
+		 FROM warehouse_items WHERE id = $1 AND archived_at IS NULL FOR UPDATE`,
+		k.ItemId,
+	).Scan(&nested); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if nested && len(k.Components) > 0 {
+		tx.Rollback()
+		return ErrNestedKit
+	}
+
+	if _, err = tx.Exec("DELETE FROM kit_components WHERE kit_id = $1", k.ItemId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, c := range k.Components {
+		var kit bool
+
+		if err = tx.QueryRow(
+			"SELECT EXISTS (SELECT 1 FROM kit_components WHERE kit_id = $1)", c.ItemId,
+		).Scan(&kit); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if kit {
+			tx.Rollback()
+			return ErrNestedKit
+		}
+
+		if _, err = tx.Exec(
+			"INSERT INTO kit_components (kit_id, component_id, quantity) VALUES ($1, $2, $3)",
+			k.ItemId, c.ItemId, c.Quantity,
+		); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = k.load(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Assemble builds quantity kits, taking the components out of stock and
+// receiving the kits at what their components last cost. Either every
+// movement is made or none is.
+func (k *Kit) Assemble(db *sql.DB, quantity Decimal, reason string) ([]StockMovement, error) {
+	return k.move(db, quantity, reason, MovementAssembly)
+}
+
+// Disassemble breaks quantity kits up, returning their components to stock.
+func (k *Kit) Disassemble(db *sql.DB, quantity Decimal, reason string) ([]StockMovement, error) {
+	return k.move(db, quantity, reason, MovementDisassembly)
+}
+
+func (k *Kit) move(db *sql.DB, quantity Decimal, reason, kind string) ([]StockMovement, error) {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = k.load(tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if len(k.Components) == 0 {
+		tx.Rollback()
+		return nil, ErrNotAKit
+	}
+
+	sign := NewDecimal(1)
+
+	if kind == MovementDisassembly {
+		sign = sign.Neg()
+	}
+
+	kit := StockMovement{ItemId: k.ItemId, Kind: kind, Quantity: quantity.Mul(sign), Reason: reason}
+
+	if kind == MovementAssembly {
+		kit.UnitCost = k.unitCost()
+	}
+
+	movements := []StockMovement{}
+
+	// Stock is taken out before it is put back, so a disassembly fails on the
+	// kit before touching the components.
+	if kind == MovementDisassembly {
+		movements = append(movements, kit)
+	}
+
+	for _, c := range k.Components {
+		movements = append(movements, StockMovement{
+			ItemId:   c.ItemId,
+			Kind:     kind,
+			Quantity: c.Quantity.Mul(quantity).Mul(sign).Neg(),
+			Reason:   reason,
+		})
+	}
+
+	if kind == MovementAssembly {
+		movements = append(movements, kit)
+	}
+
+	for i := range movements {
+		if err = movements[i].CreateTx(tx); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err = k.load(tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return movements, tx.Commit()
+}
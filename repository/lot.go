@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Lot is a batch of an item received together, optionally with an expiry
+// date. Lots are consumed first-expiring-first-out.
+type Lot struct {
+	Id         string    `json:"id"`
+	ItemId     string    `json:"item_id"`
+	ItemName   string    `json:"item_name,omitempty"`
+	Code       string    `json:"code"`
+	ExpiresAt  *Date     `json:"expires_at"`
+	Quantity   Decimal   `json:"quantity"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+type LotAllocation struct {
+	LotId    string  `json:"lot_id"`
+	Quantity Decimal `json:"quantity"`
+}
+
+func (l *Lot) createTx(tx *sql.Tx) error {
+	return tx.QueryRow(
+		"INSERT INTO stock_lots (item_id, code, expires_at, quantity) VALUES ($1, $2, $3, $4) RETURNING id, received_at",
+		l.ItemId, l.Code, l.ExpiresAt, l.Quantity,
+	).Scan(&l.Id, &l.ReceivedAt)
+}
+
+func GetLotsByItem(db *sql.DB, itemId string) ([]Lot, error) {
+	return queryLots(db,
+		`SELECT stock_lots.id, stock_lots.item_id, warehouse_items.name, stock_lots.code,
+		 stock_lots.expires_at, stock_lots.quantity, stock_lots.received_at
+		 FROM stock_lots JOIN warehouse_items ON warehouse_items.id = stock_lots.item_id
+		 WHERE stock_lots.item_id = $1 AND stock_lots.quantity > 0
+		 ORDER BY stock_lots.expires_at NULLS LAST, stock_lots.received_at`,
+		itemId)
+}
+
+// GetExpiringLots returns lots with stock that expire within the given number
+// of days, including the ones already expired.
+func GetExpiringLots(db *sql.DB, days int) ([]Lot, error) {
+	return queryLots(db,
+		`SELECT stock_lots.id, stock_lots.item_id, warehouse_items.name, stock_lots.code,
+		 stock_lots.expires_at, stock_lots.quantity, stock_lots.received_at
+		 FROM stock_lots JOIN warehouse_items ON warehouse_items.id = stock_lots.item_id
+		 WHERE stock_lots.quantity > 0 AND stock_lots.expires_at <= CURRENT_DATE + $1::int
+		 ORDER BY stock_lots.expires_at, warehouse_items.name`,
+		days)
+}
+
+func queryLots(db *sql.DB, query string, args ...any) ([]Lot, error) {
+	rows, err := db.Query(query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	lots := []Lot{}
+
+	for rows.Next() {
+		var l Lot
+
+		if err := rows.Scan(&l.Id, &l.ItemId, &l.ItemName, &l.Code, &l.ExpiresAt, &l.Quantity, &l.ReceivedAt); err != nil {
+			return nil, err
+		}
+
+		lots = append(lots, l)
+	}
+
+	return lots, nil
+}
@@ -0,0 +1,388 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	TicketOpen       = "open"
+	TicketInProgress = "in_progress"
+	TicketResolved   = "resolved"
+	TicketCancelled  = "cancelled"
+
+	ticketColumns = `maintenance_tickets.id, maintenance_tickets.asset_id, assets.serial_number,
+		maintenance_tickets.school_id, COALESCE(schools.name, ''), maintenance_tickets.description,
+		maintenance_tickets.status, maintenance_tickets.technician_id, maintenance_tickets.opened_by,
+		maintenance_tickets.created_at, maintenance_tickets.updated_at, maintenance_tickets.closed_at`
+
+	ticketTables = `maintenance_tickets JOIN assets ON assets.id = maintenance_tickets.asset_id
+		LEFT JOIN schools ON schools.id = maintenance_tickets.school_id`
+)
+
+var TicketStatuses = []string{TicketOpen, TicketInProgress, TicketResolved, TicketCancelled}
+
+// ticketTransitions lists the statuses a ticket may move to from each status.
+// Resolved and cancelled tickets are closed for good.
+var ticketTransitions = map[string][]string{
+	TicketOpen:       {TicketInProgress, TicketResolved, TicketCancelled},
+	TicketInProgress: {TicketOpen, TicketResolved, TicketCancelled},
+}
+
+var (
+	ErrTicketClosed       = errors.New("ticket is closed")
+	ErrTicketTransition   = errors.New("ticket cannot move to that status")
+	ErrAssetHasOpenTicket = errors.New("asset already has an open maintenance ticket")
+)
+
+// MaintenanceTicket is a repair request for an asset, usually opened by the
+// school holding it. The asset is under repair while the ticket is open or in
+// progress.
+type MaintenanceTicket struct {
+	Id           string       `json:"id"`
+	AssetId      string       `json:"asset_id"`
+	SerialNumber string       `json:"serial_number"`
+	SchoolId     *string      `json:"school_id"`
+	SchoolName   string       `json:"school,omitempty"`
+	Description  string       `json:"description"`
+	Status       string       `json:"status"`
+	TechnicianId *string      `json:"technician_id"`
+	OpenedBy     *string      `json:"opened_by"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	ClosedAt     *time.Time   `json:"closed_at"`
+	Notes        []TicketNote `json:"notes,omitempty"`
+}
+
+// TicketNote is a comment on a ticket. Status is set on the notes recording a
+// status change.
+type TicketNote struct {
+	Id        string    `json:"id"`
+	Status    *string   `json:"status"`
+	Body      string    `json:"body"`
+	UserId    *string   `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TicketFilter struct {
+	AssetId      string
+	SchoolId     string
+	TechnicianId string
+	Status       string
+}
+
+// CanTransition reports whether a ticket may move from one status to another.
+func CanTransition(from, to string) bool {
+	for _, s := range ticketTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *MaintenanceTicket) scan(row rowScanner) error {
+	return row.Scan(
+		&t.Id, &t.AssetId, &t.SerialNumber, &t.SchoolId, &t.SchoolName, &t.Description, &t.Status,
+		&t.TechnicianId, &t.OpenedBy, &t.CreatedAt, &t.UpdatedAt, &t.ClosedAt,
+	)
+}
+
+func (t *MaintenanceTicket) load(q querier, lock bool) error {
+	query := "SELECT " + ticketColumns + " FROM " + ticketTables + " WHERE maintenance_tickets.id = $1"
+
+	if lock {
+		query += " FOR UPDATE OF maintenance_tickets"
+	}
+
+	if err := t.scan(q.QueryRow(query, t.Id)); err != nil {
+		return err
+	}
+
+	rows, err := q.Query(
+		`SELECT id, status, body, user_id, created_at FROM maintenance_ticket_notes
+		 WHERE ticket_id = $1 ORDER BY created_at, id`,
+		t.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	t.Notes = []TicketNote{}
+
+	for rows.Next() {
+		var n TicketNote
+
+		if err := rows.Scan(&n.Id, &n.Status, &n.Body, &n.UserId, &n.CreatedAt); err != nil {
+			return err
+		}
+
+		t.Notes = append(t.Notes, n)
+	}
+
+	return rows.Err()
+}
+
+func (t *MaintenanceTicket) GetTicketById(db *sql.DB) error {
+	return t.load(db, false)
+}
+
+func GetTickets(db *sql.DB, f TicketFilter) ([]MaintenanceTicket, error) {
+	var args queryArgs
+
+	conditions := []string{}
+
+	if f.AssetId != "" {
+		conditions = append(conditions, "maintenance_tickets.asset_id = "+args.add(f.AssetId))
+	}
+
+	if f.SchoolId != "" {
+		conditions = append(conditions, "maintenance_tickets.school_id = "+args.add(f.SchoolId))
+	}
+
+	if f.TechnicianId != "" {
+		conditions = append(conditions, "maintenance_tickets.technician_id = "+args.add(f.TechnicianId))
+	}
+
+	if f.Status != "" {
+		conditions = append(conditions, "maintenance_tickets.status = "+args.add(f.Status))
+	}
+
+	rows, err := db.Query(
+		"SELECT "+ticketColumns+" FROM "+ticketTables+whereClause(conditions)+
+			" ORDER BY maintenance_tickets.created_at DESC, maintenance_tickets.id",
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tickets := []MaintenanceTicket{}
+
+	for rows.Next() {
+		var t MaintenanceTicket
+
+		if err := t.scan(rows); err != nil {
+			return nil, err
+		}
+
+		tickets = append(tickets, t)
+	}
+
+	return tickets, rows.Err()
+}
+
+// CreateTicket opens a ticket for t.AssetId, putting the asset under repair.
+// The ticket is filed under the school holding the asset when SchoolId is not
+// given.
+func (t *MaintenanceTicket) CreateTicket(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	a := Asset{Id: t.AssetId}
+
+	if err = a.get(tx, true); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	switch {
+	case a.RetiredAt != nil:
+		err = ErrAssetRetired
+	case a.UnderRepair:
+		err = ErrAssetHasOpenTicket
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if t.SchoolId == nil {
+		t.SchoolId = a.SchoolId
+	}
+
+	if err = tx.QueryRow(
+		`INSERT INTO maintenance_tickets (asset_id, school_id, description, technician_id, opened_by)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		t.AssetId, t.SchoolId, t.Description, t.TechnicianId, t.OpenedBy,
+	).Scan(&t.Id); err != nil {
+		tx.Rollback()
+
+		if IsUniqueViolation(err) {
+			return ErrAssetHasOpenTicket
+		}
+
+		return err
+	}
+
+	if err = a.record(tx, AssetRepairOpened, t.Description, t.OpenedBy); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = t.load(tx, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Transition moves the ticket to status, leaving note on it. Closing the
+// ticket takes the asset out of repair; condition, when given, is what the
+// asset is in afterwards.
+func (t *MaintenanceTicket) Transition(db *sql.DB, status, condition, note string, userId *string) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = t.load(tx, true); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if !CanTransition(t.Status, status) {
+		tx.Rollback()
+
+		if t.ClosedAt != nil {
+			return ErrTicketClosed
+		}
+
+		return ErrTicketTransition
+	}
+
+	closed := status == TicketResolved || status == TicketCancelled
+
+	if _, err = tx.Exec(
+		`UPDATE maintenance_tickets SET status = $1, updated_at = NOW(),
+		 closed_at = CASE WHEN $2 THEN NOW() END WHERE id = $3`,
+		status, closed, t.Id,
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = t.addNote(tx, &status, note, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if closed {
+		a := Asset{Id: t.AssetId}
+
+		if err = a.get(tx, true); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if condition != "" {
+			if _, err = tx.Exec("UPDATE assets SET condition = $1 WHERE id = $2", condition, a.Id); err != nil {
+				tx.Rollback()
+				return err
+			}
+
+			a.Condition = condition
+		}
+
+		if err = a.record(tx, AssetRepairClosed, note, userId); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = t.load(tx, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Assign hands the ticket to a technician, or unassigns it when technicianId
+// is nil.
+func (t *MaintenanceTicket) Assign(db *sql.DB, technicianId *string, userId *string) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = t.load(tx, true); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if t.ClosedAt != nil {
+		tx.Rollback()
+		return ErrTicketClosed
+	}
+
+	if _, err = tx.Exec(
+		"UPDATE maintenance_tickets SET technician_id = $1, updated_at = NOW() WHERE id = $2", technicianId, t.Id,
+	); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = t.load(tx, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddNote comments on the ticket. Closed tickets take notes too, e.g. about a
+// repair's warranty.
+func (t *MaintenanceTicket) AddNote(db *sql.DB, body string, userId *string) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = t.load(tx, true); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = t.addNote(tx, nil, body, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.Exec("UPDATE maintenance_tickets SET updated_at = NOW() WHERE id = $1", t.Id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = t.load(tx, false); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (t *MaintenanceTicket) addNote(tx *sql.Tx, status *string, body string, userId *string) error {
+	_, err := tx.Exec(
+		"INSERT INTO maintenance_ticket_notes (ticket_id, status, body, user_id) VALUES ($1, $2, $3, $4)",
+		t.Id, status, body, userId,
+	)
+
+	return err
+}
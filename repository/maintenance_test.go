@@ -0,0 +1,31 @@
+package repository
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	allowed := [][2]string{
+		{TicketOpen, TicketInProgress},
+		{TicketOpen, TicketCancelled},
+		{TicketInProgress, TicketOpen},
+		{TicketInProgress, TicketResolved},
+	}
+
+	for _, c := range allowed {
+		if !CanTransition(c[0], c[1]) {
+			t.Errorf("Expected %s to move to %s", c[0], c[1])
+		}
+	}
+
+	denied := [][2]string{
+		{TicketOpen, TicketOpen},
+		{TicketResolved, TicketOpen},
+		{TicketCancelled, TicketInProgress},
+		{TicketOpen, "fixed"},
+	}
+
+	for _, c := range denied {
+		if CanTransition(c[0], c[1]) {
+			t.Errorf("Expected %s not to move to %s", c[0], c[1])
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrUnitMismatch     = errors.New("items have different units")
+	ErrPackSizeMismatch = errors.New("items have different pack sizes")
+)
+
+// ItemName is an item's name together with the key it is compared by, its
+// stored name_key: lowercased, without accents and with its whitespace
+// collapsed.
+type ItemName struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Key  string `json:"-"`
+}
+
+// ItemMerge records an item that was merged into another.
+type ItemMerge struct {
+	Id             string    `json:"id"`
+	ItemId         string    `json:"item_id"`
+	MergedItemId   string    `json:"merged_item_id"`
+	MergedName     string    `json:"merged_name"`
+	MergedQuantity Decimal   `json:"merged_quantity"`
+	MergedBy       *string   `json:"merged_by"`
+	MergedAt       time.Time `json:"merged_at"`
+}
+
+// GetItemNames lists the names of every active item. Keys of items that
+// already clashed when name_key was added carry their id as a suffix, which
+// is left out so they are still found as duplicates.
+func GetItemNames(db *sql.DB) ([]ItemName, error) {
+	rows, err := db.Query(
+		`SELECT id, name, regexp_replace(name_key, ':[0-9a-f-]{36}$', '') FROM warehouse_items
+		 WHERE archived_at IS NULL ORDER BY name, id`,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	names := []ItemName{}
+
+	for rows.Next() {
+		var n ItemName
+
+		if err := rows.Scan(&n.Id, &n.Name, &n.Key); err != nil {
+			return nil, err
+		}
+
+		names = append(names, n)
+	}
+
+	return names, rows.Err()
+}
+
+// mergeStatements move everything that refers to the duplicate item ($2) to
+// the surviving item ($1). Rows the survivor already has, like a line for
+// both items on the same purchase order, are added together.
+var mergeStatements = []string{
+	"UPDATE stock_movements SET item_id = $1 WHERE item_id = $2",
+	"UPDATE stock_lots SET item_id = $1 WHERE item_id = $2",
+	"UPDATE item_barcodes SET item_id = $1 WHERE item_id = $2",
+	"UPDATE reservations SET item_id = $1 WHERE item_id = $2",
+	"UPDATE handouts SET item_id = $1 WHERE item_id = $2",
+	"UPDATE entitlements SET item_id = $1 WHERE item_id = $2",
+	"UPDATE allotments SET item_id = $1 WHERE item_id = $2",
+
+	`UPDATE shipment_lines SET quantity = shipment_lines.quantity + duplicate.quantity
+	 FROM shipment_lines AS duplicate
+	 WHERE shipment_lines.item_id = $1 AND duplicate.item_id = $2
+	 AND duplicate.shipment_id = shipment_lines.shipment_id`,
+	`DELETE FROM shipment_lines WHERE item_id = $2
+	 AND shipment_id IN (SELECT shipment_id FROM shipment_lines WHERE item_id = $1)`,
+	"UPDATE shipment_lines SET item_id = $1 WHERE item_id = $2",
+	"DELETE FROM min_max_recommendations WHERE item_id = $2 AND status = 'pending'",
+	"UPDATE min_max_recommendations SET item_id = $1 WHERE item_id = $2",
+
+	`UPDATE purchase_order_lines SET quantity = purchase_order_lines.quantity + duplicate.quantity,
+	 received = purchase_order_lines.received + duplicate.received
+	 FROM purchase_order_lines AS duplicate
+	 WHERE purchase_order_lines.item_id = $1 AND duplicate.item_id = $2
+	 AND duplicate.purchase_order_id = purchase_order_lines.purchase_order_id`,
+	`DELETE FROM purchase_order_lines WHERE item_id = $2
+	 AND purchase_order_id IN (SELECT purchase_order_id FROM purchase_order_lines WHERE item_id = $1)`,
+	"UPDATE purchase_order_lines SET item_id = $1 WHERE item_id = $2",
+
+	`INSERT INTO stocktake_lines (stocktake_id, item_id, expected)
+	 SELECT stocktake_id, $1, expected FROM stocktake_lines WHERE item_id = $2
+	 ON CONFLICT (stocktake_id, item_id) DO UPDATE SET expected = stocktake_lines.expected + EXCLUDED.expected`,
+	`INSERT INTO stocktake_counts (stocktake_id, item_id, counted_by, quantity, counted_at)
+	 SELECT stocktake_id, $1, counted_by, quantity, counted_at FROM stocktake_counts WHERE item_id = $2
+	 ON CONFLICT (stocktake_id, item_id, counted_by) DO UPDATE SET quantity = stocktake_counts.quantity + EXCLUDED.quantity`,
+	"DELETE FROM stocktake_lines WHERE item_id = $2",
+
+	`INSERT INTO scan_session_lines (session_id, item_id, quantity)
+	 SELECT session_id, $1, quantity FROM scan_session_lines WHERE item_id = $2
+	 ON CONFLICT (session_id, item_id) DO UPDATE SET quantity = scan_session_lines.quantity + EXCLUDED.quantity`,
+	"DELETE FROM scan_session_lines WHERE item_id = $2",
+
+	`INSERT INTO kit_components (kit_id, component_id, quantity)
+	 SELECT $1, component_id, quantity FROM kit_components WHERE kit_id = $2 AND component_id <> $1
+	 ON CONFLICT (kit_id, component_id) DO UPDATE SET quantity = kit_components.quantity + EXCLUDED.quantity`,
+	`INSERT INTO kit_components (kit_id, component_id, quantity)
+	 SELECT kit_id, $1, quantity FROM kit_components WHERE component_id = $2 AND kit_id <> $1
+	 ON CONFLICT (kit_id, component_id) DO UPDATE SET quantity = kit_components.quantity + EXCLUDED.quantity`,
+	"DELETE FROM kit_components WHERE kit_id = $2 OR component_id = $2",
+
+	// Snapshots no longer add up once stock moves between items. Point in
+	// time queries fall back to the movements until the next ones are taken.
+	"DELETE FROM stock_snapshots WHERE item_id IN ($1, $2)",
+}
+
+// Merge folds the duplicate items into wi: their stock is added to wi's and
+// their movements, lots, barcodes, reservations, orders, counts and kits are
+// repointed to it before they are deleted. Items in an open stocktake cannot
+// be merged, and neither can items kept in different units or pack sizes.
+func (wi *WarehouseItem) Merge(db *sql.DB, duplicateIds []string, mergedBy string) ([]ItemMerge, error) {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := append([]string{wi.Id}, duplicateIds...)
+
+	rows, err := tx.Query(
+		"SELECT id, name, quantity, unit, pack_size, archived_at IS NOT NULL, "+lockedByStocktake+
+			" FROM warehouse_items WHERE id = ANY($1) ORDER BY id FOR UPDATE",
+		pq.Array(ids),
+	)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	type lockedItem struct {
+		name     string
+		quantity Decimal
+		unit     string
+		packSize Decimal
+		archived bool
+		locked   bool
+	}
+
+	items := map[string]lockedItem{}
+
+	for rows.Next() {
+		var id string
+		var it lockedItem
+
+		if err = rows.Scan(&id, &it.name, &it.quantity, &it.unit, &it.packSize, &it.archived, &it.locked); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, err
+		}
+
+		items[id] = it
+	}
+
+	rows.Close()
+
+	survivor, ok := items[wi.Id]
+
+	if !ok || survivor.archived || len(items) != len(ids) {
+		tx.Rollback()
+		return nil, sql.ErrNoRows
+	}
+
+	for _, it := range items {
+		if it.locked {
+			tx.Rollback()
+			return nil, ErrItemLocked
+		}
+
+		if it.unit != survivor.unit {
+			tx.Rollback()
+			return nil, ErrUnitMismatch
+		}
+
+		if it.packSize != survivor.packSize {
+			tx.Rollback()
+			return nil, ErrPackSizeMismatch
+		}
+	}
+
+	var by *string
+
+	if mergedBy != "" {
+		by = &mergedBy
+	}
+
+	merges := []ItemMerge{}
+
+	for _, id := range duplicateIds {
+		for _, stmt := range mergeStatements {
+			if _, err = tx.Exec(stmt, wi.Id, id); err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
+		dup := items[id]
+		merge := ItemMerge{ItemId: wi.Id, MergedItemId: id, MergedName: dup.name, MergedQuantity: dup.quantity, MergedBy: by}
+
+		if err = tx.QueryRow(
+			`INSERT INTO item_merges (item_id, merged_item_id, merged_name, merged_quantity, merged_by)
+			 VALUES ($1, $2, $3, $4, $5) RETURNING id, merged_at`,
+			merge.ItemId, merge.MergedItemId, merge.MergedName, merge.MergedQuantity, merge.MergedBy,
+		).Scan(&merge.Id, &merge.MergedAt); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if _, err = tx.Exec(
+			`UPDATE warehouse_items SET quantity = quantity + $1, version = version + 1,
+			 created_at = LEAST(created_at, (SELECT created_at FROM warehouse_items WHERE id = $3))
+			 WHERE id = $2`,
+			dup.quantity, wi.Id, id,
+		); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if _, err = tx.Exec("DELETE FROM warehouse_items WHERE id = $1", id); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		merges = append(merges, merge)
+	}
+
+	var nested bool
+
+	if err = tx.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM kit_components WHERE kit_id = $1)
+		 AND EXISTS (SELECT 1 FROM kit_components WHERE component_id = $1)`,
+		wi.Id,
+	).Scan(&nested); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if nested {
+		tx.Rollback()
+		return nil, ErrNestedKit
+	}
+
+	if err = wi.getActive(tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return merges, tx.Commit()
+}
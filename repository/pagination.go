@@ -0,0 +1,111 @@
+package repository
+
+import "errors"
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the row a page starts after (or before, when paging
+// backwards). Sort records the ordering it was issued for, since a cursor is
+// meaningless under a different one.
+type Cursor struct {
+	Sort   string `json:"s"`
+	Key    string `json:"k"`
+	Id     string `json:"i"`
+	Before bool   `json:"b,omitempty"`
+}
+
+type PageRequest struct {
+	Count     int
+	Cursor    *Cursor
+	WithTotal bool
+}
+
+// Page is a slice of a list ordered by a key and then by id. Total is -1
+// unless it was requested.
+type Page[T any] struct {
+	Items []T
+	Next  *Cursor
+	Prev  *Cursor
+	Total int
+}
+
+func (p PageRequest) backward() bool {
+	return p.Cursor != nil && p.Cursor.Before
+}
+
+func (p PageRequest) checkSort(sort string) error {
+	if p.Cursor != nil && p.Cursor.Sort != sort {
+		return ErrInvalidCursor
+	}
+
+	return nil
+}
+
+// keyset returns the condition selecting the rows after the cursor, if any,
+// and the ORDER BY clause for rows ordered by column and then idColumn. When
+// paging backwards the order is reversed and newPage flips the rows back.
+func (p PageRequest) keyset(column, idColumn string, desc bool, arg func(any) string) (string, string) {
+	if p.backward() {
+		desc = !desc
+	}
+
+	direction, op := " ASC", ">"
+
+	if desc {
+		direction, op = " DESC", "<"
+	}
+
+	condition := ""
+
+	if p.Cursor != nil {
+		condition = "(" + column + ", " + idColumn + ") " + op + " (" + arg(p.Cursor.Key) + ", " + arg(p.Cursor.Id) + ")"
+	}
+
+	return condition, " ORDER BY " + column + direction + ", " + idColumn + direction
+}
+
+// newPage builds a page from up to Count+1 rows fetched in keyset order, the
+// extra row only telling whether there is more to read.
+func newPage[T any](items []T, p PageRequest, sort string, key func(T) (string, string)) Page[T] {
+	page := Page[T]{Total: -1}
+	more := len(items) > p.Count
+
+	if more {
+		items = items[:p.Count]
+	}
+
+	if p.backward() {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page.Items = items
+
+	if len(items) == 0 {
+		return page
+	}
+
+	hasNext, hasPrev := more, p.Cursor != nil
+
+	if p.backward() {
+		hasNext, hasPrev = true, more
+	}
+
+	if hasNext {
+		k, id := key(items[len(items)-1])
+		page.Next = &Cursor{Sort: sort, Key: k, Id: id}
+	}
+
+	if hasPrev {
+		k, id := key(items[0])
+		page.Prev = &Cursor{Sort: sort, Key: k, Id: id, Before: true}
+	}
+
+	return page
+}
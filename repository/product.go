@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	productColumns = `products.id, products.name, products.attributes, products.category_id,
+		COALESCE(categories.name, ''), COUNT(warehouse_items.id),
+		COALESCE(SUM(warehouse_items.quantity), 0),
+		COALESCE(SUM(warehouse_items.quantity - ` + reservedQuantity + `), 0),
+		COUNT(warehouse_items.id) FILTER (WHERE warehouse_items.quantity < warehouse_items.min)`
+
+	productTables = `products
+		LEFT JOIN categories ON categories.id = products.category_id
+		LEFT JOIN warehouse_items ON warehouse_items.product_id = products.id AND warehouse_items.archived_at IS NULL`
+
+	productGroupBy = " GROUP BY products.id, categories.name"
+)
+
+var (
+	ErrItemHasProduct = errors.New("item is already a variant of another product")
+	ErrVariantTaken   = errors.New("product already has a variant with these attributes")
+	ErrNotAVariant    = errors.New("item is not a variant of this product")
+)
+
+// Variant holds the attribute values telling a product's variants apart, e.g.
+// {"size": "m"}.
+type Variant map[string]string
+
+func (v *Variant) Scan(src any) error {
+	if src == nil {
+		*v = nil
+		return nil
+	}
+
+	b, ok := src.([]byte)
+
+	if !ok {
+		return fmt.Errorf("cannot scan %T into Variant", src)
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func (v Variant) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(v)
+
+	return string(b), err
+}
+
+// normalized returns the variant with its attributes and values normalized like
+// item names, so "M" and "m" are the same size.
+func (v Variant) normalized() Variant {
+	n := Variant{}
+
+	for a, value := range v {
+		n[NormalizeName(a)] = NormalizeName(value)
+	}
+
+	return n
+}
+
+// Product groups items that are the same good in different sizes or colors,
+// such as a school uniform shirt. Each variant is a warehouse item with its
+// own stock; the quantities here are rolled up from the active variants.
+type Product struct {
+	Id           string          `json:"id"`
+	Name         string          `json:"name"`
+	Attributes   []string        `json:"attributes"`
+	CategoryId   *string         `json:"category_id"`
+	CategoryName string          `json:"category,omitempty"`
+	VariantCount int             `json:"variant_count"`
+	Quantity     Decimal         `json:"quantity"`
+	Available    Decimal         `json:"available"`
+	BelowMin     int             `json:"below_min"`
+	Variants     []WarehouseItem `json:"variants,omitempty"`
+}
+
+func (p *Product) scan(row rowScanner) error {
+	return row.Scan(
+		&p.Id, &p.Name, pq.Array(&p.Attributes), &p.CategoryId, &p.CategoryName,
+		&p.VariantCount, &p.Quantity, &p.Available, &p.BelowMin,
+	)
+}
+
+// VariantName is the name given to a new variant of the product: the
+// product's name followed by the attribute values in the product's order, like
+// "camiseta m azul".
+func (p *Product) VariantName(v Variant) string {
+	parts := []string{p.Name}
+
+	for _, a := range p.Attributes {
+		parts = append(parts, v[a])
+	}
+
+	return NormalizeName(strings.Join(parts, " "))
+}
+
+func (p *Product) CreateProduct(db *sql.DB) error {
+	p.Name = NormalizeName(p.Name)
+
+	for i, a := range p.Attributes {
+		p.Attributes[i] = NormalizeName(a)
+	}
+
+	return db.QueryRow(
+		"INSERT INTO products (name, name_key, attributes, category_id) VALUES ($1, unaccent($1), $2, $3) RETURNING id",
+		p.Name, pq.Array(p.Attributes), p.CategoryId,
+	).Scan(&p.Id)
+}
+
+// GetProductById loads the product with its active variants.
+func (p *Product) GetProductById(db *sql.DB) error {
+	return p.load(db)
+}
+
+func (p *Product) load(q querier) error {
+	if err := p.scan(q.QueryRow(
+		"SELECT "+productColumns+" FROM "+productTables+" WHERE products.id = $1"+productGroupBy, p.Id,
+	)); err != nil {
+		return err
+	}
+
+	rows, err := q.Query(
+		"SELECT "+warehouseItemColumns+" FROM "+warehouseItemTables+
+			" WHERE warehouse_items.product_id = $1 AND warehouse_items.archived_at IS NULL ORDER BY warehouse_items.name",
+		p.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	p.Variants = []WarehouseItem{}
+
+	for rows.Next() {
+		var wi WarehouseItem
+
+		if err := wi.scan(rows); err != nil {
+			return err
+		}
+
+		p.Variants = append(p.Variants, wi)
+	}
+
+	return rows.Err()
+}
+
+// GetProducts lists the products with their stock rolled up, without the
+// variants themselves.
+func GetProducts(db *sql.DB, name string) ([]Product, error) {
+	var args queryArgs
+
+	conditions := []string{}
+
+	if name != "" {
+		conditions = append(conditions,
+			"products.name_key LIKE '%' || unaccent(lower("+args.add(escapeLike(name))+")) || '%'")
+	}
+
+	rows, err := db.Query(
+		"SELECT "+productColumns+" FROM "+productTables+whereClause(conditions)+productGroupBy+" ORDER BY products.name",
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	products := []Product{}
+
+	for rows.Next() {
+		var p Product
+
+		if err := p.scan(rows); err != nil {
+			return nil, err
+		}
+
+		products = append(products, p)
+	}
+
+	return products, rows.Err()
+}
+
+// CreateVariant creates wi as a new variant of the product, naming it after
+// the product when it has no name of its own.
+func (p *Product) CreateVariant(db *sql.DB, wi *WarehouseItem, v Variant) error {
+	v = v.normalized()
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if wi.Name == "" {
+		wi.Name = p.VariantName(v)
+	}
+
+	if err = wi.CreateWarehouseItemTx(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = p.link(tx, wi.Id, v); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = p.load(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddVariant turns an existing item, such as a "camiseta p" created before
+// products existed, into a variant of the product.
+func (p *Product) AddVariant(db *sql.DB, itemId string, v Variant) error {
+	v = v.normalized()
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	var productId *string
+
+	if err = tx.QueryRow(
+		"SELECT product_id FROM warehouse_items WHERE id = $1 AND archived_at IS NULL FOR UPDATE", itemId,
+	).Scan(&productId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if productId != nil && *productId != p.Id {
+		tx.Rollback()
+		return ErrItemHasProduct
+	}
+
+	if err = p.link(tx, itemId, v); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = p.load(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (p *Product) link(tx *sql.Tx, itemId string, v Variant) error {
+	_, err := tx.Exec(
+		"UPDATE warehouse_items SET product_id = $1, variant = $2, version = version + 1 WHERE id = $3",
+		p.Id, v, itemId,
+	)
+
+	if IsUniqueViolation(err) {
+		return ErrVariantTaken
+	}
+
+	return err
+}
+
+// RemoveVariant makes the item a standalone item again. Its stock is left as
+// it is.
+func (p *Product) RemoveVariant(db *sql.DB, itemId string) error {
+	res, err := db.Exec(
+		"UPDATE warehouse_items SET product_id = NULL, variant = NULL, version = version + 1 WHERE id = $1 AND product_id = $2",
+		itemId, p.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotAVariant
+	}
+
+	return p.load(db)
+}
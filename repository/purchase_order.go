@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	PurchaseOrderOpen              = "open"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+	PurchaseOrderCancelled         = "cancelled"
+
+	purchaseOrderColumns = `purchase_orders.id, purchase_orders.supplier_id, suppliers.name,
+		purchase_orders.status, purchase_orders.expected_at, purchase_orders.notes,
+		purchase_orders.created_at, purchase_orders.closed_at`
+
+	purchaseOrderTables = `purchase_orders JOIN suppliers ON suppliers.id = purchase_orders.supplier_id`
+)
+
+var (
+	ErrPurchaseOrderClosed = errors.New("purchase order is not open")
+	ErrLineNotFound        = errors.New("purchase order line not found")
+	ErrOverReceipt         = errors.New("quantity exceeds what is outstanding")
+)
+
+type PurchaseOrder struct {
+	Id           string              `json:"id"`
+	SupplierId   string              `json:"supplier_id"`
+	SupplierName string              `json:"supplier"`
+	Status       string              `json:"status"`
+	ExpectedAt   *Date               `json:"expected_at"`
+	Notes        string              `json:"notes"`
+	Lines        []PurchaseOrderLine `json:"lines"`
+	CreatedAt    time.Time           `json:"created_at"`
+	ClosedAt     *time.Time          `json:"closed_at"`
+}
+
+// PurchaseOrderLine orders Quantity of an item in its base unit. Received
+// grows with every goods receipt until nothing is outstanding.
+type PurchaseOrderLine struct {
+	Id          string  `json:"id"`
+	ItemId      string  `json:"item_id"`
+	ItemName    string  `json:"item_name"`
+	Quantity    Decimal `json:"quantity"`
+	Received    Decimal `json:"received"`
+	Outstanding Decimal `json:"outstanding"`
+	ExpectedAt  *Date   `json:"expected_at"`
+}
+
+// GoodsReceiptLine is the quantity received against a purchase order line,
+// optionally as a lot and with the cost of one base unit.
+type GoodsReceiptLine struct {
+	LineId   string
+	Quantity Decimal
+	UnitCost *Decimal
+	Lot      *Lot
+}
+
+func (po *PurchaseOrder) scan(row rowScanner) error {
+	return row.Scan(
+		&po.Id, &po.SupplierId, &po.SupplierName, &po.Status, &po.ExpectedAt,
+		&po.Notes, &po.CreatedAt, &po.ClosedAt,
+	)
+}
+
+func (po *PurchaseOrder) CreatePurchaseOrder(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = tx.QueryRow(
+		`INSERT INTO purchase_orders (supplier_id, expected_at, notes) VALUES ($1, $2, $3)
+		 RETURNING id, status, created_at`,
+		po.SupplierId, po.ExpectedAt, po.Notes,
+	).Scan(&po.Id, &po.Status, &po.CreatedAt); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, l := range po.Lines {
+		if _, err = tx.Exec(
+			`INSERT INTO purchase_order_lines (purchase_order_id, item_id, quantity, expected_at)
+			 VALUES ($1, $2, $3, $4)`,
+			po.Id, l.ItemId, l.Quantity, l.ExpectedAt,
+		); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = po.get(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (po *PurchaseOrder) GetPurchaseOrderById(db *sql.DB) error {
+	return po.get(db)
+}
+
+// GetPurchaseOrders lists purchase orders without their lines, optionally
+// only those of a supplier or in a status.
+func GetPurchaseOrders(db *sql.DB, supplierId, status string) ([]PurchaseOrder, error) {
+	var args queryArgs
+
+	conditions := []string{}
+
+	if supplierId != "" {
+		conditions = append(conditions, "purchase_orders.supplier_id = "+args.add(supplierId))
+	}
+
+	if status != "" {
+		conditions = append(conditions, "purchase_orders.status = "+args.add(status))
+	}
+
+	rows, err := db.Query(
+		"SELECT "+purchaseOrderColumns+" FROM "+purchaseOrderTables+whereClause(conditions)+
+			" ORDER BY purchase_orders.created_at DESC, purchase_orders.id",
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	orders := []PurchaseOrder{}
+
+	for rows.Next() {
+		var po PurchaseOrder
+
+		if err := po.scan(rows); err != nil {
+			return nil, err
+		}
+
+		orders = append(orders, po)
+	}
+
+	return orders, nil
+}
+
+// Receive records a goods receipt against the order in a single transaction.
+// Every line received becomes a receipt movement and the order's status
+// follows what is still outstanding.
+func (po *PurchaseOrder) Receive(db *sql.DB, lines []GoodsReceiptLine) ([]StockMovement, error) {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = po.lockOpen(tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	movements := []StockMovement{}
+
+	for _, gl := range lines {
+		var itemId string
+		var withinOrder bool
+
+		err = tx.QueryRow(
+			`UPDATE purchase_order_lines SET received = received + $1
+			 WHERE id = $2 AND purchase_order_id = $3 RETURNING item_id, received <= quantity`,
+			gl.Quantity, gl.LineId, po.Id,
+		).Scan(&itemId, &withinOrder)
+
+		if err == sql.ErrNoRows {
+			tx.Rollback()
+			return nil, ErrLineNotFound
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		if !withinOrder {
+			tx.Rollback()
+			return nil, ErrOverReceipt
+		}
+
+		m := StockMovement{
+			ItemId:   itemId,
+			Kind:     MovementReceipt,
+			Quantity: gl.Quantity,
+			Reason:   "purchase order " + po.Id,
+			UnitCost: gl.UnitCost,
+			Lot:      gl.Lot,
+		}
+
+		if err = m.CreateTx(tx); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		movements = append(movements, m)
+	}
+
+	var outstanding bool
+
+	if err = tx.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM purchase_order_lines WHERE purchase_order_id = $1 AND received < quantity)", po.Id,
+	).Scan(&outstanding); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if outstanding {
+		_, err = tx.Exec("UPDATE purchase_orders SET status = $1 WHERE id = $2", PurchaseOrderPartiallyReceived, po.Id)
+	} else {
+		_, err = tx.Exec("UPDATE purchase_orders SET status = $1, closed_at = NOW() WHERE id = $2", PurchaseOrderReceived, po.Id)
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err = po.get(tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return movements, tx.Commit()
+}
+
+// Cancel closes the order. Whatever was already received stays in stock and
+// nothing more is expected.
+func (po *PurchaseOrder) Cancel(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = po.lockOpen(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = tx.QueryRow(
+		"UPDATE purchase_orders SET status = $1, closed_at = NOW() WHERE id = $2 RETURNING status, closed_at",
+		PurchaseOrderCancelled, po.Id,
+	).Scan(&po.Status, &po.ClosedAt); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (po *PurchaseOrder) get(q querier) error {
+	if err := po.scan(q.QueryRow(
+		"SELECT "+purchaseOrderColumns+" FROM "+purchaseOrderTables+" WHERE purchase_orders.id = $1", po.Id,
+	)); err != nil {
+		return err
+	}
+
+	rows, err := q.Query(
+		`SELECT purchase_order_lines.id, purchase_order_lines.item_id, warehouse_items.name,
+		 purchase_order_lines.quantity, purchase_order_lines.received, purchase_order_lines.expected_at
+		 FROM purchase_order_lines JOIN warehouse_items ON warehouse_items.id = purchase_order_lines.item_id
+		 WHERE purchase_order_lines.purchase_order_id = $1 ORDER BY warehouse_items.name, purchase_order_lines.id`,
+		po.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	po.Lines = []PurchaseOrderLine{}
+
+	for rows.Next() {
+		var l PurchaseOrderLine
+
+		if err := rows.Scan(&l.Id, &l.ItemId, &l.ItemName, &l.Quantity, &l.Received, &l.ExpectedAt); err != nil {
+			return err
+		}
+
+		l.Outstanding = l.Quantity.Sub(l.Received)
+
+		po.Lines = append(po.Lines, l)
+	}
+
+	return rows.Err()
+}
+
+func (po *PurchaseOrder) lockOpen(tx *sql.Tx) error {
+	if err := tx.QueryRow(
+		"SELECT status FROM purchase_orders WHERE id = $1 FOR UPDATE", po.Id,
+	).Scan(&po.Status); err != nil {
+		return err
+	}
+
+	if po.Status != PurchaseOrderOpen && po.Status != PurchaseOrderPartiallyReceived {
+		return ErrPurchaseOrderClosed
+	}
+
+	return nil
+}
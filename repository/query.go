@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// queryArgs collects positional arguments while a query is being built.
+type queryArgs []any
+
+func (a *queryArgs) add(v any) string {
+	*a = append(*a, v)
+
+	return "$" + strconv.Itoa(len(*a))
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx so reads can run inside
+// or outside a transaction.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	RecommendationPending   = "pending"
+	RecommendationAccepted  = "accepted"
+	RecommendationDismissed = "dismissed"
+
+	recommendationColumns = `min_max_recommendations.id, min_max_recommendations.item_id, warehouse_items.name,
+		min_max_recommendations.current_min, min_max_recommendations.current_max,
+		min_max_recommendations.min, min_max_recommendations.max, min_max_recommendations.rationale,
+		min_max_recommendations.status, min_max_recommendations.created_at, min_max_recommendations.decided_at`
+
+	recommendationTables = `min_max_recommendations
+		JOIN warehouse_items ON warehouse_items.id = min_max_recommendations.item_id`
+)
+
+// Recommendation is a forecast's suggested min and max for an item. The
+// rationale is kept as the JSON the forecast produced.
+type Recommendation struct {
+	Id         string          `json:"id"`
+	ItemId     string          `json:"item_id"`
+	ItemName   string          `json:"item_name"`
+	CurrentMin Decimal         `json:"current_min"`
+	CurrentMax Decimal         `json:"current_max"`
+	Min        Decimal         `json:"min"`
+	Max        Decimal         `json:"max"`
+	Rationale  json.RawMessage `json:"rationale"`
+	Status     string          `json:"status"`
+	CreatedAt  time.Time       `json:"created_at"`
+	DecidedAt  *time.Time      `json:"decided_at"`
+}
+
+// MonthlyIssues is how much of an item was issued in each month, oldest
+// first.
+type MonthlyIssues struct {
+	ItemId string
+	Min    Decimal
+	Max    Decimal
+	Months []Decimal
+}
+
+func (rec *Recommendation) scan(row rowScanner) error {
+	var rationale []byte
+
+	if err := row.Scan(
+		&rec.Id, &rec.ItemId, &rec.ItemName, &rec.CurrentMin, &rec.CurrentMax,
+		&rec.Min, &rec.Max, &rationale, &rec.Status, &rec.CreatedAt, &rec.DecidedAt,
+	); err != nil {
+		return err
+	}
+
+	rec.Rationale = rationale
+
+	return nil
+}
+
+// GetMonthlyIssues returns the issues of every active item per month, from
+// the month of the item's first movement (but not before from) up to the
+// month before until. Both from and until must be the first of a month.
+func GetMonthlyIssues(db *sql.DB, from, until time.Time) ([]MonthlyIssues, error) {
+	rows, err := db.Query(
+		`SELECT warehouse_items.id, warehouse_items.min, warehouse_items.max, MIN(stock_movements.created_at)
+		 FROM warehouse_items JOIN stock_movements ON stock_movements.item_id = warehouse_items.id
+		 WHERE warehouse_items.archived_at IS NULL AND stock_movements.created_at < $1
+		 GROUP BY warehouse_items.id ORDER BY warehouse_items.name, warehouse_items.id`,
+		until,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []MonthlyIssues{}
+	index := map[string]int{}
+	firsts := []time.Time{}
+
+	for rows.Next() {
+		var mi MonthlyIssues
+		var first time.Time
+
+		if err := rows.Scan(&mi.ItemId, &mi.Min, &mi.Max, &first); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		first = time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, until.Location())
+
+		if first.Before(from) {
+			first = from
+		}
+
+		for m := first; m.Before(until); m = m.AddDate(0, 1, 0) {
+			mi.Months = append(mi.Months, 0)
+		}
+
+		index[mi.ItemId] = len(issues)
+		issues = append(issues, mi)
+		firsts = append(firsts, first)
+	}
+
+	rows.Close()
+
+	rows, err = db.Query(
+		`SELECT item_id, created_at, -quantity FROM stock_movements
+		 WHERE kind = 'issue' AND created_at >= $1 AND created_at < $2`,
+		from, until,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemId string
+		var at time.Time
+		var quantity Decimal
+
+		if err := rows.Scan(&itemId, &at, &quantity); err != nil {
+			return nil, err
+		}
+
+		i, ok := index[itemId]
+
+		if !ok {
+			continue
+		}
+
+		at = at.In(until.Location())
+		first := firsts[i]
+		month := (at.Year()-first.Year())*12 + int(at.Month()) - int(first.Month())
+
+		if month >= 0 && month < len(issues[i].Months) {
+			issues[i].Months[month] = issues[i].Months[month].Add(quantity)
+		}
+	}
+
+	return issues, rows.Err()
+}
+
+// ReplacePendingRecommendations supersedes every pending recommendation with
+// recs.
+func ReplacePendingRecommendations(db *sql.DB, recs []Recommendation) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec("DELETE FROM min_max_recommendations WHERE status = 'pending'"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for i := range recs {
+		rec := &recs[i]
+
+		if err = tx.QueryRow(
+			`INSERT INTO min_max_recommendations (item_id, current_min, current_max, min, max, rationale)
+			 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, status, created_at`,
+			rec.ItemId, rec.CurrentMin, rec.CurrentMax, rec.Min, rec.Max, []byte(rec.Rationale),
+		).Scan(&rec.Id, &rec.Status, &rec.CreatedAt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func GetRecommendations(db *sql.DB, status string) ([]Recommendation, error) {
+	var args queryArgs
+
+	conditions := []string{}
+
+	if status != "" {
+		conditions = append(conditions, "min_max_recommendations.status = "+args.add(status))
+	}
+
+	rows, err := db.Query(
+		"SELECT "+recommendationColumns+" FROM "+recommendationTables+whereClause(conditions)+
+			" ORDER BY min_max_recommendations.created_at DESC, warehouse_items.name",
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	recs := []Recommendation{}
+
+	for rows.Next() {
+		var rec Recommendation
+
+		if err := rec.scan(rows); err != nil {
+			return nil, err
+		}
+
+		recs = append(recs, rec)
+	}
+
+	return recs, rows.Err()
+}
+
+// AcceptRecommendations applies the pending recommendations with the given
+// ids, or every pending one when ids is empty, to their items' min and max.
+// It returns the recommendations accepted.
+func AcceptRecommendations(db *sql.DB, ids []string) ([]Recommendation, error) {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	recs, err := decideRecommendations(tx, ids, RecommendationAccepted)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for _, rec := range recs {
+		if _, err = tx.Exec(
+			"UPDATE warehouse_items SET min = $1, max = $2, version = version + 1 WHERE id = $3",
+			rec.Min, rec.Max, rec.ItemId,
+		); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return recs, tx.Commit()
+}
+
+// DismissRecommendations marks pending recommendations as dismissed without
+// touching the items.
+func DismissRecommendations(db *sql.DB, ids []string) ([]Recommendation, error) {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	recs, err := decideRecommendations(tx, ids, RecommendationDismissed)
+
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return recs, tx.Commit()
+}
+
+func decideRecommendations(tx *sql.Tx, ids []string, status string) ([]Recommendation, error) {
+	var args queryArgs
+
+	conditions := []string{"status = 'pending'"}
+
+	if len(ids) > 0 {
+		conditions = append(conditions, "id = ANY("+args.add(pq.Array(ids))+")")
+	}
+
+	statusArg := args.add(status)
+
+	rows, err := tx.Query(
+		`WITH decided AS (
+			UPDATE min_max_recommendations SET status = `+statusArg+`, decided_at = NOW()`+whereClause(conditions)+`
+			RETURNING *
+		 )
+		 SELECT `+recommendationColumns+` FROM decided AS min_max_recommendations
+		 JOIN warehouse_items ON warehouse_items.id = min_max_recommendations.item_id
+		 ORDER BY warehouse_items.name`,
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	recs := []Recommendation{}
+
+	for rows.Next() {
+		var rec Recommendation
+
+		if err := rec.scan(rows); err != nil {
+			return nil, err
+		}
+
+		recs = append(recs, rec)
+	}
+
+	return recs, rows.Err()
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	ReservationActive    = "active"
+	ReservationFulfilled = "fulfilled"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+
+	// activeReservation matches reservations that still hold stock. Expired
+	// reservations keep status active in the table and stop counting as soon
+	// as expires_at passes.
+	activeReservation = `reservations.status = 'active'
+		AND (reservations.expires_at IS NULL OR reservations.expires_at > NOW())`
+
+	reservedQuantity = `COALESCE((SELECT SUM(reservations.quantity) FROM reservations
+		WHERE reservations.item_id = warehouse_items.id AND ` + activeReservation + `), 0)`
+
+	reservationColumns = `id, item_id, document_type, document_id, quantity,
+		CASE WHEN status = 'active' AND expires_at <= NOW() THEN 'expired' ELSE status END,
+		expires_at, created_at, closed_at`
+)
+
+var (
+	ErrStockReserved       = errors.New("stock is reserved")
+	ErrReservationClosed   = errors.New("reservation is not active")
+	ErrReservationExceeded = errors.New("quantity exceeds the reservation")
+)
+
+// Reservation holds stock of an item for a document, such as an approved
+// requisition, so that it is no longer available to other issues.
+type Reservation struct {
+	Id           string     `json:"id"`
+	ItemId       string     `json:"item_id"`
+	DocumentType string     `json:"document_type"`
+	DocumentId   string     `json:"document_id"`
+	Quantity     Decimal    `json:"quantity"`
+	Status       string     `json:"status"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ClosedAt     *time.Time `json:"closed_at"`
+}
+
+func (res *Reservation) scan(row rowScanner) error {
+	return row.Scan(
+		&res.Id, &res.ItemId, &res.DocumentType, &res.DocumentId, &res.Quantity,
+		&res.Status, &res.ExpiresAt, &res.CreatedAt, &res.ClosedAt,
+	)
+}
+
+// CreateReservation reserves stock of the item, failing with
+// ErrInsufficientStock when less than the quantity is available.
+func (res *Reservation) CreateReservation(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = res.createTx(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (res *Reservation) createTx(tx *sql.Tx) error {
+	var available Decimal
+
+	if err := tx.QueryRow(
+		"SELECT quantity - "+reservedQuantity+" FROM warehouse_items WHERE id = $1 AND archived_at IS NULL FOR UPDATE",
+		res.ItemId,
+	).Scan(&available); err != nil {
+		return err
+	}
+
+	if available < res.Quantity {
+		return ErrInsufficientStock
+	}
+
+	if err := res.scan(tx.QueryRow(
+		`INSERT INTO reservations (item_id, document_type, document_id, quantity, expires_at)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING `+reservationColumns,
+		res.ItemId, res.DocumentType, res.DocumentId, res.Quantity, res.ExpiresAt,
+	)); err != nil {
+		return err
+	}
+
+	return touchItem(tx, res.ItemId)
+}
+
+func (res *Reservation) GetReservationById(db *sql.DB) error {
+	return res.scan(db.QueryRow("SELECT "+reservationColumns+" FROM reservations WHERE id = $1", res.Id))
+}
+
+// GetReservations lists reservations, optionally only those of an item or of
+// a document.
+func GetReservations(db *sql.DB, itemId, documentType, documentId string) ([]Reservation, error) {
+	var args queryArgs
+
+	conditions := []string{}
+
+	if itemId != "" {
+		conditions = append(conditions, "item_id = "+args.add(itemId))
+	}
+
+	if documentType != "" {
+		conditions = append(conditions, "document_type = "+args.add(documentType))
+	}
+
+	if documentId != "" {
+		conditions = append(conditions, "document_id = "+args.add(documentId))
+	}
+
+	rows, err := db.Query(
+		"SELECT "+reservationColumns+" FROM reservations"+whereClause(conditions)+" ORDER BY created_at, id",
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	reservations := []Reservation{}
+
+	for rows.Next() {
+		var res Reservation
+
+		if err := res.scan(rows); err != nil {
+			return nil, err
+		}
+
+		reservations = append(reservations, res)
+	}
+
+	return reservations, rows.Err()
+}
+
+// Fulfill converts the reservation into an issue of quantity, or of the whole
+// reservation when quantity is zero. Whatever is not issued is released.
+func (res *Reservation) Fulfill(db *sql.DB, quantity Decimal) (StockMovement, error) {
+	m := StockMovement{ItemId: res.ItemId, Kind: MovementIssue, Reason: res.DocumentType + " " + res.DocumentId}
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return m, err
+	}
+
+	if err = res.lockActive(tx); err != nil {
+		tx.Rollback()
+		return m, err
+	}
+
+	if quantity.IsZero() {
+		quantity = res.Quantity
+	}
+
+	if quantity > res.Quantity {
+		tx.Rollback()
+		return m, ErrReservationExceeded
+	}
+
+	// Closing the reservation first means the issue below may consume the
+	// stock it was holding.
+	if err = res.close(tx, ReservationFulfilled); err != nil {
+		tx.Rollback()
+		return m, err
+	}
+
+	m.Quantity = quantity.Neg()
+
+	if err = m.CreateTx(tx); err != nil {
+		tx.Rollback()
+		return m, err
+	}
+
+	return m, tx.Commit()
+}
+
+func (res *Reservation) Release(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = res.lockActive(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = res.close(tx, ReservationReleased); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (res *Reservation) lockActive(tx *sql.Tx) error {
+	if err := res.scan(tx.QueryRow(
+		"SELECT "+reservationColumns+" FROM reservations WHERE id = $1 FOR UPDATE", res.Id,
+	)); err != nil {
+		return err
+	}
+
+	if res.Status != ReservationActive {
+		return ErrReservationClosed
+	}
+
+	return nil
+}
+
+func (res *Reservation) close(tx *sql.Tx, status string) error {
+	if err := tx.QueryRow(
+		"UPDATE reservations SET status = $1, closed_at = NOW() WHERE id = $2 RETURNING status, closed_at",
+		status, res.Id,
+	).Scan(&res.Status, &res.ClosedAt); err != nil {
+		return err
+	}
+
+	return touchItem(tx, res.ItemId)
+}
+
+// releaseDocument releases every reservation still held for the document.
+func releaseDocument(tx *sql.Tx, documentType, documentId string) error {
+	_, err := tx.Exec(
+		`WITH released AS (
+		   UPDATE reservations SET status = 'released', closed_at = NOW()
+		   WHERE document_type = $1 AND document_id = $2 AND status = 'active'
+		   RETURNING item_id
+		 )
+		 UPDATE warehouse_items SET version = version + 1 WHERE id IN (SELECT item_id FROM released)`,
+		documentType, documentId,
+	)
+
+	return err
+}
+
+// touchItem bumps the version of an item whose reservations changed, since
+// its reserved and available quantities changed with them.
+func touchItem(tx *sql.Tx, itemId string) error {
+	_, err := tx.Exec("UPDATE warehouse_items SET version = version + 1 WHERE id = $1", itemId)
+
+	return err
+}
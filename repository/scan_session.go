@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	ScanSessionReceipt = "receipt"
+	ScanSessionCount   = "count"
+
+	ScanSessionOpen      = "open"
+	ScanSessionCommitted = "committed"
+	ScanSessionCancelled = "cancelled"
+)
+
+var ErrScanSessionClosed = errors.New("scan session is not open")
+
+// ScanSession accumulates barcode scans that are committed together either as
+// a single stock receipt or as a count that replaces the on hand quantities.
+type ScanSession struct {
+	Id        string            `json:"id"`
+	Kind      string            `json:"kind"`
+	Status    string            `json:"status"`
+	Lines     []ScanSessionLine `json:"lines"`
+	CreatedAt time.Time         `json:"created_at"`
+	ClosedAt  *time.Time        `json:"closed_at"`
+}
+
+type ScanSessionLine struct {
+	ItemId   string  `json:"item_id"`
+	ItemName string  `json:"item_name"`
+	Quantity Decimal `json:"quantity"`
+}
+
+func (ss *ScanSession) CreateScanSession(db *sql.DB) error {
+	ss.Status = ScanSessionOpen
+	ss.Lines = []ScanSessionLine{}
+
+	return db.QueryRow(
+		"INSERT INTO scan_sessions (kind) VALUES ($1) RETURNING id, created_at", ss.Kind,
+	).Scan(&ss.Id, &ss.CreatedAt)
+}
+
+func (ss *ScanSession) GetScanSessionById(db *sql.DB) error {
+	if err := db.QueryRow(
+		"SELECT id, kind, status, created_at, closed_at FROM scan_sessions WHERE id = $1", ss.Id,
+	).Scan(&ss.Id, &ss.Kind, &ss.Status, &ss.CreatedAt, &ss.ClosedAt); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(
+		`SELECT scan_session_lines.item_id, warehouse_items.name, scan_session_lines.quantity
+		 FROM scan_session_lines JOIN warehouse_items ON warehouse_items.id = scan_session_lines.item_id
+		 WHERE scan_session_lines.session_id = $1 ORDER BY warehouse_items.name`,
+		ss.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	ss.Lines = []ScanSessionLine{}
+
+	for rows.Next() {
+		var l ScanSessionLine
+
+		if err := rows.Scan(&l.ItemId, &l.ItemName, &l.Quantity); err != nil {
+			return err
+		}
+
+		ss.Lines = append(ss.Lines, l)
+	}
+
+	return nil
+}
+
+// AddScan adds quantity of the item to the session, returning the line total.
+func (ss *ScanSession) AddScan(db *sql.DB, itemId string, quantity Decimal) (Decimal, error) {
+	var total Decimal
+
+	err := db.QueryRow(
+		`INSERT INTO scan_session_lines (session_id, item_id, quantity)
+		 SELECT id, $2, $3 FROM scan_sessions WHERE id = $1 AND status = 'open'
+		 ON CONFLICT (session_id, item_id)
+		 DO UPDATE SET quantity = scan_session_lines.quantity + EXCLUDED.quantity
+		 RETURNING quantity`,
+		ss.Id, itemId, quantity,
+	).Scan(&total)
+
+	if err == sql.ErrNoRows {
+		return 0, ErrScanSessionClosed
+	}
+
+	return total, err
+}
+
+// Commit posts the session's lines as stock movements in a single
+// transaction. Receipts add the scanned quantities while counts adjust each
+// scanned item to the counted quantity. Counted items are locked while their
+// adjustment is worked out, so a movement posted meanwhile is not lost.
+func (ss *ScanSession) Commit(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = ss.lockOpen(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	rows, err := tx.Query(
+		`SELECT scan_session_lines.item_id, scan_session_lines.quantity, warehouse_items.quantity
+		 FROM scan_session_lines JOIN warehouse_items ON warehouse_items.id = scan_session_lines.item_id
+		 WHERE scan_session_lines.session_id = $1
+		 ORDER BY scan_session_lines.item_id FOR UPDATE OF warehouse_items`,
+		ss.Id,
+	)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	movements := []StockMovement{}
+
+	for rows.Next() {
+		var itemId string
+		var scanned, onHand Decimal
+
+		if err = rows.Scan(&itemId, &scanned, &onHand); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+
+		m := StockMovement{ItemId: itemId, Kind: MovementReceipt, Quantity: scanned, Reason: "scan session " + ss.Id}
+
+		if ss.Kind == ScanSessionCount {
+			m.Kind = MovementAdjustment
+			m.Quantity = scanned.Sub(onHand)
+		}
+
+		if !m.Quantity.IsZero() {
+			movements = append(movements, m)
+		}
+	}
+
+	rows.Close()
+
+	for _, m := range movements {
+		if err = m.CreateTx(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = ss.close(tx, ScanSessionCommitted); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ss *ScanSession) Cancel(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = ss.lockOpen(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = ss.close(tx, ScanSessionCancelled); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ss *ScanSession) lockOpen(tx *sql.Tx) error {
+	if err := tx.QueryRow(
+		"SELECT kind, status FROM scan_sessions WHERE id = $1 FOR UPDATE", ss.Id,
+	).Scan(&ss.Kind, &ss.Status); err != nil {
+		return err
+	}
+
+	if ss.Status != ScanSessionOpen {
+		return ErrScanSessionClosed
+	}
+
+	return nil
+}
+
+func (ss *ScanSession) close(tx *sql.Tx, status string) error {
+	ss.Status = status
+
+	return tx.QueryRow(
+		"UPDATE scan_sessions SET status = $1, closed_at = NOW() WHERE id = $2 RETURNING closed_at",
+		status, ss.Id,
+	).Scan(&ss.ClosedAt)
+}
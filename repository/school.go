@@ -1,12 +1,78 @@
 package repository
 
-import "database/sql"
+import (
+	"database/sql"
 
+	"github.com/lib/pq"
+)
+
+// School is a school supplied by the warehouse. Enrollment is its number of
+// students, which stock is split by when it runs short.
 type School struct {
-	Id   string `json:"id"`
-	Name string `json:"name"`
+	Id         string `json:"id"`
+	Name       string `json:"name"`
+	Enrollment int    `json:"enrollment"`
 }
 
 func (s *School) CreateSchool(db *sql.DB) error {
-	return db.QueryRow("INSERT INTO schools (name) VALUES ($1) RETURNING id", s.Name).Scan(&s.Id)
+	return db.QueryRow(
+		"INSERT INTO schools (name, enrollment) VALUES ($1, $2) RETURNING id", s.Name, s.Enrollment,
+	).Scan(&s.Id)
+}
+
+func (s *School) GetSchoolById(db *sql.DB) error {
+	return db.QueryRow("SELECT name, enrollment FROM schools WHERE id = $1", s.Id).Scan(&s.Name, &s.Enrollment)
+}
+
+func (s *School) UpdateSchool(db *sql.DB) error {
+	res, err := db.Exec("UPDATE schools SET name = $1, enrollment = $2 WHERE id = $3", s.Name, s.Enrollment, s.Id)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetSchools lists the schools, or only those in ids when it is not empty.
+func GetSchools(db *sql.DB, ids []string) ([]School, error) {
+	var args queryArgs
+
+	conditions := []string{}
+
+	if len(ids) > 0 {
+		conditions = append(conditions, "id = ANY("+args.add(pq.Array(ids))+")")
+	}
+
+	rows, err := db.Query("SELECT id, name, enrollment FROM schools"+whereClause(conditions)+" ORDER BY name, id", args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	schools := []School{}
+
+	for rows.Next() {
+		var s School
+
+		if err := rows.Scan(&s.Id, &s.Name, &s.Enrollment); err != nil {
+			return nil, err
+		}
+
+		schools = append(schools, s)
+	}
+
+	return schools, rows.Err()
 }
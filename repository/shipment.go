@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	ShipmentDraft      = "draft"
+	ShipmentDispatched = "dispatched"
+	ShipmentCancelled  = "cancelled"
+
+	shipmentDocument = "shipment"
+
+	shipmentColumns = `shipments.id, shipments.school_id, schools.name, shipments.status, shipments.period,
+		shipments.notes, shipments.created_by, shipments.created_at, shipments.dispatched_at, shipments.cancelled_at`
+
+	shipmentTables = `shipments JOIN schools ON schools.id = shipments.school_id`
+)
+
+var ErrShipmentNotDraft = errors.New("shipment is not a draft")
+
+// Shipment is stock on its way to a school. Drafts reserve their lines so the
+// stock is not issued elsewhere, and dispatching one issues them. Period is
+// set on shipments generated from allotments.
+type Shipment struct {
+	Id           string         `json:"id"`
+	SchoolId     string         `json:"school_id"`
+	SchoolName   string         `json:"school,omitempty"`
+	Status       string         `json:"status"`
+	Period       *string        `json:"period"`
+	Notes        string         `json:"notes"`
+	CreatedBy    *string        `json:"created_by"`
+	CreatedAt    time.Time      `json:"created_at"`
+	DispatchedAt *time.Time     `json:"dispatched_at"`
+	CancelledAt  *time.Time     `json:"cancelled_at"`
+	Lines        []ShipmentLine `json:"lines"`
+}
+
+type ShipmentLine struct {
+	ItemId   string  `json:"item_id"`
+	Name     string  `json:"name"`
+	Quantity Decimal `json:"quantity"`
+	Unit     string  `json:"unit"`
+}
+
+func (sh *Shipment) scan(row rowScanner) error {
+	return row.Scan(
+		&sh.Id, &sh.SchoolId, &sh.SchoolName, &sh.Status, &sh.Period, &sh.Notes,
+		&sh.CreatedBy, &sh.CreatedAt, &sh.DispatchedAt, &sh.CancelledAt,
+	)
+}
+
+func (sh *Shipment) get(q querier, lock bool) error {
+	query := "SELECT " + shipmentColumns + " FROM " + shipmentTables + " WHERE shipments.id = $1"
+
+	if lock {
+		query += " FOR UPDATE OF shipments"
+	}
+
+	if err := sh.scan(q.QueryRow(query, sh.Id)); err != nil {
+		return err
+	}
+
+	return sh.loadLines(q)
+}
+
+func (sh *Shipment) loadLines(q querier) error {
+	rows, err := q.Query(
+		`SELECT shipment_lines.item_id, warehouse_items.name, shipment_lines.quantity, warehouse_items.unit
+		 FROM shipment_lines JOIN warehouse_items ON warehouse_items.id = shipment_lines.item_id
+		 WHERE shipment_lines.shipment_id = $1 ORDER BY warehouse_items.name, shipment_lines.item_id`,
+		sh.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	sh.Lines = []ShipmentLine{}
+
+	for rows.Next() {
+		var l ShipmentLine
+
+		if err := rows.Scan(&l.ItemId, &l.Name, &l.Quantity, &l.Unit); err != nil {
+			return err
+		}
+
+		sh.Lines = append(sh.Lines, l)
+	}
+
+	return rows.Err()
+}
+
+func (sh *Shipment) GetShipmentById(db *sql.DB) error {
+	return sh.get(db, false)
+}
+
+// CreateDraftShipments creates the shipments as drafts and reserves their
+// lines. Either all of them are created or, when stock runs out, none are.
+func CreateDraftShipments(db *sql.DB, shipments []Shipment, userId *string) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	for i := range shipments {
+		sh := &shipments[i]
+
+		if err = tx.QueryRow(
+			"INSERT INTO shipments (school_id, period, notes, created_by) VALUES ($1, $2, $3, $4) RETURNING id",
+			sh.SchoolId, sh.Period, sh.Notes, userId,
+		).Scan(&sh.Id); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		for _, l := range sh.Lines {
+			if _, err = tx.Exec(
+				"INSERT INTO shipment_lines (shipment_id, item_id, quantity) VALUES ($1, $2, $3)",
+				sh.Id, l.ItemId, l.Quantity,
+			); err != nil {
+				tx.Rollback()
+				return err
+			}
+
+			res := Reservation{ItemId: l.ItemId, DocumentType: shipmentDocument, DocumentId: sh.Id, Quantity: l.Quantity}
+
+			if err = res.createTx(tx); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		if err = sh.get(tx, false); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetShipments lists shipments, most recent first, optionally only those of a
+// school, with a status or generated for a period.
+func GetShipments(db *sql.DB, schoolId, status, period string) ([]Shipment, error) {
+	var args queryArgs
+
+	conditions := []string{}
+
+	if schoolId != "" {
+		conditions = append(conditions, "shipments.school_id = "+args.add(schoolId))
+	}
+
+	if status != "" {
+		conditions = append(conditions, "shipments.status = "+args.add(status))
+	}
+
+	if period != "" {
+		conditions = append(conditions, "shipments.period = "+args.add(period))
+	}
+
+	rows, err := db.Query(
+		"SELECT "+shipmentColumns+" FROM "+shipmentTables+whereClause(conditions)+
+			" ORDER BY shipments.created_at DESC, shipments.id",
+		args...,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	shipments := []Shipment{}
+
+	for rows.Next() {
+		var sh Shipment
+
+		if err := sh.scan(rows); err != nil {
+			return nil, err
+		}
+
+		shipments = append(shipments, sh)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range shipments {
+		if err := shipments[i].loadLines(db); err != nil {
+			return nil, err
+		}
+	}
+
+	return shipments, nil
+}
+
+// Dispatch issues the draft's lines out of the stock reserved for them.
+func (sh *Shipment) Dispatch(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = sh.lockDraft(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Releasing the reservations first lets the issues below consume the
+	// stock they were holding.
+	if err = releaseDocument(tx, shipmentDocument, sh.Id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, l := range sh.Lines {
+		m := StockMovement{
+			ItemId:   l.ItemId,
+			Kind:     MovementIssue,
+			Quantity: l.Quantity.Neg(),
+			Reason:   shipmentDocument + " " + sh.Id,
+		}
+
+		if err = m.CreateTx(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = sh.close(tx, ShipmentDispatched, "dispatched_at"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Cancel drops the draft and releases the stock it reserved.
+func (sh *Shipment) Cancel(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = sh.lockDraft(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = releaseDocument(tx, shipmentDocument, sh.Id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = sh.close(tx, ShipmentCancelled, "cancelled_at"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (sh *Shipment) lockDraft(tx *sql.Tx) error {
+	if err := sh.get(tx, true); err != nil {
+		return err
+	}
+
+	if sh.Status != ShipmentDraft {
+		return ErrShipmentNotDraft
+	}
+
+	return nil
+}
+
+func (sh *Shipment) close(tx *sql.Tx, status, column string) error {
+	if _, err := tx.Exec("UPDATE shipments SET status = $1, "+column+" = NOW() WHERE id = $2", status, sh.Id); err != nil {
+		return err
+	}
+
+	return sh.get(tx, false)
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+const warehouseItemColumnsAsOf = warehouseItemFields + "warehouse_items.reserved"
+
+// warehouseItemTablesAsOf stands in for warehouseItemTables with the items as
+// they were at the instant at, a query placeholder. Quantities are worked out
+// backwards from the first stock snapshot after at, or from the current
+// quantity when there is none, so only a day of movements is summed however
+// old at is. Names, levels and categories are today's.
+func warehouseItemTablesAsOf(at string) string {
+	return `(SELECT warehouse_items.id, warehouse_items.name, warehouse_items.min, warehouse_items.max,
+		warehouse_items.category_id, warehouse_items.unit, warehouse_items.pack_unit,
+		warehouse_items.pack_size, warehouse_items.version, warehouse_items.product_id, warehouse_items.variant,
+		CASE WHEN warehouse_items.archived_at <= ` + at + ` THEN warehouse_items.archived_at END AS archived_at,
+		COALESCE(snapshot.quantity, warehouse_items.quantity) - COALESCE((
+			SELECT SUM(stock_movements.quantity) FROM stock_movements
+			WHERE stock_movements.item_id = warehouse_items.id AND stock_movements.created_at >= ` + at + `
+			AND (snapshot.taken_at IS NULL OR stock_movements.created_at < snapshot.taken_at)
+		), 0) AS quantity,
+		COALESCE((
+			SELECT SUM(reservations.quantity) FROM reservations
+			WHERE reservations.item_id = warehouse_items.id AND reservations.created_at < ` + at + `
+			AND (reservations.closed_at IS NULL OR reservations.closed_at >= ` + at + `)
+			AND (reservations.expires_at IS NULL OR reservations.expires_at > ` + at + `)
+		), 0) AS reserved
+		FROM warehouse_items
+		LEFT JOIN LATERAL (
+			SELECT stock_snapshots.taken_at, stock_snapshots.quantity FROM stock_snapshots
+			WHERE stock_snapshots.item_id = warehouse_items.id AND stock_snapshots.taken_at > ` + at + `
+			ORDER BY stock_snapshots.taken_at LIMIT 1
+		) AS snapshot ON TRUE
+		WHERE warehouse_items.created_at < ` + at + `
+	) AS warehouse_items
+	LEFT JOIN categories ON categories.id = warehouse_items.category_id`
+}
+
+// GetWarehouseItemAsOf loads the item as it was at the instant at, counting
+// the movements made before it. It fails with sql.ErrNoRows when the item did
+// not exist yet, or was already archived unless includeArchived is set.
+func (wi *WarehouseItem) GetWarehouseItemAsOf(db *sql.DB, at time.Time, includeArchived bool) error {
+	query := "SELECT " + warehouseItemColumnsAsOf + " FROM " + warehouseItemTablesAsOf("$2") + " WHERE warehouse_items.id = $1"
+
+	if !includeArchived {
+		query += " AND warehouse_items.archived_at IS NULL"
+	}
+
+	return wi.scan(db.QueryRow(query, wi.Id, at))
+}
+
+// TakeStockSnapshots records every item's quantity at the instant at, which
+// should be far enough in the past that no transaction still adding movements
+// before it is open. Taking a snapshot again replaces it. It returns how many
+// items were recorded.
+func TakeStockSnapshots(db *sql.DB, at time.Time) (int64, error) {
+	res, err := db.Exec(
+		`INSERT INTO stock_snapshots (item_id, taken_at, quantity)
+		 SELECT warehouse_items.id, $1::timestamptz, warehouse_items.quantity FROM `+warehouseItemTablesAsOf("$1")+`
+		 ON CONFLICT (item_id, taken_at) DO UPDATE SET quantity = EXCLUDED.quantity`,
+		at,
+	)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	MovementReceipt    = "receipt"
+	MovementIssue      = "issue"
+	MovementAdjustment = "adjustment"
+
+	// Assembly and disassembly movements move stock between a kit and its
+	// components.
+	MovementAssembly    = "assembly"
+	MovementDisassembly = "disassembly"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrExpiredStock      = errors.New("remaining stock is expired")
+	ErrLotNotFound       = errors.New("lot not found")
+	ErrItemLocked        = errors.New("item is being counted in an open stocktake")
+)
+
+// lockedByStocktake matches items that are part of an open stocktake. Stock
+// changes to them are refused until the stocktake is approved or cancelled.
+const lockedByStocktake = `EXISTS (
+	SELECT 1 FROM stocktake_lines JOIN stocktakes ON stocktakes.id = stocktake_lines.stocktake_id
+	WHERE stocktake_lines.item_id = warehouse_items.id AND stocktakes.status = 'open')`
+
+// StockMovement is a signed change to an item's quantity: receipts are
+// positive and issues negative. Every change to stock goes through one.
+// UnitCost is the cost of one base unit and is only known for receipts.
+type StockMovement struct {
+	Id        string          `json:"id"`
+	ItemId    string          `json:"item_id"`
+	Kind      string          `json:"kind"`
+	Quantity  Decimal         `json:"quantity"`
+	Reason    string          `json:"reason"`
+	Balance   Decimal         `json:"balance"`
+	UnitCost  *Decimal        `json:"unit_cost,omitempty"`
+	LotId     *string         `json:"lot_id,omitempty"`
+	Lots      []LotAllocation `json:"lots"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	// Lot, when set on a receipt, is created with the received quantity.
+	Lot *Lot `json:"-"`
+}
+
+type lotStock struct {
+	id       string
+	quantity Decimal
+	expired  bool
+}
+
+func (m *StockMovement) Create(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = m.CreateTx(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// usesAvailableStock reports whether the movement takes out stock and so must
+// leave expired lots and reserved stock alone. Adjustments correct what is
+// physically there and may touch both.
+func (m *StockMovement) usesAvailableStock() bool {
+	return m.Quantity.IsNegative() && m.Kind != MovementAdjustment
+}
+
+// CreateTx applies the movement to the item's quantity with a single
+// conditional update that refuses to go below zero, and records it as part of
+// tx. Outgoing movements consume lots first-expiring-first-out, then stock
+// that was received without a lot. Issues and assembly never consume expired
+// lots or stock held by reservations.
+func (m *StockMovement) CreateTx(tx *sql.Tx) error {
+	err := tx.QueryRow(
+		`UPDATE warehouse_items SET quantity = quantity + $1, version = version + 1
+		 WHERE id = $2 AND archived_at IS NULL AND NOT `+lockedByStocktake+`
+		 AND quantity + $1 >= CASE WHEN $3 THEN `+reservedQuantity+` ELSE 0 END
+		 RETURNING quantity`,
+		m.Quantity, m.ItemId, m.usesAvailableStock(),
+	).Scan(&m.Balance)
+
+	if err == sql.ErrNoRows {
+		var archived, locked, enough bool
+
+		if err = tx.QueryRow(
+			"SELECT archived_at IS NOT NULL, "+lockedByStocktake+", quantity + $2 >= 0 FROM warehouse_items WHERE id = $1",
+			m.ItemId, m.Quantity,
+		).Scan(&archived, &locked, &enough); err != nil {
+			return err
+		}
+
+		if archived {
+			return ErrItemArchived
+		}
+
+		if locked {
+			return ErrItemLocked
+		}
+
+		if enough {
+			return ErrStockReserved
+		}
+
+		return ErrInsufficientStock
+	}
+
+	if err != nil {
+		return err
+	}
+
+	m.Lots = []LotAllocation{}
+
+	if m.Quantity.IsPositive() && m.Lot != nil {
+		m.Lot.ItemId = m.ItemId
+		m.Lot.Quantity = m.Quantity
+
+		if err := m.Lot.createTx(tx); err != nil {
+			return err
+		}
+
+		m.Lots = append(m.Lots, LotAllocation{LotId: m.Lot.Id, Quantity: m.Quantity})
+	}
+
+	if m.Quantity.IsNegative() {
+		if err := m.consumeLots(tx, m.Balance.Sub(m.Quantity)); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.QueryRow(
+		`INSERT INTO stock_movements (item_id, kind, quantity, reason, unit_cost)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		m.ItemId, m.Kind, m.Quantity, m.Reason, m.UnitCost,
+	).Scan(&m.Id, &m.CreatedAt); err != nil {
+		return err
+	}
+
+	for _, a := range m.Lots {
+		if _, err := tx.Exec(
+			"INSERT INTO stock_movement_lots (movement_id, lot_id, quantity) VALUES ($1, $2, $3)",
+			m.Id, a.LotId, a.Quantity,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *StockMovement) consumeLots(tx *sql.Tx, onHand Decimal) error {
+	var rows *sql.Rows
+	var err error
+
+	if m.LotId != nil {
+		rows, err = tx.Query(
+			`SELECT id, quantity, COALESCE(expires_at < CURRENT_DATE, FALSE) FROM stock_lots
+			 WHERE id = $1 AND item_id = $2 FOR UPDATE`,
+			*m.LotId, m.ItemId)
+	} else {
+		rows, err = tx.Query(
+			`SELECT id, quantity, COALESCE(expires_at < CURRENT_DATE, FALSE) FROM stock_lots
+			 WHERE item_id = $1 AND quantity > 0
+			 ORDER BY expires_at NULLS LAST, received_at FOR UPDATE`,
+			m.ItemId)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	lots := []lotStock{}
+	var tracked Decimal
+
+	for rows.Next() {
+		var l lotStock
+
+		if err = rows.Scan(&l.id, &l.quantity, &l.expired); err != nil {
+			rows.Close()
+			return err
+		}
+
+		tracked = tracked.Add(l.quantity)
+		lots = append(lots, l)
+	}
+
+	rows.Close()
+
+	if m.LotId != nil && len(lots) == 0 {
+		return ErrLotNotFound
+	}
+
+	needed := m.Quantity.Neg()
+	var expired Decimal
+
+	for _, l := range lots {
+		if needed.IsZero() {
+			break
+		}
+
+		if l.expired && m.usesAvailableStock() {
+			expired = expired.Add(l.quantity)
+			continue
+		}
+
+		take := l.quantity
+
+		if needed < take {
+			take = needed
+		}
+
+		if take.IsZero() {
+			continue
+		}
+
+		m.Lots = append(m.Lots, LotAllocation{LotId: l.id, Quantity: take.Neg()})
+		needed = needed.Sub(take)
+	}
+
+	if untracked := onHand.Sub(tracked); m.LotId == nil && untracked.IsPositive() {
+		take := untracked
+
+		if needed < take {
+			take = needed
+		}
+
+		needed = needed.Sub(take)
+	}
+
+	if needed.IsPositive() {
+		if expired >= needed {
+			return ErrExpiredStock
+		}
+
+		return ErrInsufficientStock
+	}
+
+	for _, a := range m.Lots {
+		if _, err = tx.Exec(
+			"UPDATE stock_lots SET quantity = quantity + $1 WHERE id = $2", a.Quantity, a.LotId,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func GetStockMovementsByItem(db *sql.DB, itemId string, p PageRequest) (Page[StockMovement], error) {
+	const sort = "created_at:desc"
+
+	if err := p.checkSort(sort); err != nil {
+		return Page[StockMovement]{}, err
+	}
+
+	var args queryArgs
+
+	conditions := []string{"item_id = " + args.add(itemId)}
+	keyset, orderBy := p.keyset("created_at", "id", true, args.add)
+
+	if keyset != "" {
+		conditions = append(conditions, keyset)
+	}
+
+	rows, err := db.Query(
+		"SELECT id, item_id, kind, quantity, reason, unit_cost, created_at FROM stock_movements"+
+			whereClause(conditions)+orderBy+" LIMIT "+args.add(p.Count+1),
+		args...,
+	)
+
+	if err != nil {
+		return Page[StockMovement]{}, err
+	}
+
+	defer rows.Close()
+
+	movements := []StockMovement{}
+
+	for rows.Next() {
+		var m StockMovement
+
+		if err := rows.Scan(&m.Id, &m.ItemId, &m.Kind, &m.Quantity, &m.Reason, &m.UnitCost, &m.CreatedAt); err != nil {
+			return Page[StockMovement]{}, err
+		}
+
+		movements = append(movements, m)
+	}
+
+	page := newPage(movements, p, sort, func(m StockMovement) (string, string) {
+		return m.CreatedAt.Format(time.RFC3339Nano), m.Id
+	})
+
+	if p.WithTotal {
+		if err := db.QueryRow(
+			"SELECT COUNT(*) FROM stock_movements WHERE item_id = $1", itemId,
+		).Scan(&page.Total); err != nil {
+			return Page[StockMovement]{}, err
+		}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,406 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	StocktakeOpen      = "open"
+	StocktakeApproved  = "approved"
+	StocktakeCancelled = "cancelled"
+)
+
+var (
+	ErrStocktakeClosed    = errors.New("stocktake is not open")
+	ErrItemNotInStocktake = errors.New("item is not part of the stocktake")
+	ErrStocktakeDisputed  = errors.New("stocktake has disputed counts")
+)
+
+// Stocktake is a physical count of the warehouse. Opening one snapshots the
+// expected quantity of every item in scope and locks those items against
+// stock changes until the stocktake is approved or cancelled.
+type Stocktake struct {
+	Id         string          `json:"id"`
+	Status     string          `json:"status"`
+	Blind      bool            `json:"blind"`
+	CategoryId *string         `json:"category_id"`
+	CreatedBy  *string         `json:"created_by"`
+	Lines      []StocktakeLine `json:"lines"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ClosedAt   *time.Time      `json:"closed_at"`
+}
+
+// StocktakeLine is an item being counted. Counted is the most recent count
+// submitted by any counter and Disputed is set when counters disagree.
+type StocktakeLine struct {
+	ItemId   string           `json:"item_id"`
+	ItemName string           `json:"item_name"`
+	Expected *Decimal         `json:"expected,omitempty"`
+	Counted  *Decimal         `json:"counted"`
+	Variance *Decimal         `json:"variance,omitempty"`
+	Disputed bool             `json:"disputed"`
+	Counts   []StocktakeCount `json:"counts"`
+}
+
+// StocktakeCount is a single counter's count of an item. Counting the same
+// item again replaces the counter's previous count.
+type StocktakeCount struct {
+	CountedBy string    `json:"counted_by"`
+	Quantity  Decimal   `json:"quantity"`
+	CountedAt time.Time `json:"counted_at"`
+}
+
+// CreateStocktake opens the stocktake over itemIds, or over every active item
+// of the category (or of the whole warehouse) when itemIds is empty. It fails
+// with ErrItemLocked if any of those items is already being counted.
+func (st *Stocktake) CreateStocktake(db *sql.DB, itemIds []string) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	var args queryArgs
+
+	conditions := []string{"archived_at IS NULL"}
+
+	if len(itemIds) > 0 {
+		conditions = append(conditions, "id = ANY("+args.add(pq.Array(itemIds))+")")
+	}
+
+	if st.CategoryId != nil {
+		conditions = append(conditions, "category_id = "+args.add(*st.CategoryId))
+	}
+
+	rows, err := tx.Query(
+		"SELECT id, quantity, "+lockedByStocktake+" FROM warehouse_items"+whereClause(conditions)+" FOR UPDATE",
+		args...,
+	)
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	expected := map[string]Decimal{}
+
+	for rows.Next() {
+		var id string
+		var quantity Decimal
+		var locked bool
+
+		if err = rows.Scan(&id, &quantity, &locked); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+
+		if locked {
+			rows.Close()
+			tx.Rollback()
+			return ErrItemLocked
+		}
+
+		expected[id] = quantity
+	}
+
+	rows.Close()
+
+	if err = tx.QueryRow(
+		"INSERT INTO stocktakes (blind, category_id, created_by) VALUES ($1, $2, $3) RETURNING id, status, created_at",
+		st.Blind, st.CategoryId, st.CreatedBy,
+	).Scan(&st.Id, &st.Status, &st.CreatedAt); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for id, quantity := range expected {
+		if _, err = tx.Exec(
+			"INSERT INTO stocktake_lines (stocktake_id, item_id, expected) VALUES ($1, $2, $3)",
+			st.Id, id, quantity,
+		); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = st.loadLines(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (st *Stocktake) GetStocktakeById(db *sql.DB) error {
+	if err := st.get(db); err != nil {
+		return err
+	}
+
+	return st.loadLines(db)
+}
+
+// AddCount records counter's count of the item, replacing the counter's
+// previous count of it.
+func (st *Stocktake) AddCount(db *sql.DB, itemId, counter string, quantity Decimal) error {
+	var status string
+	var inStocktake bool
+
+	if err := db.QueryRow(
+		`SELECT status, EXISTS (SELECT 1 FROM stocktake_lines WHERE stocktake_id = $1 AND item_id = $2)
+		 FROM stocktakes WHERE id = $1`,
+		st.Id, itemId,
+	).Scan(&status, &inStocktake); err != nil {
+		return err
+	}
+
+	if !inStocktake {
+		return ErrItemNotInStocktake
+	}
+
+	res, err := db.Exec(
+		`INSERT INTO stocktake_counts (stocktake_id, item_id, counted_by, quantity)
+		 SELECT id, $2, $3, $4 FROM stocktakes WHERE id = $1 AND status = 'open'
+		 ON CONFLICT (stocktake_id, item_id, counted_by)
+		 DO UPDATE SET quantity = EXCLUDED.quantity, counted_at = NOW()`,
+		st.Id, itemId, counter, quantity,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrStocktakeClosed
+	}
+
+	return nil
+}
+
+// Approve closes the stocktake and posts an adjustment for every counted item
+// whose count differs from its quantity on hand, all in one transaction.
+// Items nobody counted are left untouched. It fails with ErrStocktakeDisputed,
+// leaving the lines loaded, while counters disagree on any item; recounting it
+// until they agree clears the dispute.
+func (st *Stocktake) Approve(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = st.lockOpen(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = st.loadLines(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if len(st.DisputedItems()) > 0 {
+		tx.Rollback()
+		return ErrStocktakeDisputed
+	}
+
+	// Closing first releases the stocktake's lock on its items so the
+	// adjustments below can go through.
+	if err = st.close(tx, StocktakeApproved); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, l := range st.Lines {
+		if l.Counted == nil {
+			continue
+		}
+
+		var onHand Decimal
+
+		if err = tx.QueryRow(
+			"SELECT quantity FROM warehouse_items WHERE id = $1 FOR UPDATE", l.ItemId,
+		).Scan(&onHand); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		m := StockMovement{
+			ItemId:   l.ItemId,
+			Kind:     MovementAdjustment,
+			Quantity: l.Counted.Sub(onHand),
+			Reason:   "stocktake " + st.Id,
+		}
+
+		if m.Quantity.IsZero() {
+			continue
+		}
+
+		if err = m.CreateTx(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DisputedItems returns the ids of the items counters disagree on.
+func (st *Stocktake) DisputedItems() []string {
+	ids := []string{}
+
+	for _, l := range st.Lines {
+		if l.Disputed {
+			ids = append(ids, l.ItemId)
+		}
+	}
+
+	return ids
+}
+
+func (st *Stocktake) Cancel(db *sql.DB) error {
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = st.lockOpen(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = st.close(tx, StocktakeCancelled); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// HideExpected strips what a blind count must not reveal to counters: the
+// expected quantities and everybody else's counts.
+func (st *Stocktake) HideExpected(counter string) {
+	for i := range st.Lines {
+		l := &st.Lines[i]
+
+		l.Expected = nil
+		l.Variance = nil
+		l.Counted = nil
+		l.Disputed = false
+
+		own := []StocktakeCount{}
+
+		for _, c := range l.Counts {
+			if c.CountedBy == counter {
+				own = append(own, c)
+			}
+		}
+
+		l.Counts = own
+	}
+}
+
+func (st *Stocktake) get(q querier) error {
+	return q.QueryRow(
+		"SELECT id, status, blind, category_id, created_by, created_at, closed_at FROM stocktakes WHERE id = $1", st.Id,
+	).Scan(&st.Id, &st.Status, &st.Blind, &st.CategoryId, &st.CreatedBy, &st.CreatedAt, &st.ClosedAt)
+}
+
+func (st *Stocktake) loadLines(q querier) error {
+	rows, err := q.Query(
+		`SELECT stocktake_lines.item_id, warehouse_items.name, stocktake_lines.expected
+		 FROM stocktake_lines JOIN warehouse_items ON warehouse_items.id = stocktake_lines.item_id
+		 WHERE stocktake_lines.stocktake_id = $1 ORDER BY warehouse_items.name, warehouse_items.id`,
+		st.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	st.Lines = []StocktakeLine{}
+	index := map[string]int{}
+
+	for rows.Next() {
+		var l StocktakeLine
+		var expected Decimal
+
+		if err := rows.Scan(&l.ItemId, &l.ItemName, &expected); err != nil {
+			rows.Close()
+			return err
+		}
+
+		l.Expected = &expected
+		l.Counts = []StocktakeCount{}
+		index[l.ItemId] = len(st.Lines)
+		st.Lines = append(st.Lines, l)
+	}
+
+	rows.Close()
+
+	rows, err = q.Query(
+		`SELECT item_id, counted_by, quantity, counted_at FROM stocktake_counts
+		 WHERE stocktake_id = $1 ORDER BY counted_at`,
+		st.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemId string
+		var c StocktakeCount
+
+		if err := rows.Scan(&itemId, &c.CountedBy, &c.Quantity, &c.CountedAt); err != nil {
+			return err
+		}
+
+		l := &st.Lines[index[itemId]]
+
+		for _, other := range l.Counts {
+			if other.Quantity != c.Quantity {
+				l.Disputed = true
+			}
+		}
+
+		counted := c.Quantity
+		variance := counted.Sub(*l.Expected)
+
+		l.Counted = &counted
+		l.Variance = &variance
+		l.Counts = append(l.Counts, c)
+	}
+
+	return rows.Err()
+}
+
+func (st *Stocktake) lockOpen(tx *sql.Tx) error {
+	if err := tx.QueryRow(
+		"SELECT status FROM stocktakes WHERE id = $1 FOR UPDATE", st.Id,
+	).Scan(&st.Status); err != nil {
+		return err
+	}
+
+	if st.Status != StocktakeOpen {
+		return ErrStocktakeClosed
+	}
+
+	return nil
+}
+
+func (st *Stocktake) close(tx *sql.Tx, status string) error {
+	st.Status = status
+
+	return tx.QueryRow(
+		"UPDATE stocktakes SET status = $1, closed_at = NOW() WHERE id = $2 RETURNING closed_at",
+		status, st.Id,
+	).Scan(&st.ClosedAt)
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const studentColumns = "id, school_id, enrollment_id, name, grade, active, created_at, updated_at"
+
+// Student is a student enrolled at a school, identified by the school's own
+// enrollment number. Students who leave are kept inactive so their handouts
+// stay on record.
+type Student struct {
+	Id           string    `json:"id"`
+	SchoolId     string    `json:"school_id"`
+	EnrollmentId string    `json:"enrollment_id"`
+	Name         string    `json:"name"`
+	Grade        string    `json:"grade"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// RosterImport counts what importing a roster did.
+type RosterImport struct {
+	Created     int `json:"created"`
+	Updated     int `json:"updated"`
+	Deactivated int `json:"deactivated"`
+}
+
+func (st *Student) scan(row rowScanner) error {
+	return row.Scan(
+		&st.Id, &st.SchoolId, &st.EnrollmentId, &st.Name, &st.Grade, &st.Active, &st.CreatedAt, &st.UpdatedAt,
+	)
+}
+
+func (st *Student) GetStudentById(db *sql.DB) error {
+	return st.scan(db.QueryRow("SELECT "+studentColumns+" FROM students WHERE id = $1", st.Id))
+}
+
+func GetStudents(db *sql.DB, schoolId string, includeInactive bool) ([]Student, error) {
+	query := "SELECT " + studentColumns + " FROM students WHERE school_id = $1"
+
+	if !includeInactive {
+		query += " AND active"
+	}
+
+	rows, err := db.Query(query+" ORDER BY grade, name, id", schoolId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	students := []Student{}
+
+	for rows.Next() {
+		var st Student
+
+		if err := st.scan(rows); err != nil {
+			return nil, err
+		}
+
+		students = append(students, st)
+	}
+
+	return students, rows.Err()
+}
+
+// ImportRoster creates or updates the school's students by enrollment number,
+// reactivating those who come back. When replace is set the roster is the
+// whole school, and students missing from it are deactivated. The school's
+// enrollment becomes its number of active students. Either the whole roster is
+// imported or nothing is.
+func ImportRoster(db *sql.DB, schoolId string, students []Student, replace bool) (RosterImport, error) {
+	var result RosterImport
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return result, err
+	}
+
+	enrolled := make([]string, len(students))
+
+	for i := range students {
+		var created bool
+
+		st := &students[i]
+		st.SchoolId = schoolId
+
+		if err = tx.QueryRow(
+			`INSERT INTO students (school_id, enrollment_id, name, grade) VALUES ($1, $2, $3, $4)
+			 ON CONFLICT (school_id, enrollment_id)
+			 DO UPDATE SET name = EXCLUDED.name, grade = EXCLUDED.grade, active = TRUE, updated_at = NOW()
+			 RETURNING id, active, created_at, updated_at, xmax = 0`,
+			schoolId, st.EnrollmentId, st.Name, st.Grade,
+		).Scan(&st.Id, &st.Active, &st.CreatedAt, &st.UpdatedAt, &created); err != nil {
+			tx.Rollback()
+			return result, err
+		}
+
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+
+		enrolled[i] = st.EnrollmentId
+	}
+
+	if replace {
+		res, err := tx.Exec(
+			`UPDATE students SET active = FALSE, updated_at = NOW()
+			 WHERE school_id = $1 AND active AND NOT (enrollment_id = ANY($2))`,
+			schoolId, pq.Array(enrolled),
+		)
+
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+
+		n, err := res.RowsAffected()
+
+		if err != nil {
+			tx.Rollback()
+			return result, err
+		}
+
+		result.Deactivated = int(n)
+	}
+
+	if _, err = tx.Exec(
+		"UPDATE schools SET enrollment = (SELECT COUNT(*) FROM students WHERE school_id = $1 AND active) WHERE id = $1",
+		schoolId,
+	); err != nil {
+		tx.Rollback()
+		return result, err
+	}
+
+	return result, tx.Commit()
+}
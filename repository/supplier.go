@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Supplier struct {
+	Id        string    `json:"id"`
+	Name      string    `json:"name"`
+	TaxId     *string   `json:"tax_id"`
+	Email     *string   `json:"email"`
+	Phone     *string   `json:"phone"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (sp *Supplier) CreateSupplier(db *sql.DB) error {
+	return db.QueryRow(
+		"INSERT INTO suppliers (name, tax_id, email, phone) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		sp.Name, sp.TaxId, sp.Email, sp.Phone,
+	).Scan(&sp.Id, &sp.CreatedAt)
+}
+
+func (sp *Supplier) GetSupplierById(db *sql.DB) error {
+	return db.QueryRow(
+		"SELECT id, name, tax_id, email, phone, created_at FROM suppliers WHERE id = $1", sp.Id,
+	).Scan(&sp.Id, &sp.Name, &sp.TaxId, &sp.Email, &sp.Phone, &sp.CreatedAt)
+}
+
+func GetSuppliers(db *sql.DB) ([]Supplier, error) {
+	rows, err := db.Query("SELECT id, name, tax_id, email, phone, created_at FROM suppliers ORDER BY name")
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	suppliers := []Supplier{}
+
+	for rows.Next() {
+		var sp Supplier
+
+		if err := rows.Scan(&sp.Id, &sp.Name, &sp.TaxId, &sp.Email, &sp.Phone, &sp.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		suppliers = append(suppliers, sp)
+	}
+
+	return suppliers, nil
+}
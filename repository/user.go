@@ -4,6 +4,13 @@ import (
 	"database/sql"
 )
 
+const (
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+
+	userColumns = "id, email, username, password, refresh_token, activated, role"
+)
+
 type User struct {
 	Id           string         `json:"id"`
 	Email        string         `json:"email"`
@@ -11,17 +18,18 @@ type User struct {
 	Password     string         `json:"password"`
 	Activated    bool           `json:"activated"`
 	RefreshToken sql.NullString `json:"refresh_token"`
+	Role         string         `json:"role"`
 }
 
 func (u *User) GetUserByEmail(db *sql.DB) error {
 	return db.QueryRow(
-		"SELECT * FROM users WHERE users.email = $1",
-		u.Email).Scan(&u.Id, &u.Email, &u.Username, &u.Password, &u.RefreshToken, &u.Activated)
+		"SELECT "+userColumns+" FROM users WHERE users.email = $1",
+		u.Email).Scan(&u.Id, &u.Email, &u.Username, &u.Password, &u.RefreshToken, &u.Activated, &u.Role)
 }
 
 func (u *User) GetUserById(db *sql.DB) error {
-	return db.QueryRow("SELECT * FROM users WHERE users.id = $1",
-		u.Id).Scan(&u.Id, &u.Email, &u.Username, &u.Password, &u.RefreshToken, &u.Activated)
+	return db.QueryRow("SELECT "+userColumns+" FROM users WHERE users.id = $1",
+		u.Id).Scan(&u.Id, &u.Email, &u.Username, &u.Password, &u.RefreshToken, &u.Activated, &u.Role)
 }
 
 func (u *User) Activate(db *sql.DB) error {
@@ -33,9 +41,13 @@ func (u *User) Activate(db *sql.DB) error {
 func (u *User) Create(db *sql.DB) error {
 	err :=
 		db.QueryRow(
-			"INSERT INTO users (email, username, password) VALUES ($1, $2, $3) RETURNING id",
+			"INSERT INTO users (email, username, password) VALUES ($1, $2, $3) RETURNING id, role",
 			u.Email, u.Username, u.Password,
-		).Scan(&u.Id)
+		).Scan(&u.Id, &u.Role)
 
 	return err
 }
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ItemHistory is an item together with its stock movements in the order they
+// happened.
+type ItemHistory struct {
+	ItemId    string
+	Name      string
+	Category  string
+	Unit      string
+	ProductId *string
+	Product   string
+	Movements []StockMovement
+}
+
+// GetItemHistories loads the movements of every item made before until,
+// archived items included.
+func GetItemHistories(db *sql.DB, until time.Time) ([]ItemHistory, error) {
+	rows, err := db.Query(
+		`SELECT warehouse_items.id, warehouse_items.name, COALESCE(categories.name, ''), warehouse_items.unit,
+		 warehouse_items.product_id, COALESCE(products.name, ''),
+		 stock_movements.id, stock_movements.kind, stock_movements.quantity, stock_movements.unit_cost,
+		 stock_movements.created_at
+		 FROM stock_movements
+		 JOIN warehouse_items ON warehouse_items.id = stock_movements.item_id
+		 LEFT JOIN categories ON categories.id = warehouse_items.category_id
+		 LEFT JOIN products ON products.id = warehouse_items.product_id
+		 WHERE stock_movements.created_at < $1
+		 ORDER BY warehouse_items.name, warehouse_items.id, stock_movements.created_at, stock_movements.id`,
+		until,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	histories := []ItemHistory{}
+
+	for rows.Next() {
+		var h ItemHistory
+		var m StockMovement
+
+		if err := rows.Scan(
+			&h.ItemId, &h.Name, &h.Category, &h.Unit, &h.ProductId, &h.Product,
+			&m.Id, &m.Kind, &m.Quantity, &m.UnitCost, &m.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		m.ItemId = h.ItemId
+
+		if len(histories) == 0 || histories[len(histories)-1].ItemId != h.ItemId {
+			histories = append(histories, h)
+		}
+
+		last := &histories[len(histories)-1]
+		last.Movements = append(last.Movements, m)
+	}
+
+	return histories, rows.Err()
+}
@@ -2,60 +2,553 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	defaultUnit = "un"
+
+	warehouseItemFields = `warehouse_items.id, warehouse_items.name, warehouse_items.quantity,
+		warehouse_items.min, warehouse_items.max, warehouse_items.category_id,
+		COALESCE(categories.name, ''), warehouse_items.unit, warehouse_items.pack_unit,
+		warehouse_items.pack_size, warehouse_items.version, warehouse_items.archived_at,
+		warehouse_items.product_id, warehouse_items.variant, `
+
+	warehouseItemColumns = warehouseItemFields + reservedQuantity
+
+	warehouseItemTables = `warehouse_items
+		LEFT JOIN categories ON categories.id = warehouse_items.category_id`
+)
+
+var (
+	ErrUnknownUnit     = errors.New("unknown unit of measure")
+	ErrVersionMismatch = errors.New("item was modified by someone else")
+	ErrNameTaken       = errors.New("item name already in use")
+	ErrItemArchived    = errors.New("item is archived")
+	ErrItemNotArchived = errors.New("item is not archived")
+	ErrItemHasHistory  = errors.New("item has stock history")
 )
 
 type WarehouseItem struct {
-	Id       string `json:"id"`
-	Name     string `json:"name"`
-	Min      int32  `json:"min"`
-	Max      int32  `json:"max"`
-	Quantity int32  `json:"quantity"`
+	Id           string     `json:"id"`
+	Name         string     `json:"name"`
+	Min          Decimal    `json:"min"`
+	Max          Decimal    `json:"max"`
+	Quantity     Decimal    `json:"quantity"`
+	OnHand       Decimal    `json:"on_hand"`
+	Reserved     Decimal    `json:"reserved"`
+	Available    Decimal    `json:"available"`
+	CategoryId   *string    `json:"category_id"`
+	CategoryName string     `json:"category,omitempty"`
+	Unit         string     `json:"unit"`
+	PackUnit     *string    `json:"pack_unit"`
+	PackSize     Decimal    `json:"pack_size"`
+	Barcodes     []string   `json:"barcodes,omitempty"`
+	ProductId    *string    `json:"product_id"`
+	Variant      Variant    `json:"variant,omitempty"`
+	Version      int32      `json:"version"`
+	ArchivedAt   *time.Time `json:"archived_at"`
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func (wi *WarehouseItem) scan(row rowScanner) error {
+	if err := row.Scan(
+		&wi.Id, &wi.Name, &wi.Quantity, &wi.Min, &wi.Max, &wi.CategoryId,
+		&wi.CategoryName, &wi.Unit, &wi.PackUnit, &wi.PackSize, &wi.Version,
+		&wi.ArchivedAt, &wi.ProductId, &wi.Variant, &wi.Reserved,
+	); err != nil {
+		return err
+	}
+
+	wi.setAvailability()
+
+	return nil
+}
+
+// setAvailability derives the on hand and available quantities from Quantity
+// and Reserved.
+func (wi *WarehouseItem) setAvailability() {
+	wi.OnHand = wi.Quantity
+	wi.Available = wi.Quantity.Sub(wi.Reserved)
+}
+
+// NormalizeName trims a name, collapses its inner whitespace and lowercases
+// it, so that "Caderno " and "caderno" are the same item. Accents are kept for
+// display; names that only differ by them are told apart by name_key.
+func NormalizeName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// normalizeUnit trims and lowercases a unit, so that "Cx" and "cx" are the
+// same unit.
+func normalizeUnit(unit string) string {
+	return strings.ToLower(strings.TrimSpace(unit))
+}
+
+func (wi *WarehouseItem) setDefaults() {
+	wi.Name = NormalizeName(wi.Name)
+	wi.Unit = normalizeUnit(wi.Unit)
+
+	if wi.Unit == "" {
+		wi.Unit = defaultUnit
+	}
+
+	if wi.PackUnit != nil {
+		packUnit := normalizeUnit(*wi.PackUnit)
+		wi.PackUnit = &packUnit
+
+		if packUnit == "" {
+			wi.PackUnit = nil
+		}
+	}
+
+	if wi.PackSize == 0 {
+		wi.PackSize = NewDecimal(1)
+	}
+}
+
+// ToBaseUnits converts a quantity expressed in unit into the item's base
+// unit. An empty unit means the base unit, and case does not matter.
+func (wi *WarehouseItem) ToBaseUnits(quantity Decimal, unit string) (Decimal, error) {
+	unit = normalizeUnit(unit)
+
+	if unit == "" || unit == normalizeUnit(wi.Unit) {
+		return quantity, nil
+	}
+
+	if wi.PackUnit != nil && unit == normalizeUnit(*wi.PackUnit) {
+		return quantity.Mul(wi.PackSize), nil
+	}
+
+	return 0, ErrUnknownUnit
+}
+
+// ToBaseUnitCost converts the cost of one unit into the cost of one base
+// unit.
+func (wi *WarehouseItem) ToBaseUnitCost(cost Decimal, unit string) (Decimal, error) {
+	unit = normalizeUnit(unit)
+
+	if unit == "" || unit == normalizeUnit(wi.Unit) {
+		return cost, nil
+	}
+
+	if wi.PackUnit != nil && unit == normalizeUnit(*wi.PackUnit) {
+		return cost.Div(wi.PackSize), nil
+	}
+
+	return 0, ErrUnknownUnit
 }
 
 func (wi *WarehouseItem) CreateWarehouseItem(db *sql.DB) error {
-	return db.QueryRow(
-		"INSERT INTO warehouse_items (name, quantity, min, max) VALUES ($1, $2, $3, $4) RETURNING id",
-		&wi.Name, &wi.Quantity, &wi.Min, &wi.Max,
-	).Scan(&wi.Id)
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = wi.CreateWarehouseItemTx(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
 
+// CreateWarehouseItemTx creates the item as part of tx, recording its initial
+// quantity as a receipt.
+func (wi *WarehouseItem) CreateWarehouseItemTx(tx *sql.Tx) error {
+	wi.setDefaults()
+
+	if err := tx.QueryRow(
+		`INSERT INTO warehouse_items (name, name_key, quantity, min, max, category_id, unit, pack_unit, pack_size)
+		 VALUES ($1, unaccent($1), 0, $2, $3, $4, $5, $6, $7) RETURNING id, version`,
+		wi.Name, wi.Min, wi.Max, wi.CategoryId, wi.Unit, wi.PackUnit, wi.PackSize,
+	).Scan(&wi.Id, &wi.Version); err != nil {
+		return err
+	}
+
+	if wi.Quantity != 0 {
+		m := StockMovement{ItemId: wi.Id, Kind: MovementReceipt, Quantity: wi.Quantity, Reason: "initial stock"}
+
+		if err := m.CreateTx(tx); err != nil {
+			return err
+		}
+
+		wi.Version++
+	}
+
+	wi.setAvailability()
+
+	return nil
+}
+
+// GetWarehouseItemById loads the item unless it is archived.
 func (wi *WarehouseItem) GetWarehouseItemById(db *sql.DB) error {
-	return db.QueryRow(
-		"SELECT * FROM warehouse_items WHERE id = $1",
+	return wi.getActive(db)
+}
+
+// GetWarehouseItemByIdTx is GetWarehouseItemById as part of tx.
+func (wi *WarehouseItem) GetWarehouseItemByIdTx(tx *sql.Tx) error {
+	return wi.getActive(tx)
+}
+
+func (wi *WarehouseItem) getActive(q querier) error {
+	return wi.scan(q.QueryRow(
+		"SELECT "+warehouseItemColumns+" FROM "+warehouseItemTables+
+			" WHERE warehouse_items.id = $1 AND warehouse_items.archived_at IS NULL",
+		wi.Id,
+	))
+}
+
+// GetAnyWarehouseItemById loads the item even if it is archived.
+func (wi *WarehouseItem) GetAnyWarehouseItemById(db *sql.DB) error {
+	return wi.scan(db.QueryRow(
+		"SELECT "+warehouseItemColumns+" FROM "+warehouseItemTables+" WHERE warehouse_items.id = $1",
 		wi.Id,
-	).Scan(&wi.Id, &wi.Name, &wi.Quantity, &wi.Min, &wi.Max)
+	))
 }
 
+// UpdateWarehouseItem saves the item if it is still at wi.Version, failing
+// with ErrVersionMismatch otherwise. On success wi.Version is the new version.
 func (wi *WarehouseItem) UpdateWarehouseItem(db *sql.DB) error {
-	_, err := db.Exec(
-		"UPDATE warehouse_items SET quantity = $1, min = $2, max = $3 WHERE id = $4",
-		wi.Quantity, wi.Min, wi.Max, wi.Id)
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if err = wi.UpdateWarehouseItemTx(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateWarehouseItemTx is UpdateWarehouseItem as part of tx.
+func (wi *WarehouseItem) UpdateWarehouseItemTx(tx *sql.Tx) error {
+	wi.setDefaults()
+
+	var current Decimal
+	var version int32
+
+	if err := tx.QueryRow(
+		"SELECT quantity, version FROM warehouse_items WHERE id = $1 FOR UPDATE", wi.Id,
+	).Scan(&current, &version); err != nil {
+		return err
+	}
+
+	if version != wi.Version {
+		return ErrVersionMismatch
+	}
+
+	if delta := wi.Quantity.Sub(current); delta != 0 {
+		m := StockMovement{ItemId: wi.Id, Kind: MovementAdjustment, Quantity: delta, Reason: "quantity updated"}
+
+		if err := m.CreateTx(tx); err != nil {
+			return err
+		}
+	}
+
+	err := tx.QueryRow(
+		`UPDATE warehouse_items SET name = $1, name_key = unaccent($1), min = $2, max = $3, category_id = $4, unit = $5, pack_unit = $6,
+		 pack_size = $7, version = $8 + 1
+		 WHERE id = $9 RETURNING version`,
+		wi.Name, wi.Min, wi.Max, wi.CategoryId, wi.Unit, wi.PackUnit, wi.PackSize, wi.Version, wi.Id,
+	).Scan(&wi.Version)
+
+	if IsUniqueViolation(err) {
+		return ErrNameTaken
+	}
 
 	return err
 }
 
+// DeleteWarehouseItem archives the item if it is still at wi.Version. The row
+// is kept so its stock history stays intact.
 func (wi *WarehouseItem) DeleteWarehouseItem(db *sql.DB) error {
-	_, err := db.Exec("DELETE FROM warehouse_items WHERE id = $1", wi.Id)
+	return wi.archive(db)
+}
+
+// DeleteWarehouseItemTx is DeleteWarehouseItem as part of tx.
+func (wi *WarehouseItem) DeleteWarehouseItemTx(tx *sql.Tx) error {
+	return wi.archive(tx)
+}
+
+func (wi *WarehouseItem) archive(e execer) error {
+	res, err := e.Exec(
+		`UPDATE warehouse_items SET archived_at = NOW(), version = version + 1
+		 WHERE id = $1 AND version = $2 AND archived_at IS NULL`,
+		wi.Id, wi.Version,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+func (wi *WarehouseItem) RestoreWarehouseItem(db *sql.DB) error {
+	err := db.QueryRow(
+		`UPDATE warehouse_items SET archived_at = NULL, version = version + 1
+		 WHERE id = $1 AND archived_at IS NOT NULL RETURNING version`,
+		wi.Id,
+	).Scan(&wi.Version)
+
+	if err == sql.ErrNoRows {
+		return ErrItemNotArchived
+	}
+
+	wi.ArchivedAt = nil
 
 	return err
 }
 
+// PurgeWarehouseItem permanently deletes an item that never had any stock
+// movement, was never ordered, shipped or handed out and is not a component
+// of any kit.
+func (wi *WarehouseItem) PurgeWarehouseItem(db *sql.DB) error {
+	res, err := db.Exec(
+		`DELETE FROM warehouse_items WHERE id = $1
+		 AND NOT EXISTS (SELECT 1 FROM stock_movements WHERE item_id = $1)
+		 AND NOT EXISTS (SELECT 1 FROM purchase_order_lines WHERE item_id = $1)
+		 AND NOT EXISTS (SELECT 1 FROM kit_components WHERE component_id = $1)
+		 AND NOT EXISTS (SELECT 1 FROM handouts WHERE item_id = $1)
+		 AND NOT EXISTS (SELECT 1 FROM shipment_lines WHERE item_id = $1)`,
+		wi.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrItemHasHistory
+	}
+
+	return nil
+}
+
+// UpSertWarehouseItem creates the item or updates its levels, receiving its
+// quantity into stock either way. An existing item keeps its unit, pack unit
+// and pack size unless they are given, since its stock is counted in them.
+// Archived items are left alone and ErrItemArchived is returned until they
+// are restored.
 func (wi *WarehouseItem) UpSertWarehouseItem(db *sql.DB) error {
-	_, err := db.Exec(
-		`INSERT INTO warehouse_items (name, quantity, min, max)
-		 VALUES ($1, $2, $3, $4)
-		 ON CONFLICT (name) 
-		 DO UPDATE SET quantity = warehouse_items.quantity + EXCLUDED.quantity, min = EXCLUDED.min, max = EXCLUDED.max`,
-		&wi.Name, &wi.Quantity, &wi.Min, &wi.Max)
+	var unit *string
+	var packSize *Decimal
 
-	return err
+	if u := normalizeUnit(wi.Unit); u != "" {
+		unit = &u
+	}
+
+	if wi.PackSize != 0 {
+		p := wi.PackSize
+		packSize = &p
+	}
+
+	wi.setDefaults()
+
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	if wi.CategoryName != "" {
+		c := Category{Name: wi.CategoryName}
+
+		if err = c.getOrCreate(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		wi.CategoryId = &c.Id
+	}
+
+	err = tx.QueryRow(
+		`INSERT INTO warehouse_items (name, name_key, quantity, min, max, category_id, unit, pack_unit, pack_size)
+		 VALUES ($1, unaccent($1), 0, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (name_key)
+		 DO UPDATE SET min = EXCLUDED.min, max = EXCLUDED.max,
+		 category_id = COALESCE(EXCLUDED.category_id, warehouse_items.category_id),
+		 unit = COALESCE($8, warehouse_items.unit),
+		 pack_unit = COALESCE(EXCLUDED.pack_unit, warehouse_items.pack_unit),
+		 pack_size = COALESCE($9, warehouse_items.pack_size),
+		 version = warehouse_items.version + 1
+		 WHERE warehouse_items.archived_at IS NULL
+		 RETURNING id, unit, pack_unit, pack_size`,
+		wi.Name, wi.Min, wi.Max, wi.CategoryId, wi.Unit, wi.PackUnit, wi.PackSize, unit, packSize,
+	).Scan(&wi.Id, &wi.Unit, &wi.PackUnit, &wi.PackSize)
+
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return ErrItemArchived
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if wi.Quantity != 0 {
+		m := StockMovement{ItemId: wi.Id, Kind: MovementReceipt, Quantity: wi.Quantity, Reason: "csv upload"}
+
+		if err = m.CreateTx(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// WarehouseItemFilter narrows and orders the items listed by
+// GetWarehouseItems. Sort must be one of the keys of WarehouseItemSortFields.
+type WarehouseItemFilter struct {
+	Name            string
+	BelowMin        bool
+	AboveMax        bool
+	MinQuantity     *Decimal
+	MaxQuantity     *Decimal
+	IncludeArchived bool
+	ProductId       string
+	AsOf            *time.Time
+	Sort            string
+	Desc            bool
+}
+
+var WarehouseItemSortFields = map[string]string{
+	"name":     "warehouse_items.name",
+	"quantity": "warehouse_items.quantity",
+	"min":      "warehouse_items.min",
+	"max":      "warehouse_items.max",
+	"category": "COALESCE(categories.name, '')",
 }
 
-func GetWarehouseItems(db *sql.DB, start, count int) ([]WarehouseItem, error) {
-	rows, err := db.Query("SELECT * FROM warehouse_items LIMIT $1 OFFSET $2", count, start)
+func (f WarehouseItemFilter) where(arg func(any) string) []string {
+	conditions := []string{}
+
+	if !f.IncludeArchived {
+		conditions = append(conditions, "warehouse_items.archived_at IS NULL")
+	}
+
+	if f.Name != "" {
+		conditions = append(conditions,
+			"unaccent(lower(warehouse_items.name)) LIKE '%' || unaccent(lower("+arg(escapeLike(f.Name))+")) || '%'")
+	}
+
+	if f.ProductId != "" {
+		conditions = append(conditions, "warehouse_items.product_id = "+arg(f.ProductId))
+	}
+
+	if f.BelowMin {
+		conditions = append(conditions, "warehouse_items.quantity < warehouse_items.min")
+	}
+
+	if f.AboveMax {
+		conditions = append(conditions, "warehouse_items.quantity > warehouse_items.max")
+	}
+
+	if f.MinQuantity != nil {
+		conditions = append(conditions, "warehouse_items.quantity >= "+arg(*f.MinQuantity))
+	}
+
+	if f.MaxQuantity != nil {
+		conditions = append(conditions, "warehouse_items.quantity <= "+arg(*f.MaxQuantity))
+	}
+
+	return conditions
+}
+
+// source returns the columns and tables items are listed from, which are the
+// items as they were at f.AsOf when it is set.
+func (f WarehouseItemFilter) source(arg func(any) string) (string, string) {
+	if f.AsOf == nil {
+		return warehouseItemColumns, warehouseItemTables
+	}
+
+	return warehouseItemColumnsAsOf, warehouseItemTablesAsOf(arg(*f.AsOf))
+}
+
+func (f WarehouseItemFilter) sortSignature() string {
+	if f.Desc {
+		return f.Sort + ":desc"
+	}
+
+	return f.Sort + ":asc"
+}
+
+func (wi WarehouseItem) sortKey(field string) string {
+	switch field {
+	case "quantity":
+		return wi.Quantity.String()
+	case "min":
+		return wi.Min.String()
+	case "max":
+		return wi.Max.String()
+	case "category":
+		return wi.CategoryName
+	}
+
+	return wi.Name
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func GetWarehouseItems(db *sql.DB, f WarehouseItemFilter, p PageRequest) (Page[WarehouseItem], error) {
+	column, ok := WarehouseItemSortFields[f.Sort]
+
+	if !ok {
+		f.Sort, column = "name", WarehouseItemSortFields["name"]
+	}
+
+	if err := p.checkSort(f.sortSignature()); err != nil {
+		return Page[WarehouseItem]{}, err
+	}
+
+	var args queryArgs
+
+	columns, tables := f.source(args.add)
+	conditions := f.where(args.add)
+	keyset, orderBy := p.keyset(column, "warehouse_items.id", f.Desc, args.add)
+
+	if keyset != "" {
+		conditions = append(conditions, keyset)
+	}
+
+	rows, err := db.Query(
+		"SELECT "+columns+" FROM "+tables+whereClause(conditions)+orderBy+
+			" LIMIT "+args.add(p.Count+1),
+		args...,
+	)
 
 	if err != nil {
-		return nil, err
+		return Page[WarehouseItem]{}, err
 	}
 
 	defer rows.Close()
@@ -65,12 +558,28 @@ func GetWarehouseItems(db *sql.DB, start, count int) ([]WarehouseItem, error) {
 	for rows.Next() {
 		var wi WarehouseItem
 
-		if err := rows.Scan(&wi.Id, &wi.Name, &wi.Quantity, &wi.Min, &wi.Max); err != nil {
-			return nil, err
+		if err := wi.scan(rows); err != nil {
+			return Page[WarehouseItem]{}, err
 		}
 
 		items = append(items, wi)
 	}
 
-	return items, nil
+	page := newPage(items, p, f.sortSignature(), func(wi WarehouseItem) (string, string) {
+		return wi.sortKey(f.Sort), wi.Id
+	})
+
+	if p.WithTotal {
+		var countArgs queryArgs
+
+		_, tables := f.source(countArgs.add)
+
+		if err := db.QueryRow(
+			"SELECT COUNT(*) FROM "+tables+whereClause(f.where(countArgs.add)), countArgs...,
+		).Scan(&page.Total); err != nil {
+			return Page[WarehouseItem]{}, err
+		}
+	}
+
+	return page, nil
 }
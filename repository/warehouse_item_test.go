@@ -0,0 +1,42 @@
+package repository
+
+import "testing"
+
+func TestToBaseUnits(t *testing.T) {
+	packUnit := "Cx"
+	wi := WarehouseItem{Unit: "Un", PackUnit: &packUnit, PackSize: NewDecimal(12)}
+
+	if q, err := wi.ToBaseUnits(NewDecimal(2), "cx"); err != nil || q != NewDecimal(24) {
+		t.Errorf("Expected 2 cx to be 24, got %s and %v", q, err)
+	}
+
+	if q, err := wi.ToBaseUnits(NewDecimal(2), " UN "); err != nil || q != NewDecimal(2) {
+		t.Errorf("Expected 2 UN to be 2, got %s and %v", q, err)
+	}
+
+	if _, err := wi.ToBaseUnits(NewDecimal(2), "kg"); err != ErrUnknownUnit {
+		t.Errorf("Expected ErrUnknownUnit, got %v", err)
+	}
+
+	if c, err := wi.ToBaseUnitCost(NewDecimal(24), "CX"); err != nil || c != NewDecimal(2) {
+		t.Errorf("Expected a CX costing 24 to be 2 per unit, got %s and %v", c, err)
+	}
+}
+
+func TestSetDefaultsNormalizesUnits(t *testing.T) {
+	packUnit, blank := " Cx ", " "
+
+	wi := WarehouseItem{Name: "Caderno", Unit: "UN", PackUnit: &packUnit}
+	wi.setDefaults()
+
+	if wi.Unit != "un" || *wi.PackUnit != "cx" {
+		t.Errorf("Expected un and cx, got %q and %q", wi.Unit, *wi.PackUnit)
+	}
+
+	wi = WarehouseItem{Name: "Lápis", PackUnit: &blank}
+	wi.setDefaults()
+
+	if wi.Unit != defaultUnit || wi.PackUnit != nil {
+		t.Errorf("Expected the default unit and no pack unit, got %q and %v", wi.Unit, wi.PackUnit)
+	}
+}